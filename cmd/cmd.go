@@ -6,6 +6,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
 	"github.com/anchore/k8s-inventory/internal/config"
@@ -17,6 +18,9 @@ var (
 	appConfig   *config.Application
 	log         *logrus.Logger
 	cliOnlyOpts config.CliOnlyOptions
+	// flagKeys maps the name of each flag bound to a setting to the key of the setting, e.g. kubeconfig to
+	// kubeconfig.path
+	flagKeys = map[string]string{}
 )
 
 func init() {
@@ -38,12 +42,18 @@ func setGlobalCliOptions() {
 		flag, "q", false,
 		"suppress all logging output",
 	)
-	if err := viper.BindPFlag(flag, rootCmd.PersistentFlags().Lookup(flag)); err != nil {
+	bindFlag(rootCmd.PersistentFlags(), flag, flag)
+
+	rootCmd.PersistentFlags().CountVarP(&cliOnlyOpts.Verbosity, "verbose", "v", "increase verbosity (-v = info, -vv = debug)")
+}
+
+// bindFlag binds the flag to the setting with the key
+func bindFlag(flags *pflag.FlagSet, flag, key string) {
+	if err := viper.BindPFlag(key, flags.Lookup(flag)); err != nil {
 		fmt.Printf("unable to bind flag '%s': %+v", flag, err)
 		os.Exit(1)
 	}
-
-	rootCmd.PersistentFlags().CountVarP(&cliOnlyOpts.Verbosity, "verbose", "v", "increase verbosity (-v = info, -vv = debug)")
+	flagKeys[flag] = key
 }
 
 func Execute() {
@@ -54,6 +64,11 @@ func Execute() {
 }
 
 func InitAppConfig() {
+	if validatingConfig() {
+		// the config validate command loads the configuration itself, to report all the problems with it
+		appConfig = &config.Application{CliOptions: cliOnlyOpts}
+		return
+	}
 	cfg, err := config.LoadConfigFromFile(viper.GetViper(), &cliOnlyOpts)
 	if err != nil {
 		fmt.Printf("failed to load application config: \n\t%+v\n", err)
@@ -62,6 +77,12 @@ func InitAppConfig() {
 	appConfig = cfg
 }
 
+// validatingConfig returns whether the config validate command is run
+func validatingConfig() bool {
+	cmd, _, err := rootCmd.Find(os.Args[1:])
+	return err == nil && cmd == configValidateCmd
+}
+
 func GetAppConfig() *config.Application {
 	return appConfig
 }
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+)
+
+var configOutput string
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "validate and show the configuration",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "validate the configuration and report all problems with it at once",
+	Long: `Load the configuration like the agent does, and report all problems with it at once with the key and the
+source of each setting concerned: settings that are invalid, unknown keys, regular expressions that do not compile
+(namespace selectors, account routes and metadata collection), account routes without a name, URLs that do not parse
+and files that do not exist.`,
+	Args: cobra.NoArgs,
+	Run:  validateConfig,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "show the effective configuration and the source of each value",
+	Long: `Show the effective configuration, merged from the flags, environment variables, config file and defaults,
+with the secrets redacted and the source of each value.`,
+	Args: cobra.NoArgs,
+	Run:  showConfig,
+}
+
+func init() {
+	configShowCmd.Flags().StringVarP(&configOutput, "output", "o", "table", "output format, options=[table json]")
+
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// changedFlags returns the keys of the settings set with the flags of the command, and the name of each flag
+func changedFlags(cmd *cobra.Command) map[string]string {
+	changed := map[string]string{}
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if key, ok := flagKeys[flag.Name]; ok {
+			changed[key] = flag.Name
+		}
+	})
+	return changed
+}
+
+func validateConfig(cmd *cobra.Command, _ []string) {
+	problems := config.Validate(viper.GetViper(), &cliOnlyOpts, changedFlags(cmd))
+	configPath := viper.GetViper().ConfigFileUsed()
+	if configPath == "" {
+		configPath = "no config file"
+	}
+	if len(problems) == 0 {
+		fmt.Printf("%s: configuration is valid\n", configPath)
+		return
+	}
+	fmt.Printf("%s: %d problem(s) found\n", configPath, len(problems))
+	for _, problem := range problems {
+		fmt.Printf("  - %s\n", problem)
+	}
+	os.Exit(1)
+}
+
+func showConfig(cmd *cobra.Command, _ []string) {
+	settings, err := appConfig.Settings(viper.GetViper(), changedFlags(cmd))
+	if err != nil {
+		log.Errorf("Failed to get configuration settings: %+v", err)
+		os.Exit(1)
+	}
+	if err := config.WriteSettings(os.Stdout, settings, configOutput); err != nil {
+		log.Errorf("Failed to show configuration: %+v", err)
+		os.Exit(1)
+	}
+}
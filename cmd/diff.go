@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/anchore/k8s-inventory/pkg/inventory"
+)
+
+var diffOutput string
+
+var diffCmd = &cobra.Command{
+	Use:   "diff OLD_REPORT NEW_REPORT",
+	Short: "show the differences between two inventory reports",
+	Long: `Show the namespaces, pods and containers that were added or removed between two inventory reports
+(e.g. from --verbose-inventory-reports), and the containers whose image tag changed or whose tag now refers to a
+different digest. Namespaces and pods are matched by UID, and containers by their pod UID and name.`,
+	Args: cobra.ExactArgs(2),
+	Run:  diffReports,
+}
+
+func init() {
+	diffCmd.Flags().StringVarP(&diffOutput, "output", "o", "text", "output format, options=[text json]")
+
+	rootCmd.AddCommand(diffCmd)
+}
+
+func diffReports(_ *cobra.Command, args []string) {
+	oldReport, err := inventory.ReadReport(args[0])
+	if err != nil {
+		log.Errorf("Failed to read old report: %+v", err)
+		os.Exit(1)
+	}
+	newReport, err := inventory.ReadReport(args[1])
+	if err != nil {
+		log.Errorf("Failed to read new report: %+v", err)
+		os.Exit(1)
+	}
+
+	if err := inventory.WriteDiff(os.Stdout, inventory.Diff(oldReport, newReport), diffOutput); err != nil {
+		log.Errorf("Failed to show differences: %+v", err)
+		os.Exit(1)
+	}
+}
@@ -1,22 +1,34 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime/pprof"
+	"syscall"
+	"time"
 
+	"github.com/anchore/k8s-inventory/internal/metrics"
+	"github.com/anchore/k8s-inventory/pkg/agentstatus"
 	"github.com/anchore/k8s-inventory/pkg/healthreporter"
 	"github.com/anchore/k8s-inventory/pkg/integration"
+	"github.com/anchore/k8s-inventory/pkg/leader"
 	"github.com/anchore/k8s-inventory/pkg/mode"
 	"github.com/anchore/k8s-inventory/pkg/reporter"
+	"github.com/anchore/k8s-inventory/pkg/server"
+	"github.com/anchore/k8s-inventory/pkg/sharding"
+	"github.com/anchore/k8s-inventory/pkg/tracing"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 
 	"github.com/anchore/k8s-inventory/pkg"
 )
 
+// tracingShutdownTimeout bounds how long the remaining spans are flushed for on exit
+const tracingShutdownTimeout = 5 * time.Second
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "anchore-k8s-inventory",
@@ -47,85 +59,257 @@ var rootCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		if appConfig.CliOptions.DryRun {
+			runDryRun()
+			return
+		}
+
 		switch appConfig.RunMode {
 		case mode.PeriodicPolling:
-			neverDone := make(chan bool, 1)
-
-			ch := integration.GetChannels()
-			gatedReportInfo := healthreporter.GetGatedReportInfo()
-
-			go healthreporter.PeriodicallySendHealthReport(appConfig, ch, gatedReportInfo)
-			go pkg.PeriodicallyGetInventoryReport(appConfig, ch, gatedReportInfo)
-
-			_, err := integration.PerformRegistration(appConfig, ch)
-			if err != nil {
+			runPeriodic()
+		default:
+			shutdownTracing := setupTracing()
+			anErrorOccurred := runAdhoc()
+			shutdownTracing()
+			if anErrorOccurred {
 				os.Exit(1)
 			}
+		}
+	},
+}
 
-			<-neverDone
-		default:
-			reports, err := pkg.GetInventoryReports(appConfig)
-			if appConfig.Dev.ProfileCPU {
-				pprof.StopCPUProfile()
+// runAdhoc reports the inventory once, returning whether an error occurred
+func runAdhoc() bool {
+	ctx, span := tracing.Tracer().Start(context.Background(), "inventory.poll")
+	defer span.End()
+
+	reports, err := pkg.GetInventoryReports(ctx, appConfig)
+	if appConfig.Dev.ProfileCPU {
+		pprof.StopCPUProfile()
+	}
+	if err != nil {
+		log.Errorf("Failed to get Image Results: %+v", err)
+		return true
+	}
+	anErrorOccurred := false
+	reportInfo := healthreporter.InventoryReportInfo{}
+	for account, reportsForAccount := range reports {
+		for count, report := range reportsForAccount {
+			log.Infof("Sending Inventory Report to Anchore Account %s, %d of %d", account, count+1, len(reportsForAccount))
+			err = pkg.HandleReport(ctx, report, &reportInfo, appConfig, account)
+			if errors.Is(err, reporter.ErrAnchoreAccountDoesNotExist) {
+				// Retry with default account
+				retryAccount := pkg.GetDefaultAccount(appConfig)
+				metrics.DefaultAccountFallbacks.WithLabelValues(account).Inc()
+				log.Warnf("Error sending to Anchore Account %s, sending to default account", account)
+				err = pkg.HandleReport(ctx, report, &reportInfo, appConfig, retryAccount)
 			}
 			if err != nil {
-				log.Errorf("Failed to get Image Results: %+v", err)
-				os.Exit(1)
-			}
-			anErrorOccurred := false
-			reportInfo := healthreporter.InventoryReportInfo{}
-			for account, reportsForAccount := range reports {
-				for count, report := range reportsForAccount {
-					log.Infof("Sending Inventory Report to Anchore Account %s, %d of %d", account, count+1, len(reportsForAccount))
-					err = pkg.HandleReport(report, &reportInfo, appConfig, account)
-					if errors.Is(err, reporter.ErrAnchoreAccountDoesNotExist) {
-						// Retry with default account
-						retryAccount := appConfig.AnchoreDetails.Account
-						if appConfig.AccountRouteByNamespaceLabel.DefaultAccount != "" {
-							retryAccount = appConfig.AccountRouteByNamespaceLabel.DefaultAccount
-						}
-						log.Warnf("Error sending to Anchore Account %s, sending to default account", account)
-						err = pkg.HandleReport(report, &reportInfo, appConfig, retryAccount)
-					}
-					if err != nil {
-						log.Errorf("Failed to handle Image Results: %+v", err)
-						anErrorOccurred = true
-					}
-				}
+				log.Errorf("Failed to handle Image Results: %+v", err)
+				anErrorOccurred = true
 			}
-			if anErrorOccurred {
-				os.Exit(1)
+		}
+	}
+	return anErrorOccurred
+}
+
+// setupTracing starts exporting traces if tracing is enabled, and returns a function that flushes and stops the
+// export
+func setupTracing() func() {
+	if !appConfig.Tracing.Enabled {
+		return func() {}
+	}
+	shutdown, err := tracing.Setup(context.Background(), appConfig.Tracing)
+	if err != nil {
+		log.Errorf("Failed to set up tracing: %+v", err)
+		os.Exit(1)
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			log.Errorf("Failed to shut down tracing: %+v", err)
+		}
+	}
+}
+
+// runPeriodic reports the inventory periodically until the process is interrupted or terminated
+func runPeriodic() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing := setupTracing()
+	defer shutdownTracing()
+
+	var srv *server.Server
+	if appConfig.Server.Enabled {
+		srv = server.New(appConfig.Server)
+		if err := srv.Start(); err != nil {
+			log.Errorf("Failed to start server: %+v", err)
+			os.Exit(1)
+		}
+	}
+
+	if appConfig.AgentStatus.IsEnabled() {
+		statusReporter, err := agentstatus.NewForConfig(appConfig)
+		if err != nil {
+			log.Errorf("Failed to set up agent status: %+v", err)
+			os.Exit(1)
+		}
+		statusReporter.Start(ctx)
+	}
+
+	ch := integration.GetChannels()
+	gatedReportInfo := healthreporter.GetGatedReportInfo()
+
+	healthReportingDone := make(chan struct{})
+	go func() {
+		defer close(healthReportingDone)
+		healthreporter.PeriodicallySendHealthReport(ctx, appConfig, ch, gatedReportInfo)
+	}()
+	inventoryReportingDone := startInventoryReporting(ctx, ch, gatedReportInfo)
+	go func() {
+		_, err := integration.PerformRegistration(appConfig, ch)
+		if err != nil {
+			os.Exit(1)
+		}
+		server.SetReady("registered with Anchore")
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Info("anchore-k8s-inventory is shutting down...")
+	if inventoryReportingDone != nil {
+		// give the agent the time to release its Lease, so that the other replicas take over right away
+		select {
+		case <-inventoryReportingDone:
+		case <-time.After(leaseReleaseTimeout()):
+			log.Warnf("Gave up waiting for the Lease to be released")
+		}
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(),
+		time.Duration(appConfig.Registration.DeactivationTimeoutSeconds)*time.Second)
+	// the periodic health reports stop first, so that they do not overlap with the final health report
+	select {
+	case <-healthReportingDone:
+	case <-shutdownCtx.Done():
+		log.Warnf("Gave up waiting for the periodic health reports to stop")
+	}
+	if registered := integration.Current(); registered != nil {
+		if err := healthreporter.SendFinalHealthReport(shutdownCtx, appConfig, registered, gatedReportInfo); err != nil {
+			log.Warnf("Failed to send final health report: %v", err)
+		}
+		if registered.DeactivatesOnShutdown(appConfig) {
+			if err := healthreporter.Deactivate(shutdownCtx, appConfig, registered, "agent was shut down"); err != nil {
+				log.Errorf("Failed to deactivate integration: %+v", err)
 			}
 		}
-	},
+	}
+	cancel()
+	if srv != nil {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Errorf("Failed to shut down server: %+v", err)
+		}
+	}
+}
+
+// startInventoryReporting reports the inventory periodically in the background, only while leading the replicas if
+// leader election is enabled, or only for the share of the namespaces of the agent if sharding is enabled. In these
+// cases, the returned channel is closed once the agent stopped reporting and released its Lease after the context is
+// done, otherwise it is nil.
+func startInventoryReporting(ctx context.Context, ch integration.Channels, gatedReportInfo *healthreporter.GatedReportInfo) <-chan struct{} {
+	if appConfig.Sharding.Enabled {
+		left := startSharding(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			pkg.PeriodicallyGetInventoryReportUntilDone(ctx, appConfig, ch, gatedReportInfo)
+			<-left
+		}()
+		return done
+	}
+	if !appConfig.LeaderElection.Enabled {
+		go pkg.PeriodicallyGetInventoryReport(appConfig, ch, gatedReportInfo)
+		return nil
+	}
+
+	elector, err := leader.NewForConfig(appConfig)
+	if err != nil {
+		log.Errorf("Failed to set up leader election: %+v", err)
+		os.Exit(1)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pkg.PeriodicallyGetInventoryReportWhileLeading(ctx, appConfig, ch, gatedReportInfo, elector)
+	}()
+	return done
+}
+
+// startSharding joins the replicas that share the namespaces, before the inventory reporting starts so that the first
+// cycle collects only the share of the agent
+func startSharding(ctx context.Context) <-chan struct{} {
+	membership, err := sharding.NewForConfig(appConfig)
+	if err != nil {
+		log.Errorf("Failed to set up sharding: %+v", err)
+		os.Exit(1)
+	}
+	membership.Join(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		membership.Run(ctx)
+	}()
+	return done
+}
+
+// leaseReleaseTimeout is how long to wait for the agent to release its Lease on shutdown
+func leaseReleaseTimeout() time.Duration {
+	if appConfig.Sharding.Enabled {
+		return time.Duration(appConfig.Sharding.RenewIntervalSeconds) * time.Second
+	}
+	return time.Duration(appConfig.LeaderElection.RenewDeadlineSeconds) * time.Second
+}
+
+// runDryRun collects, routes, batches and normalizes the inventory like a normal run, and prints what would be sent
+// to Anchore instead of sending it
+func runDryRun() {
+	plan, err := pkg.GetDryRunPlan(appConfig)
+	if appConfig.Dev.ProfileCPU {
+		pprof.StopCPUProfile()
+	}
+	if err != nil {
+		log.Errorf("Failed to get Image Results: %+v", err)
+		os.Exit(1)
+	}
+	if err := pkg.WriteDryRunPlan(os.Stdout, plan); err != nil {
+		log.Errorf("Failed to write dry run plan: %+v", err)
+		os.Exit(1)
+	}
 }
 
 func init() {
 	opt := "kubeconfig"
 	rootCmd.Flags().StringP(opt, "k", "", "(optional) absolute path to the kubeconfig file")
-	if err := viper.BindPFlag(opt+".path", rootCmd.Flags().Lookup(opt)); err != nil {
-		fmt.Printf("unable to bind flag '%s': %+v", opt, err)
-		os.Exit(1)
-	}
+	bindFlag(rootCmd.Flags(), opt, opt+".path")
 
 	opt = "mode"
 	rootCmd.Flags().StringP(opt, "m", mode.AdHoc.String(), fmt.Sprintf("execution mode, options=%v", mode.Modes))
-	if err := viper.BindPFlag(opt, rootCmd.Flags().Lookup(opt)); err != nil {
-		fmt.Printf("unable to bind flag '%s': %+v", opt, err)
-		os.Exit(1)
-	}
+	bindFlag(rootCmd.Flags(), opt, opt)
 
 	opt = "polling-interval-seconds"
 	rootCmd.Flags().StringP(opt, "p", "300", "If mode is 'periodic', this specifies the interval")
-	if err := viper.BindPFlag(opt, rootCmd.Flags().Lookup(opt)); err != nil {
-		fmt.Printf("unable to bind flag '%s': %+v", opt, err)
-		os.Exit(1)
-	}
+	bindFlag(rootCmd.Flags(), opt, opt)
+
+	rootCmd.Flags().BoolVar(&cliOnlyOpts.DryRun, "dry-run", false,
+		"collect the inventory and print the accounts, batches and sizes that would be reported, without sending anything to Anchore")
 
 	opt = "verbose-inventory-reports"
 	rootCmd.Flags().BoolP(opt, "i", false, "If true, will print the full inventory report to stdout")
-	if err := viper.BindPFlag(opt, rootCmd.Flags().Lookup(opt)); err != nil {
-		fmt.Printf("unable to bind flag '%s': %+v", opt, err)
-		os.Exit(1)
+	bindFlag(rootCmd.Flags(), opt, opt)
+
+	// the config commands validate and show the settings of the agent flags too
+	for _, name := range []string{"kubeconfig", "mode", "polling-interval-seconds", "verbose-inventory-reports"} {
+		configCmd.PersistentFlags().AddFlag(rootCmd.Flags().Lookup(name))
 	}
 }
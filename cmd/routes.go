@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/anchore/k8s-inventory/pkg"
+)
+
+var (
+	routesNamespacesFile string
+	routesOutput         string
+)
+
+var routesCmd = &cobra.Command{
+	Use:   "routes",
+	Short: "inspect how namespaces are routed to Anchore accounts",
+}
+
+var routesExplainCmd = &cobra.Command{
+	Use:   "explain",
+	Short: "show the account(s) each namespace is reported to and the rule that routed it",
+	Long: `Show the account(s) each namespace is reported to and the rule that routed it (account-routes,
+account-route-by-namespace-label or the default account). Namespaces matched by more than one account route,
+and namespaces that are not reported because they are missing the routing label, are flagged.
+
+The namespaces are read from the cluster, or from a file with --namespaces-file, either a namespace list
+(e.g. kubectl get namespaces -o yaml) or one namespace name per line.`,
+	Args: cobra.NoArgs,
+	Run:  explainRoutes,
+}
+
+func init() {
+	routesExplainCmd.Flags().StringVarP(&routesNamespacesFile, "namespaces-file", "f", "",
+		"explain the routing for the namespaces in this file instead of the ones in the cluster")
+	routesExplainCmd.Flags().StringVarP(&routesOutput, "output", "o", "table", "output format, options=[table json]")
+
+	routesCmd.AddCommand(routesExplainCmd)
+	rootCmd.AddCommand(routesCmd)
+}
+
+func explainRoutes(_ *cobra.Command, _ []string) {
+	decisions, err := pkg.GetRouteDecisions(appConfig, routesNamespacesFile)
+	if err != nil {
+		log.Errorf("Failed to get namespaces: %+v", err)
+		os.Exit(1)
+	}
+	if err := pkg.WriteRouteDecisions(os.Stdout, decisions, routesOutput); err != nil {
+		log.Errorf("Failed to explain routes: %+v", err)
+		os.Exit(1)
+	}
+}
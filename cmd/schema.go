@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/anchore/k8s-inventory/pkg/inventory"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "show the JSON Schema of the inventory report",
+	Long: fmt.Sprintf(`Show the JSON Schema of the inventory report (version %s). Every report includes its format version
+as schema_version.`, inventory.SchemaVersion),
+	Args: cobra.NoArgs,
+	Run:  printSchema,
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+}
+
+func printSchema(_ *cobra.Command, _ []string) {
+	schema, err := inventory.Schema()
+	if err != nil {
+		log.Errorf("Failed to generate schema: %+v", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(schema))
+}
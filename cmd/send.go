@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/anchore/k8s-inventory/pkg"
+	"github.com/anchore/k8s-inventory/pkg/inventory"
+)
+
+var sendAccount string
+
+var sendCmd = &cobra.Command{
+	Use:   "send REPORT [REPORT...]",
+	Short: "send saved inventory reports to Anchore",
+	Long: `Send inventory reports saved to files (e.g. with --verbose-inventory-reports) to an Anchore account. Each file
+can contain a single JSON report or a stream of reports (e.g. NDJSON). The reports are normalized, batched by
+inventory-report-limits and sent in the same way as a collected inventory, falling back to the default account if
+the account does not exist in Anchore.
+
+This can be used to report the inventory of clusters that cannot reach Anchore, or to re-send reports that failed.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  sendReports,
+}
+
+func init() {
+	sendCmd.Flags().StringVarP(&sendAccount, "account", "a", "",
+		"the Anchore account to send the reports to (defaults to anchore.account)")
+
+	rootCmd.AddCommand(sendCmd)
+}
+
+func sendReports(cmd *cobra.Command, args []string) {
+	reports := make([]inventory.Report, 0)
+	for _, path := range args {
+		reportsInFile, err := inventory.ReadReports(path)
+		if err != nil {
+			log.Errorf("Failed to read reports: %+v", err)
+			os.Exit(1)
+		}
+		reports = append(reports, reportsInFile...)
+	}
+
+	// sending stops when the command is interrupted or terminated
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	shutdownTracing := setupTracing()
+	err := pkg.SendReports(ctx, appConfig, sendAccount, reports)
+	shutdownTracing()
+	stop()
+	if err != nil {
+		log.Errorf("Failed to send reports: %+v", err)
+		os.Exit(1)
+	}
+}
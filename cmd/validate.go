@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/anchore/k8s-inventory/pkg/inventory"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate REPORT [REPORT...]",
+	Short: "validate saved inventory reports against the inventory report schema",
+	Long: `Validate inventory reports saved to files (e.g. with --verbose-inventory-reports) against the JSON Schema of the
+inventory report (see the schema command). Each file can contain a single JSON report or a stream of reports.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  validateReports,
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
+
+func validateReports(_ *cobra.Command, args []string) {
+	anErrorOccurred := false
+	for _, path := range args {
+		count, err := inventory.ValidateReportFile(path)
+		if err != nil {
+			log.Errorf("%+v", err)
+			anErrorOccurred = true
+			continue
+		}
+		fmt.Printf("%s: %d valid inventory report(s)\n", path, count)
+	}
+	if anErrorOccurred {
+		os.Exit(1)
+	}
+}
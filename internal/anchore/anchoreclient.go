@@ -2,12 +2,12 @@ package anchore
 
 import (
 	"bytes"
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/anchore/k8s-inventory/internal/config"
 	"github.com/anchore/k8s-inventory/internal/log"
+	"github.com/anchore/k8s-inventory/internal/metrics"
 	"github.com/anchore/k8s-inventory/internal/tracker"
 	"github.com/h2non/gock"
 	"io"
@@ -15,12 +15,18 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
 
-const methodNotAllowedDetail = "Method Not Allowed"
+const (
+	methodNotAllowedDetail = "Method Not Allowed"
+	InventoryAPIPathV1     = "v1/enterprise/kubernetes-inventory"
+	InventoryAPIPathV2     = "v2/kubernetes-inventory"
+)
 
 type Version struct {
 	API struct {
@@ -41,10 +47,11 @@ type ControllerErrorDetails struct {
 	Status int    `json:"status"`
 }
 
+// APIErrorDetails is the error of the Anchore API. Its detail is an object or a string depending on the endpoint.
 type APIErrorDetails struct {
-	Message  string                 `json:"message"`
-	Detail   map[string]interface{} `json:"detail"`
-	HTTPCode int                    `json:"httpcode"`
+	Message  string      `json:"message"`
+	Detail   interface{} `json:"detail"`
+	HTTPCode int         `json:"httpcode"`
 }
 
 type APIClientError struct {
@@ -62,15 +69,82 @@ func (e *APIClientError) Error() string {
 		e.APIErrorDetails, e.ControllerErrorDetails)
 }
 
+// Client is a reusable Anchore API client. It keeps a single HTTP client (and therefore a single pool of
+// connections) per Anchore URL, and caches the API version negotiated with each of those URLs so that it is
+// only detected once. A Client is safe for concurrent use.
+type Client struct {
+	mu             sync.Mutex
+	endpoints      map[string]*endpoint
+	authenticators map[string]*oauth2Authenticator
+}
+
+// endpoint holds the per-URL state of a Client
+type endpoint struct {
+	httpConfig config.HTTPConfig
+	httpClient *http.Client
+	tlsFiles   map[string]time.Time
+	version    *Version
+}
+
+// Capabilities describes the API endpoints available on an Anchore instance
+type Capabilities struct {
+	InventoryAPIPath string
+}
+
+var defaultClient = NewClient()
+
+// NewClient returns a new Client without any cached connections or versions
+func NewClient() *Client {
+	return &Client{
+		endpoints:      make(map[string]*endpoint),
+		authenticators: make(map[string]*oauth2Authenticator),
+	}
+}
+
+// DefaultClient returns the Client shared by the whole application
+func DefaultClient() *Client {
+	return defaultClient
+}
+
+// GetVersion retrieves the version of Anchore using the shared default client
 func GetVersion(anchoreDetails config.AnchoreInfo) (*Version, error) {
+	return defaultClient.GetVersion(anchoreDetails)
+}
+
+// Post sends a request to Anchore using the shared default client
+func Post(requestBody []byte, id string, path string, anchoreDetails config.AnchoreInfo, operation string) (*[]byte, error) {
+	return defaultClient.Post(requestBody, id, path, anchoreDetails, operation)
+}
+
+// Put sends an update to Anchore using the shared default client
+func Put(requestBody []byte, id string, path string, anchoreDetails config.AnchoreInfo, operation string) (*[]byte, error) {
+	return defaultClient.Put(requestBody, id, path, anchoreDetails, operation)
+}
+
+// Get retrieves a resource from Anchore using the shared default client
+func Get(id string, path string, anchoreDetails config.AnchoreInfo, operation string) (*[]byte, error) {
+	return defaultClient.Get(id, path, anchoreDetails, operation)
+}
+
+// GetVersion retrieves the version of Anchore and caches it for later capability lookups
+func (c *Client) GetVersion(anchoreDetails config.AnchoreInfo) (*Version, error) {
 	operation := "version get"
 	defer tracker.TrackFunctionTime(time.Now(), fmt.Sprintf("Sent %s request to Anchore", operation))
 
 	log.Debug("Determining Anchore service version")
 
-	client := getClient(anchoreDetails)
+	client, err := c.getClient(anchoreDetails)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest("GET", anchoreDetails.URL+"/version", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare %s request to Anchore: %w", operation, err)
+	}
+	setHeaders(request, anchoreDetails.HTTP)
 
-	response, err := client.Get(anchoreDetails.URL + "/version")
+	response, err := client.Do(request)
 	if err != nil {
 		return nil, err
 	}
@@ -91,41 +165,189 @@ func GetVersion(anchoreDetails config.AnchoreInfo) (*Version, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse API version: %w", err)
 	}
+
+	c.mu.Lock()
+	c.getEndpoint(anchoreDetails).version = &ver
+	c.mu.Unlock()
+
 	return &ver, nil
 }
 
-func Post(requestBody []byte, id string, path string, anchoreDetails config.AnchoreInfo, operation string) (*[]byte, error) {
-	defer tracker.TrackFunctionTime(time.Now(), fmt.Sprintf("Sent %s request to Anchore", operation))
+// Capabilities returns the API endpoints to use for the Anchore instance, based on the last version retrieved
+// from it. The latest API is assumed until a version has been retrieved.
+func (c *Client) Capabilities(anchoreDetails config.AnchoreInfo) Capabilities {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return capabilitiesForVersion(c.getEndpoint(anchoreDetails).version)
+}
+
+// Reset forgets all cached connections and negotiated versions
+func (c *Client) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.endpoints = make(map[string]*endpoint)
+	c.authenticators = make(map[string]*oauth2Authenticator)
+}
+
+// Post sends the request body to the Anchore API path, replacing any {{id}} placeholder in the path with id
+func (c *Client) Post(requestBody []byte, id string, path string, anchoreDetails config.AnchoreInfo, operation string) (*[]byte, error) {
+	return c.send(http.MethodPost, requestBody, id, path, anchoreDetails, operation)
+}
+
+// Put sends the request body to the Anchore API path as an update, replacing any {{id}} placeholder in the path
+// with id
+func (c *Client) Put(requestBody []byte, id string, path string, anchoreDetails config.AnchoreInfo, operation string) (*[]byte, error) {
+	return c.send(http.MethodPut, requestBody, id, path, anchoreDetails, operation)
+}
+
+// Get retrieves the resource at the Anchore API path, replacing any {{id}} placeholder in the path with id
+func (c *Client) Get(id string, path string, anchoreDetails config.AnchoreInfo, operation string) (*[]byte, error) {
+	return c.send(http.MethodGet, nil, id, path, anchoreDetails, operation)
+}
+
+func (c *Client) send(method string, requestBody []byte, id string, path string, anchoreDetails config.AnchoreInfo, operation string) (responseBody *[]byte, err error) {
+	start := time.Now()
+	defer tracker.TrackFunctionTime(start, fmt.Sprintf("Sent %s request to Anchore", operation))
+	defer func() {
+		statusCode := 0
+		var apiClientError *APIClientError
+		if errors.As(err, &apiClientError) {
+			statusCode = apiClientError.HTTPStatusCode
+		}
+		metrics.ObserveAnchoreRequest(operation, anchoreDetails.Account, start, statusCode, err)
+	}()
 
 	log.Debugf("Performing %s to Anchore using endpoint: %s", operation, strings.Replace(path, "{{id}}", id, 1))
 
-	client := getClient(anchoreDetails)
+	client, err := c.getClient(anchoreDetails)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := c.getAuthenticator(anchoreDetails)
+	if err != nil {
+		return nil, err
+	}
 
 	anchoreURL, err := getURL(anchoreDetails, path, id)
 	if err != nil {
 		return nil, err
 	}
 
-	request, err := getPostRequest(anchoreDetails, anchoreURL, requestBody, operation)
+	request, err := getRequest(method, auth, anchoreDetails, anchoreURL, requestBody, operation)
 	if err != nil {
 		return nil, err
 	}
 
-	return doPost(client, request, operation)
+	responseBody, err = doRequest(client, request, operation)
+	if IncorrectCredentials(err) && auth.Invalidate() {
+		log.Debugf("Credentials rejected by Anchore during %s, retrying with fresh credentials", operation)
+		request, err = getRequest(method, auth, anchoreDetails, anchoreURL, requestBody, operation)
+		if err != nil {
+			return nil, err
+		}
+		return doRequest(client, request, operation)
+	}
+	return responseBody, err
+}
+
+// getClient returns the HTTP client for the Anchore URL, creating it if it does not exist yet, if the HTTP
+// configuration for the URL has changed or if any of the certificate files it uses changed on disk
+func (c *Client) getClient(anchoreDetails config.AnchoreInfo) (*http.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ep := c.getEndpoint(anchoreDetails)
+	tlsFiles := tlsFileModTimes(anchoreDetails.HTTP)
+	if ep.httpClient != nil && reflect.DeepEqual(ep.httpConfig, anchoreDetails.HTTP) && reflect.DeepEqual(ep.tlsFiles, tlsFiles) {
+		return ep.httpClient, nil
+	}
+
+	httpClient, err := newHTTPClient(anchoreDetails.HTTP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure HTTP client for %s: %w", anchoreDetails.URL, err)
+	}
+	if ep.httpClient != nil {
+		log.Debugf("HTTP configuration for %s changed, replacing HTTP client", anchoreDetails.URL)
+		ep.httpClient.CloseIdleConnections()
+	}
+	ep.httpConfig = anchoreDetails.HTTP
+	ep.httpClient = httpClient
+	ep.tlsFiles = tlsFiles
+	return ep.httpClient, nil
+}
+
+// getAuthenticator returns the Authenticator for the credentials in the Anchore details. A bearer token takes
+// precedence over OAuth2 client credentials, which take precedence over the user and password.
+func (c *Client) getAuthenticator(anchoreDetails config.AnchoreInfo) (Authenticator, error) {
+	if token := anchoreDetails.BearerToken(); token != "" {
+		return bearerAuthenticator{token: token}, nil
+	}
+	if anchoreDetails.OAuth2.IsValid() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		// OAuth2 authenticators are shared so that the access token they hold is reused across requests
+		key := anchoreDetails.OAuth2.TokenURL + "|" + anchoreDetails.OAuth2.ClientID
+		timeout := time.Duration(anchoreDetails.HTTP.TimeoutSeconds) * time.Second
+		auth, ok := c.authenticators[key]
+		if !ok || !reflect.DeepEqual(auth.config, anchoreDetails.OAuth2) || auth.httpClient.Timeout != timeout {
+			httpClient, err := newTokenHTTPClient(anchoreDetails.HTTP.TimeoutSeconds)
+			if err != nil {
+				return nil, fmt.Errorf("failed to configure HTTP client for %s: %w", anchoreDetails.OAuth2.TokenURL, err)
+			}
+			auth = newOAuth2Authenticator(anchoreDetails.OAuth2, httpClient)
+			c.authenticators[key] = auth
+		}
+		return auth, nil
+	}
+	return basicAuthenticator{user: anchoreDetails.User, password: anchoreDetails.Password}, nil
 }
 
-func getClient(anchoreDetails config.AnchoreInfo) *http.Client {
+// getEndpoint must be called with the mutex held
+func (c *Client) getEndpoint(anchoreDetails config.AnchoreInfo) *endpoint {
+	ep, ok := c.endpoints[anchoreDetails.URL]
+	if !ok {
+		ep = &endpoint{}
+		c.endpoints[anchoreDetails.URL] = ep
+	}
+	return ep
+}
+
+func capabilitiesForVersion(ver *Version) Capabilities {
+	if ver == nil || ver.API.Version == "2" {
+		return Capabilities{InventoryAPIPath: InventoryAPIPathV2}
+	}
+	// If we can't parse the version, we'll assume it's v1 as 4.X does not include the version in the API version response
+	return Capabilities{InventoryAPIPath: InventoryAPIPathV1}
+}
+
+func newHTTPClient(httpConfig config.HTTPConfig) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(httpConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: anchoreDetails.HTTP.Insecure},
-	} // #nosec G402
+		Proxy:           newProxyFunc(httpConfig),
+		TLSClientConfig: tlsConfig,
+	}
 
 	client := &http.Client{
 		Transport: tr,
-		Timeout:   time.Duration(anchoreDetails.HTTP.TimeoutSeconds) * time.Second,
+		Timeout:   time.Duration(httpConfig.TimeoutSeconds) * time.Second,
 	}
 	gock.InterceptClient(client) // Required to use gock for testing custom client
 
-	return client
+	return client, nil
+}
+
+// newTokenHTTPClient returns the HTTP client for an OAuth2 token endpoint, which is usually not served by Anchore: it
+// verifies the certificate of the endpoint with the system roots and uses the proxy from the environment, but none of
+// the TLS, proxy or header settings for Anchore
+func newTokenHTTPClient(timeoutSeconds int) (*http.Client, error) {
+	return newHTTPClient(config.HTTPConfig{TimeoutSeconds: timeoutSeconds})
 }
 
 func getURL(anchoreDetails config.AnchoreInfo, path string, id string) (string, error) {
@@ -138,19 +360,23 @@ func getURL(anchoreDetails config.AnchoreInfo, path string, id string) (string,
 	return anchoreURL.String(), nil
 }
 
-func getPostRequest(anchoreDetails config.AnchoreInfo, endpointURL string, reqBody []byte, operation string) (*http.Request, error) {
-	request, err := http.NewRequest("POST", endpointURL, bytes.NewBuffer(reqBody))
+func getRequest(method string, auth Authenticator, anchoreDetails config.AnchoreInfo, endpointURL string, reqBody []byte, operation string) (*http.Request, error) {
+	request, err := http.NewRequest(method, endpointURL, bytes.NewBuffer(reqBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare %s request to Anchore: %w", operation, err)
 	}
 
-	request.SetBasicAuth(anchoreDetails.User, anchoreDetails.Password)
+	// custom headers are set first, so that they cannot replace the ones required by Anchore
+	setHeaders(request, anchoreDetails.HTTP)
+	if err := auth.Authenticate(request); err != nil {
+		return nil, fmt.Errorf("failed to authenticate %s request to Anchore: %w", operation, err)
+	}
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("x-anchore-account", anchoreDetails.Account)
 	return request, nil
 }
 
-func doPost(client *http.Client, request *http.Request, operation string) (*[]byte, error) {
+func doRequest(client *http.Client, request *http.Request, operation string) (*[]byte, error) {
 	response, err := client.Do(request)
 	if err != nil {
 		return nil, err
@@ -170,7 +396,12 @@ func checkHTTPErrors(response *http.Response, operation string) error {
 	switch {
 	case response.StatusCode >= 400 && response.StatusCode <= 599:
 		msg := fmt.Sprintf("%s response from Anchore (during %s)", response.Status, operation)
-		log.Errorf(msg)
+		if response.StatusCode == http.StatusNotFound {
+			// expected while negotiating the API version or checking the account, the caller decides if it is an error
+			log.Debug(msg)
+		} else {
+			log.Errorf(msg)
+		}
 
 		respBody, _ := getBody(response, operation)
 		if respBody == nil {
@@ -182,7 +413,7 @@ func checkHTTPErrors(response *http.Response, operation string) error {
 		// errorMsg information in the response will be either an APIErrorDetails or a ControllerErrorDetails
 		apiError := APIErrorDetails{}
 		err := json.Unmarshal(*respBody, &apiError)
-		if err == nil {
+		if err == nil && (apiError.Message != "" || apiError.HTTPCode != 0) {
 			return &APIClientError{Message: msg, Path: response.Request.URL.Path, Method: response.Request.Method,
 				Body: nil, HTTPStatusCode: response.StatusCode, APIErrorDetails: &apiError}
 		}
@@ -284,6 +515,16 @@ func ServerLacksAgentHealthAPISupport(err error) bool {
 	return false
 }
 
+// IntegrationNotFound is true if Anchore does not know the integration, e.g. after Anchore was restored from a backup
+// or the integration was deleted, as opposed to Anchore not supporting the integration APIs at all
+func IntegrationNotFound(err error) bool {
+	var apiClientError *APIClientError
+	if errors.As(err, &apiClientError) {
+		return apiClientError.HTTPStatusCode == http.StatusNotFound && !ServerLacksAgentHealthAPISupport(err)
+	}
+	return false
+}
+
 func UserLacksAPIPrivileges(err error) bool {
 	var apiClientError *APIClientError
 
@@ -11,6 +11,7 @@ import (
 	"os"
 	"syscall"
 	"testing"
+	"time"
 )
 
 type httpError struct {
@@ -436,6 +437,37 @@ func TestPost(t *testing.T) {
 	}
 }
 
+func TestPut(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://ancho.re").
+		Put("v2/system/integrations/1234/status").
+		MatchType("json").
+		BodyString(`{"state":"DEACTIVATED"}`).
+		Reply(200).
+		JSON(map[string]interface{}{})
+
+	result, err := Put([]byte(`{"state":"DEACTIVATED"}`), "1234", "v2/system/integrations/{{id}}/status", anchoreDetails,
+		"integration lifecycle update")
+	assert.NoError(t, err)
+	assert.NotNil(t, result)
+	assert.True(t, gock.IsDone())
+}
+
+func TestGet(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://ancho.re").
+		Get("v2/system/integrations/1234").
+		Reply(200).
+		JSON(map[string]interface{}{"uuid": "1234"})
+
+	result, err := Get("1234", "v2/system/integrations/{{id}}", anchoreDetails, "integration get")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"uuid":"1234"}`, string(*result))
+	assert.True(t, gock.IsDone())
+}
+
 func TestGetUrl(t *testing.T) {
 	type args struct {
 		anchoreDetails config.AnchoreInfo
@@ -534,7 +566,9 @@ func TestGetPostRequest(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := getPostRequest(anchoreDetails, tt.args.url, tt.args.reqBody, "register integration")
+			auth, err := NewClient().getAuthenticator(anchoreDetails)
+			assert.NoError(t, err)
+			result, err := getRequest(http.MethodPost, auth, anchoreDetails, tt.args.url, tt.args.reqBody, "register integration")
 			if tt.want != nil {
 				assert.Nil(t, result)
 				assert.Error(t, err, tt.want)
@@ -548,6 +582,48 @@ func TestGetPostRequest(t *testing.T) {
 	}
 }
 
+func TestGetPostRequestAuthorization(t *testing.T) {
+	tests := []struct {
+		name           string
+		anchoreDetails config.AnchoreInfo
+		want           string
+	}{
+		{
+			name:           "basic auth with user and password",
+			anchoreDetails: anchoreDetails,
+			want:           "Basic YWRtaW46Zm9vYmFy",
+		},
+		{
+			name: "bearer auth with token",
+			anchoreDetails: config.AnchoreInfo{
+				URL:   "https://ancho.re",
+				Token: "my-token",
+			},
+			want: "Bearer my-token",
+		},
+		{
+			name: "bearer auth with api key takes precedence over token and password",
+			anchoreDetails: config.AnchoreInfo{
+				URL:      "https://ancho.re",
+				User:     "admin",
+				Password: "foobar",
+				APIKey:   "my-api-key",
+				Token:    "my-token",
+			},
+			want: "Bearer my-api-key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := NewClient().getAuthenticator(tt.anchoreDetails)
+			assert.NoError(t, err)
+			result, err := getRequest(http.MethodPost, auth, tt.anchoreDetails, "https://ancho.re/v2/kubernetes-inventory", nil, "inventory report")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, result.Header.Get("Authorization"))
+		})
+	}
+}
+
 func TestAnchoreIsOffline(t *testing.T) {
 	tests := []struct {
 		name string
@@ -653,6 +729,50 @@ func TestAnchoreLacksAgentHealthAPISupport(t *testing.T) {
 	}
 }
 
+func TestIntegrationNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "AnchoreAPIClientError with 404 http_status for unknown integration returns true",
+			err: &APIClientError{
+				HTTPStatusCode: http.StatusNotFound,
+				Message:        "404 Not Found response from Anchore (during health report)",
+				Path:           "/v2/system/integrations/1234/health-report",
+				Method:         "POST",
+				APIErrorDetails: &APIErrorDetails{
+					Message:  "Integration not found",
+					HTTPCode: http.StatusNotFound,
+				},
+			},
+			want: true,
+		},
+		{
+			name: "AnchoreAPIClientError with 404 http_status for unknown URL returns false",
+			err:  &urlNotFoundError,
+			want: false,
+		},
+		{
+			name: "AnchoreAPIClientError with 401 http_status returns false",
+			err:  &unAuthorizedError,
+			want: false,
+		},
+		{
+			name: "Other errorMsg returns false",
+			err:  errOther,
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := IntegrationNotFound(tt.err)
+			assert.Equal(t, tt.want, result)
+		})
+	}
+}
+
 func TestUserLacksAPIPrivileges(t *testing.T) {
 	tests := []struct {
 		name string
@@ -712,3 +832,57 @@ func TestIncorrectCredentials(t *testing.T) {
 		})
 	}
 }
+
+func TestClientCapabilities(t *testing.T) {
+	defer gock.Off()
+
+	client := NewClient()
+	otherAnchoreDetails := anchoreDetails
+	otherAnchoreDetails.URL = "https://other.ancho.re"
+
+	// the latest API is assumed until the version has been negotiated
+	assert.Equal(t, InventoryAPIPathV2, client.Capabilities(anchoreDetails).InventoryAPIPath)
+
+	gock.New("https://ancho.re").
+		Get("/version").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"api":     map[string]interface{}{},
+			"db":      map[string]interface{}{"schema_version": "400"},
+			"service": map[string]interface{}{"version": "4.8.0"},
+		})
+	_, err := client.GetVersion(anchoreDetails)
+	assert.NoError(t, err)
+
+	// the negotiated version is cached per URL
+	assert.Equal(t, InventoryAPIPathV1, client.Capabilities(anchoreDetails).InventoryAPIPath)
+	assert.Equal(t, InventoryAPIPathV2, client.Capabilities(otherAnchoreDetails).InventoryAPIPath)
+
+	client.Reset()
+	assert.Equal(t, InventoryAPIPathV2, client.Capabilities(anchoreDetails).InventoryAPIPath)
+}
+
+func TestClientReusesHTTPClient(t *testing.T) {
+	client := NewClient()
+
+	first, err := client.getClient(anchoreDetails)
+	assert.NoError(t, err)
+	same, err := client.getClient(anchoreDetails)
+	assert.NoError(t, err)
+	assert.Same(t, first, same)
+
+	// a different URL gets its own HTTP client
+	otherAnchoreDetails := anchoreDetails
+	otherAnchoreDetails.URL = "https://other.ancho.re"
+	other, err := client.getClient(otherAnchoreDetails)
+	assert.NoError(t, err)
+	assert.NotSame(t, first, other)
+
+	// changing the HTTP configuration for a URL replaces its HTTP client
+	changedAnchoreDetails := anchoreDetails
+	changedAnchoreDetails.HTTP.TimeoutSeconds = 42
+	changed, err := client.getClient(changedAnchoreDetails)
+	assert.NoError(t, err)
+	assert.NotSame(t, first, changed)
+	assert.Equal(t, 42*time.Second, changed.Timeout)
+}
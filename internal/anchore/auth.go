@@ -0,0 +1,156 @@
+package anchore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/internal/log"
+)
+
+// Access tokens are refreshed this long before they expire, so that a request is never sent with a token that
+// expires while it is in flight
+const tokenRefreshWindow = 30 * time.Second
+
+// Authenticator adds credentials to the requests sent to Anchore
+type Authenticator interface {
+	Authenticate(request *http.Request) error
+	// Invalidate discards any cached credentials after Anchore rejected them. It returns true if new credentials
+	// will be obtained for the next request, i.e. if it is worth retrying the request.
+	Invalidate() bool
+}
+
+type basicAuthenticator struct {
+	user     string
+	password string
+}
+
+func (a basicAuthenticator) Authenticate(request *http.Request) error {
+	request.SetBasicAuth(a.user, a.password)
+	return nil
+}
+
+func (a basicAuthenticator) Invalidate() bool {
+	return false
+}
+
+type bearerAuthenticator struct {
+	token string
+}
+
+func (a bearerAuthenticator) Authenticate(request *http.Request) error {
+	request.Header.Set("Authorization", "Bearer "+a.token)
+	return nil
+}
+
+func (a bearerAuthenticator) Invalidate() bool {
+	return false
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// oauth2Authenticator obtains access tokens using the OAuth2 client credentials grant and caches them until
+// shortly before they expire
+type oauth2Authenticator struct {
+	mu         sync.Mutex
+	config     config.OAuth2Config
+	httpClient *http.Client
+	now        func() time.Time
+	token      string
+	expiry     time.Time
+}
+
+func newOAuth2Authenticator(oauth2Config config.OAuth2Config, httpClient *http.Client) *oauth2Authenticator {
+	return &oauth2Authenticator{
+		config:     oauth2Config,
+		httpClient: httpClient,
+		now:        time.Now,
+	}
+}
+
+func (a *oauth2Authenticator) Authenticate(request *http.Request) error {
+	token, err := a.getToken()
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (a *oauth2Authenticator) Invalidate() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.token = ""
+	return true
+}
+
+func (a *oauth2Authenticator) getToken() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && (a.expiry.IsZero() || a.now().Add(tokenRefreshWindow).Before(a.expiry)) {
+		return a.token, nil
+	}
+
+	log.Debugf("Requesting access token from %s", a.config.TokenURL)
+	token, err := a.requestToken()
+	if err != nil {
+		return "", err
+	}
+
+	a.token = token.AccessToken
+	a.expiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		a.expiry = a.now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return a.token, nil
+}
+
+func (a *oauth2Authenticator) requestToken() (*tokenResponse, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(a.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(a.config.Scopes, " "))
+	}
+
+	request, err := http.NewRequest("POST", a.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare access token request: %w", err)
+	}
+	request.SetBasicAuth(url.QueryEscape(a.config.ClientID), url.QueryEscape(a.config.ClientSecret))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := a.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request access token: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access token response: %w", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to request access token: %s response from %s", response.Status, a.config.TokenURL)
+	}
+
+	token := tokenResponse{}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("failed to parse access token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("access token response from %s did not contain an access token", a.config.TokenURL)
+	}
+	return &token, nil
+}
@@ -0,0 +1,177 @@
+package anchore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTokenServer returns a token endpoint that hands out a new access token (token-1, token-2, ...) for every
+// request made with the expected client credentials
+func newTokenServer(t *testing.T, expiresIn int64) (*httptest.Server, *int32) {
+	var issued int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok || clientID != "k8s-inventory" || clientSecret != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+		assert.Equal(t, "inventory health", r.Form.Get("scope"))
+
+		n := atomic.AddInt32(&issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(tokenResponse{
+			AccessToken: fmt.Sprintf("token-%d", n),
+			TokenType:   "Bearer",
+			ExpiresIn:   expiresIn,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &issued
+}
+
+func oauth2Config(tokenURL string) config.OAuth2Config {
+	return config.OAuth2Config{
+		TokenURL:     tokenURL,
+		ClientID:     "k8s-inventory",
+		ClientSecret: "s3cr3t",
+		Scopes:       []string{"inventory", "health"},
+	}
+}
+
+func TestOAuth2AuthenticatorCachesAndRefreshesToken(t *testing.T) {
+	tokenServer, issued := newTokenServer(t, 300)
+
+	now := time.Date(2024, 10, 4, 10, 11, 12, 0, time.UTC)
+	auth := newOAuth2Authenticator(oauth2Config(tokenServer.URL), tokenServer.Client())
+	auth.now = func() time.Time { return now }
+
+	authenticate := func() string {
+		request, err := http.NewRequest("POST", "https://ancho.re/v2/kubernetes-inventory", nil)
+		assert.NoError(t, err)
+		assert.NoError(t, auth.Authenticate(request))
+		return request.Header.Get("Authorization")
+	}
+
+	assert.Equal(t, "Bearer token-1", authenticate())
+
+	// the token is cached while it is valid
+	now = now.Add(4 * time.Minute)
+	assert.Equal(t, "Bearer token-1", authenticate())
+	assert.Equal(t, int32(1), atomic.LoadInt32(issued))
+
+	// the token is refreshed shortly before it expires
+	now = now.Add(40 * time.Second)
+	assert.Equal(t, "Bearer token-2", authenticate())
+
+	// invalidating the token forces a new one to be requested
+	assert.True(t, auth.Invalidate())
+	assert.Equal(t, "Bearer token-3", authenticate())
+	assert.Equal(t, int32(3), atomic.LoadInt32(issued))
+}
+
+func TestOAuth2AuthenticatorTokenRequestFailure(t *testing.T) {
+	tokenServer, _ := newTokenServer(t, 300)
+
+	badConfig := oauth2Config(tokenServer.URL)
+	badConfig.ClientSecret = "wrong"
+	auth := newOAuth2Authenticator(badConfig, tokenServer.Client())
+
+	request, err := http.NewRequest("POST", "https://ancho.re/v2/kubernetes-inventory", nil)
+	assert.NoError(t, err)
+	err = auth.Authenticate(request)
+	assert.ErrorContains(t, err, "401 Unauthorized")
+	assert.Empty(t, request.Header.Get("Authorization"))
+}
+
+func TestClientPostRetriesWithFreshTokenOn401(t *testing.T) {
+	tokenServer, issued := newTokenServer(t, 0)
+
+	var received []string
+	anchoreServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("Authorization"))
+		// the first token is rejected as if it had been revoked
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer anchoreServer.Close()
+
+	details := config.AnchoreInfo{
+		URL:     anchoreServer.URL,
+		OAuth2:  oauth2Config(tokenServer.URL),
+		Account: "admin",
+		HTTP:    config.HTTPConfig{TimeoutSeconds: 10},
+	}
+	client := NewClient()
+
+	_, err := client.Post([]byte(`{}`), "", "v2/system/integrations/registration", details, "integration registration")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bearer token-1", "Bearer token-2"}, received)
+
+	// the fresh token is reused for subsequent requests
+	_, err = client.Post([]byte(`{}`), "", "v2/kubernetes-inventory", details, "inventory report")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(issued))
+}
+
+func TestClientPostDoesNotRetryStaticCredentialsOn401(t *testing.T) {
+	requests := 0
+	anchoreServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer anchoreServer.Close()
+
+	details := config.AnchoreInfo{
+		URL:      anchoreServer.URL,
+		User:     "admin",
+		Password: "wrong",
+		Account:  "admin",
+		HTTP:     config.HTTPConfig{TimeoutSeconds: 10},
+	}
+
+	_, err := NewClient().Post([]byte(`{}`), "", "v2/kubernetes-inventory", details, "inventory report")
+	assert.True(t, IncorrectCredentials(err))
+	assert.Equal(t, 1, requests)
+}
+
+func TestOAuth2TokenEndpointDoesNotUseAnchoreHTTPConfig(t *testing.T) {
+	tokenServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(tokenResponse{AccessToken: "token-1"})
+	}))
+	defer tokenServer.Close()
+
+	details := config.AnchoreInfo{
+		URL:    "https://ancho.re",
+		OAuth2: oauth2Config(tokenServer.URL),
+		HTTP: config.HTTPConfig{
+			TimeoutSeconds: 10,
+			Insecure:       true,
+			ServerName:     "anchore.internal",
+			ProxyURL:       "http://proxy.internal:3128",
+		},
+	}
+	auth, err := NewClient().getAuthenticator(details)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 10*time.Second, auth.(*oauth2Authenticator).httpClient.Timeout)
+
+	// the certificate of the token endpoint is verified even though certificate checks are disabled for Anchore
+	request, err := http.NewRequest(http.MethodPost, details.URL, nil)
+	assert.NoError(t, err)
+	assert.ErrorContains(t, auth.Authenticate(request), "certificate")
+}
@@ -0,0 +1,40 @@
+package anchore
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+)
+
+// newProxyFunc returns the proxy selection for connections to Anchore. The environment (HTTPS_PROXY, HTTP_PROXY
+// and NO_PROXY) is used unless it is overridden by the proxy-url and no-proxy configuration.
+func newProxyFunc(httpConfig config.HTTPConfig) func(*http.Request) (*url.URL, error) {
+	if httpConfig.ProxyURL == "" && len(httpConfig.NoProxy) == 0 {
+		return http.ProxyFromEnvironment
+	}
+
+	proxyConfig := httpproxy.FromEnvironment()
+	if httpConfig.ProxyURL != "" {
+		proxyConfig.HTTPProxy = httpConfig.ProxyURL
+		proxyConfig.HTTPSProxy = httpConfig.ProxyURL
+	}
+	if len(httpConfig.NoProxy) > 0 {
+		proxyConfig.NoProxy = strings.Join(httpConfig.NoProxy, ",")
+	}
+
+	proxyFunc := proxyConfig.ProxyFunc()
+	return func(request *http.Request) (*url.URL, error) {
+		return proxyFunc(request.URL)
+	}
+}
+
+// setHeaders adds the custom headers from the HTTP configuration to a request to Anchore
+func setHeaders(request *http.Request, httpConfig config.HTTPConfig) {
+	for name, value := range httpConfig.Headers {
+		request.Header.Set(name, value)
+	}
+}
@@ -0,0 +1,94 @@
+package anchore
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+)
+
+// newTLSConfig builds the TLS configuration for connections to Anchore from the HTTP configuration. Certificates
+// can be given inline (base64 encoded PEM) or as files; files are read every time the configuration is built.
+func newTLSConfig(httpConfig config.HTTPConfig) (*tls.Config, error) {
+	minVersion, err := httpConfig.TLSMinVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: httpConfig.Insecure, // #nosec G402
+		MinVersion:         minVersion,
+		ServerName:         httpConfig.ServerName,
+	}
+
+	caPEM, err := readPEM(httpConfig.CACert, httpConfig.CACertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	if len(caPEM) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("failed to parse CA certificate: no PEM encoded certificates found")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	certPEM, err := readPEM(httpConfig.ClientCert, httpConfig.ClientCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client certificate: %w", err)
+	}
+	keyPEM, err := readPEM(httpConfig.ClientKey, httpConfig.ClientKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client key: %w", err)
+	}
+	if len(certPEM) > 0 || len(keyPEM) > 0 {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// readPEM returns the PEM data from the base64 encoded value or, if it is empty, from the file
+func readPEM(base64Value, file string) ([]byte, error) {
+	if base64Value != "" {
+		decoded, err := base64.StdEncoding.DecodeString(base64Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to base64 decode: %w", err)
+		}
+		return decoded, nil
+	}
+	if file != "" {
+		return os.ReadFile(file)
+	}
+	return nil, nil
+}
+
+// tlsFileModTimes returns the modification time of each certificate file referenced by the HTTP configuration,
+// so that changes to them (e.g. certificate rotation) can be detected
+func tlsFileModTimes(httpConfig config.HTTPConfig) map[string]time.Time {
+	modTimes := make(map[string]time.Time)
+	for _, file := range []string{httpConfig.CACertFile, httpConfig.ClientCertFile, httpConfig.ClientKeyFile} {
+		if file == "" {
+			continue
+		}
+		// a missing file is recorded with a zero time, so that its creation is also noticed
+		info, err := os.Stat(file)
+		if err == nil {
+			modTimes[file] = info.ModTime()
+		} else {
+			modTimes[file] = time.Time{}
+		}
+	}
+	return modTimes
+}
@@ -0,0 +1,174 @@
+package anchore
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert, isCA bool) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		DNSNames:              []string{name},
+	}
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// newMTLSServer starts an Anchore stand-in that only accepts clients presenting a certificate signed by the CA.
+// It responds with the common name of the client certificate.
+func newMTLSServer(t *testing.T, ca *testCert, serverName string) *httptest.Server {
+	serverCert := newTestCert(t, serverName, ca, false)
+	keyPair, err := tls.X509KeyPair(serverCert.certPEM, serverCert.keyPEM)
+	require.NoError(t, err)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"client": "` + r.TLS.PeerCertificates[0].Subject.CommonName + `"}`))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, true)
+	client := newTestCert(t, "client", ca, false)
+
+	tlsConfig, err := newTLSConfig(config.HTTPConfig{
+		CACert:        base64.StdEncoding.EncodeToString(ca.certPEM),
+		ClientCert:    base64.StdEncoding.EncodeToString(client.certPEM),
+		ClientKey:     base64.StdEncoding.EncodeToString(client.keyPEM),
+		MinTLSVersion: "1.3",
+		ServerName:    "anchore.internal",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+	assert.Equal(t, "anchore.internal", tlsConfig.ServerName)
+	assert.NotNil(t, tlsConfig.RootCAs)
+	assert.Len(t, tlsConfig.Certificates, 1)
+
+	_, err = newTLSConfig(config.HTTPConfig{CACert: "not base64!"})
+	assert.ErrorContains(t, err, "failed to read CA certificate")
+
+	_, err = newTLSConfig(config.HTTPConfig{CACert: base64.StdEncoding.EncodeToString([]byte("not a certificate"))})
+	assert.ErrorContains(t, err, "no PEM encoded certificates found")
+
+	_, err = newTLSConfig(config.HTTPConfig{ClientCertFile: filepath.Join(t.TempDir(), "missing.crt")})
+	assert.ErrorContains(t, err, "failed to read client certificate")
+}
+
+func TestClientMTLSWithCertificateRotation(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, true)
+	server := newMTLSServer(t, ca, "anchore.internal")
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	modTime := time.Now().Add(-time.Minute)
+	first := newTestCert(t, "first-client", ca, false)
+	writeFile(t, caFile, ca.certPEM, modTime)
+	writeFile(t, certFile, first.certPEM, modTime)
+	writeFile(t, keyFile, first.keyPEM, modTime)
+
+	details := config.AnchoreInfo{
+		URL:      server.URL,
+		User:     "admin",
+		Password: "foobar",
+		Account:  "admin",
+		HTTP: config.HTTPConfig{
+			TimeoutSeconds: 10,
+			CACertFile:     caFile,
+			ClientCertFile: certFile,
+			ClientKeyFile:  keyFile,
+			// the server certificate is issued for a name that does not match the URL (127.0.0.1)
+			ServerName: "anchore.internal",
+		},
+	}
+	client := NewClient()
+
+	response, err := client.Post([]byte(`{}`), "", "v2/kubernetes-inventory", details, "inventory report")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"client": "first-client"}`, string(*response))
+
+	// simulate the certificate being rotated on disk
+	second := newTestCert(t, "second-client", ca, false)
+	writeFile(t, certFile, second.certPEM, modTime.Add(30*time.Second))
+	writeFile(t, keyFile, second.keyPEM, modTime.Add(30*time.Second))
+
+	response, err = client.Post([]byte(`{}`), "", "v2/kubernetes-inventory", details, "inventory report")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"client": "second-client"}`, string(*response))
+
+	// without the SNI override the server certificate does not match
+	details.HTTP.ServerName = ""
+	_, err = client.Post([]byte(`{}`), "", "v2/kubernetes-inventory", details, "inventory report")
+	assert.Error(t, err)
+}
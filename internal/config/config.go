@@ -10,8 +10,10 @@ are listed below in order of precedence:
 */package config
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"path"
 	"strings"
 
@@ -23,6 +25,7 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/anchore/k8s-inventory/internal"
+	"github.com/anchore/k8s-inventory/internal/matcher"
 	"github.com/anchore/k8s-inventory/pkg/mode"
 )
 
@@ -32,6 +35,7 @@ const redacted = "******"
 type CliOnlyOptions struct {
 	ConfigPath string
 	Verbosity  int
+	DryRun     bool
 }
 
 // All Application configurations
@@ -60,12 +64,76 @@ type Application struct {
 	MetadataCollection              MetadataCollection    `mapstructure:"metadata-collection" json:"metadata-collection,omitempty" yaml:"metadata-collection"`
 	AnchoreDetails                  AnchoreInfo           `mapstructure:"anchore" json:"anchore,omitempty" yaml:"anchore"`
 	VerboseInventoryReports         bool                  `mapstructure:"verbose-inventory-reports" json:"verbose-inventory-reports,omitempty" yaml:"verbose-inventory-reports"`
+	Server                          ServerConfig          `mapstructure:"server" json:"server,omitempty" yaml:"server"`
+	Tracing                         TracingConfig         `mapstructure:"tracing" json:"tracing,omitempty" yaml:"tracing"`
+	LeaderElection                  LeaderElectionConfig  `mapstructure:"leader-election" json:"leader-election,omitempty" yaml:"leader-election"`
+	Sharding                        ShardingConfig        `mapstructure:"sharding" json:"sharding,omitempty" yaml:"sharding"`
+	AgentStatus                     AgentStatusConfig     `mapstructure:"agent-status" json:"agent-status,omitempty" yaml:"agent-status"`
+	localKeys                       map[string]bool       // remote settings that are set locally, and override the remote configuration
+}
+
+// ServerConfig configures the HTTP server for the health probes and metrics, only started in periodic mode
+type ServerConfig struct {
+	Enabled                bool   `mapstructure:"enabled" json:"enabled,omitempty" yaml:"enabled"`
+	ListenAddress          string `mapstructure:"listen-address" json:"listen-address,omitempty" yaml:"listen-address"`
+	ShutdownTimeoutSeconds int    `mapstructure:"shutdown-timeout-seconds" json:"shutdown-timeout-seconds,omitempty" yaml:"shutdown-timeout-seconds"`
+}
+
+// TracingConfig configures the export of OpenTelemetry traces over OTLP/HTTP. When Endpoint is empty, the standard
+// OTEL_EXPORTER_OTLP_* environment variables are used.
+type TracingConfig struct {
+	Enabled     bool    `mapstructure:"enabled" json:"enabled,omitempty" yaml:"enabled"`
+	Endpoint    string  `mapstructure:"endpoint" json:"endpoint,omitempty" yaml:"endpoint"`
+	SampleRatio float64 `mapstructure:"sample-ratio" json:"sample-ratio,omitempty" yaml:"sample-ratio"`
+	ServiceName string  `mapstructure:"service-name" json:"service-name,omitempty" yaml:"service-name"`
+}
+
+// LeaderElectionConfig configures the election of a leader among the replicas of the agent with a Lease, so that only
+// the leader collects and reports the inventory. When LeaseNamespace is empty, the namespace the agent runs in
+// (POD_NAMESPACE) is used.
+type LeaderElectionConfig struct {
+	Enabled              bool   `mapstructure:"enabled" json:"enabled,omitempty" yaml:"enabled"`
+	LeaseName            string `mapstructure:"lease-name" json:"lease-name,omitempty" yaml:"lease-name"`
+	LeaseNamespace       string `mapstructure:"lease-namespace" json:"lease-namespace,omitempty" yaml:"lease-namespace"`
+	LeaseDurationSeconds int    `mapstructure:"lease-duration-seconds" json:"lease-duration-seconds,omitempty" yaml:"lease-duration-seconds"`
+	RenewDeadlineSeconds int    `mapstructure:"renew-deadline-seconds" json:"renew-deadline-seconds,omitempty" yaml:"renew-deadline-seconds"`
+	RetryPeriodSeconds   int    `mapstructure:"retry-period-seconds" json:"retry-period-seconds,omitempty" yaml:"retry-period-seconds"`
+}
+
+// ShardingConfig configures the split of the namespaces between the replicas of the agent, which discover each other
+// through a Lease per replica labeled with the Group. When LeaseNamespace is empty, the namespace the agent runs in
+// (POD_NAMESPACE) is used.
+type ShardingConfig struct {
+	Enabled              bool   `mapstructure:"enabled" json:"enabled,omitempty" yaml:"enabled"`
+	Group                string `mapstructure:"group" json:"group,omitempty" yaml:"group"`
+	LeaseNamespace       string `mapstructure:"lease-namespace" json:"lease-namespace,omitempty" yaml:"lease-namespace"`
+	LeaseDurationSeconds int    `mapstructure:"lease-duration-seconds" json:"lease-duration-seconds,omitempty" yaml:"lease-duration-seconds"`
+	RenewIntervalSeconds int    `mapstructure:"renew-interval-seconds" json:"renew-interval-seconds,omitempty" yaml:"renew-interval-seconds"`
+}
+
+// AgentStatusConfig configures how the state of the agent is surfaced in the cluster, as Events on the pod of the agent
+// and in a status ConfigMap. When Namespace is empty, the namespace the agent runs in (POD_NAMESPACE) is used.
+type AgentStatusConfig struct {
+	Events        bool   `mapstructure:"events" json:"events,omitempty" yaml:"events"`
+	ConfigMap     bool   `mapstructure:"config-map" json:"config-map,omitempty" yaml:"config-map"`
+	ConfigMapName string `mapstructure:"config-map-name" json:"config-map-name,omitempty" yaml:"config-map-name"`
+	Namespace     string `mapstructure:"namespace" json:"namespace,omitempty" yaml:"namespace"`
+}
+
+// IsEnabled returns whether the state of the agent is surfaced in the cluster at all
+func (agentStatus *AgentStatusConfig) IsEnabled() bool {
+	return agentStatus.Events || agentStatus.ConfigMap
 }
 
 type RegistrationOptions struct {
 	RegistrationID         string `mapstructure:"registration-id" json:"registration-id,omitempty" yaml:"registration-id"`
 	IntegrationName        string `mapstructure:"integration-name" json:"integration-name,omitempty" yaml:"integration-name"`
 	IntegrationDescription string `mapstructure:"integration-description" json:"integration-description,omitempty" yaml:"integration-description"`
+	// on shutdown, send a final health report and mark the integration as deactivated within the timeout
+	DeactivateOnShutdown       bool `mapstructure:"deactivate-on-shutdown" json:"deactivate-on-shutdown,omitempty" yaml:"deactivate-on-shutdown"`
+	DeactivationTimeoutSeconds int  `mapstructure:"deactivation-timeout-seconds" json:"deactivation-timeout-seconds,omitempty" yaml:"deactivation-timeout-seconds"`
+	// apply the settings that Anchore administrators set in the configuration of the integration, unless set locally
+	AcceptRemoteConfiguration bool `mapstructure:"accept-remote-configuration" json:"accept-remote-configuration,omitempty" yaml:"accept-remote-configuration"`
 }
 
 // MissingTagConf details the policy for handling missing tags when reporting images
@@ -79,14 +147,43 @@ type NamespaceSelector struct {
 	Include     []string `mapstructure:"include" json:"include,omitempty" yaml:"include"`
 	Exclude     []string `mapstructure:"exclude" json:"exclude,omitempty" yaml:"exclude"`
 	IgnoreEmpty bool     `mapstructure:"ignore-empty" json:"ignore-empty,omitempty" yaml:"ignore-empty"`
+
+	excludeMatcher *matcher.Matcher
 }
 
 type AccountRoutes map[string]AccountRouteDetails
 
 type AccountRouteDetails struct {
-	User       string   `mapstructure:"user" json:"user,omitempty" yaml:"user"`
-	Password   string   `mapstructure:"password" json:"password,omitempty" yaml:"password"`
-	Namespaces []string `mapstructure:"namespaces" json:"namespaces,omitempty" yaml:"namespaces"`
+	User              string         `mapstructure:"user" json:"user,omitempty" yaml:"user"`
+	Password          string         `mapstructure:"password" json:"password,omitempty" yaml:"password"`
+	PasswordFile      string         `mapstructure:"password-file" json:"password-file,omitempty" yaml:"password-file"`
+	PasswordSecretRef SecretRef      `mapstructure:"password-secret-ref" json:"password-secret-ref,omitempty" yaml:"password-secret-ref"`
+	APIKey            string         `mapstructure:"api-key" json:"api-key,omitempty" yaml:"api-key"`
+	Token             string         `mapstructure:"token" json:"token,omitempty" yaml:"token"`
+	TokenFile         string         `mapstructure:"token-file" json:"token-file,omitempty" yaml:"token-file"`
+	TokenSecretRef    SecretRef      `mapstructure:"token-secret-ref" json:"token-secret-ref,omitempty" yaml:"token-secret-ref"`
+	Exec              ExecCredential `mapstructure:"exec" json:"exec,omitempty" yaml:"exec"`
+	OAuth2            OAuth2Config   `mapstructure:"oauth2" json:"oauth2,omitempty" yaml:"oauth2"`
+	Namespaces        []string       `mapstructure:"namespaces" json:"namespaces,omitempty" yaml:"namespaces"`
+
+	namespaceMatcher *matcher.Matcher
+}
+
+// A command that prints the credentials for Anchore as JSON (user, password or token, and an optional expiry),
+// similar to kubectl exec credential plugins
+type ExecCredential struct {
+	Command        string            `mapstructure:"command" json:"command,omitempty" yaml:"command"`
+	Args           []string          `mapstructure:"args" json:"args,omitempty" yaml:"args"`
+	Env            map[string]string `mapstructure:"env" json:"env,omitempty" yaml:"env"`
+	TimeoutSeconds int               `mapstructure:"timeout-seconds" json:"timeout-seconds,omitempty" yaml:"timeout-seconds"`
+}
+
+// A reference to a key of a Kubernetes Secret holding a credential. If no namespace is given, the namespace the
+// agent runs in (POD_NAMESPACE) is used.
+type SecretRef struct {
+	Name      string `mapstructure:"name" json:"name,omitempty" yaml:"name"`
+	Namespace string `mapstructure:"namespace" json:"namespace,omitempty" yaml:"namespace"`
+	Key       string `mapstructure:"key" json:"key,omitempty" yaml:"key"`
 }
 
 type AccountRouteByNamespaceLabel struct {
@@ -112,6 +209,9 @@ type ResourceMetadata struct {
 	Annotations []string `mapstructure:"include-annotations" json:"include-annotations,omitempty" yaml:"include-annotations"`
 	Labels      []string `mapstructure:"include-labels" json:"include-labels,omitempty" yaml:"include-labels"`
 	Disable     bool     `mapstructure:"disable" json:"disable,omitempty" yaml:"disable"`
+
+	annotationMatcher *matcher.Matcher
+	labelMatcher      *matcher.Matcher
 }
 
 type MetadataCollection struct {
@@ -122,17 +222,46 @@ type MetadataCollection struct {
 
 // Information for posting in-use image details to Anchore (or any URL for that matter)
 type AnchoreInfo struct {
-	URL      string     `mapstructure:"url" json:"url,omitempty" yaml:"url"`
-	User     string     `mapstructure:"user" json:"user,omitempty" yaml:"user"`
-	Password string     `mapstructure:"password" json:"password,omitempty" yaml:"password"`
-	Account  string     `mapstructure:"account" json:"account,omitempty" yaml:"account"`
-	HTTP     HTTPConfig `mapstructure:"http" json:"http,omitempty" yaml:"http"`
+	URL               string         `mapstructure:"url" json:"url,omitempty" yaml:"url"`
+	User              string         `mapstructure:"user" json:"user,omitempty" yaml:"user"`
+	Password          string         `mapstructure:"password" json:"password,omitempty" yaml:"password"`
+	PasswordFile      string         `mapstructure:"password-file" json:"password-file,omitempty" yaml:"password-file"`
+	PasswordSecretRef SecretRef      `mapstructure:"password-secret-ref" json:"password-secret-ref,omitempty" yaml:"password-secret-ref"`
+	APIKey            string         `mapstructure:"api-key" json:"api-key,omitempty" yaml:"api-key"`
+	Token             string         `mapstructure:"token" json:"token,omitempty" yaml:"token"`
+	TokenFile         string         `mapstructure:"token-file" json:"token-file,omitempty" yaml:"token-file"`
+	TokenSecretRef    SecretRef      `mapstructure:"token-secret-ref" json:"token-secret-ref,omitempty" yaml:"token-secret-ref"`
+	Exec              ExecCredential `mapstructure:"exec" json:"exec,omitempty" yaml:"exec"`
+	OAuth2            OAuth2Config   `mapstructure:"oauth2" json:"oauth2,omitempty" yaml:"oauth2"`
+	Account           string         `mapstructure:"account" json:"account,omitempty" yaml:"account"`
+	HTTP              HTTPConfig     `mapstructure:"http" json:"http,omitempty" yaml:"http"`
+}
+
+// OAuth2 client credentials used to obtain access tokens for Anchore from a token endpoint
+type OAuth2Config struct {
+	TokenURL     string   `mapstructure:"token-url" json:"token-url,omitempty" yaml:"token-url"`
+	ClientID     string   `mapstructure:"client-id" json:"client-id,omitempty" yaml:"client-id"`
+	ClientSecret string   `mapstructure:"client-secret" json:"client-secret,omitempty" yaml:"client-secret"`
+	Scopes       []string `mapstructure:"scopes" json:"scopes,omitempty" yaml:"scopes"`
 }
 
 // Configurations for the HTTP Client itself (net/http)
 type HTTPConfig struct {
-	Insecure       bool `mapstructure:"insecure" json:"insecure,omitempty" yaml:"insecure"`
-	TimeoutSeconds int  `mapstructure:"timeout-seconds" json:"timeout-seconds,omitempty" yaml:"timeout-seconds"`
+	Insecure       bool   `mapstructure:"insecure" json:"insecure,omitempty" yaml:"insecure"`
+	TimeoutSeconds int    `mapstructure:"timeout-seconds" json:"timeout-seconds,omitempty" yaml:"timeout-seconds"`
+	CACert         string `mapstructure:"ca-cert" json:"ca-cert,omitempty" yaml:"ca-cert"`
+	CACertFile     string `mapstructure:"ca-cert-file" json:"ca-cert-file,omitempty" yaml:"ca-cert-file"`
+	ClientCert     string `mapstructure:"client-cert" json:"client-cert,omitempty" yaml:"client-cert"`
+	ClientCertFile string `mapstructure:"client-cert-file" json:"client-cert-file,omitempty" yaml:"client-cert-file"`
+	ClientKey      string `mapstructure:"client-key" json:"client-key,omitempty" yaml:"client-key"`
+	ClientKeyFile  string `mapstructure:"client-key-file" json:"client-key-file,omitempty" yaml:"client-key-file"`
+	MinTLSVersion  string `mapstructure:"min-tls-version" json:"min-tls-version,omitempty" yaml:"min-tls-version"`
+	ServerName     string `mapstructure:"server-name" json:"server-name,omitempty" yaml:"server-name"`
+	// Proxy to use instead of the one from the environment (HTTPS_PROXY, HTTP_PROXY). Proxy credentials can be
+	// included in the URL
+	ProxyURL string            `mapstructure:"proxy-url" json:"proxy-url,omitempty" yaml:"proxy-url"`
+	NoProxy  []string          `mapstructure:"no-proxy" json:"no-proxy,omitempty" yaml:"no-proxy"`
+	Headers  map[string]string `mapstructure:"headers" json:"headers,omitempty" yaml:"headers"`
 }
 
 // Logging Configuration
@@ -148,11 +277,98 @@ type Development struct {
 	ProfileCPU bool `mapstructure:"profile-cpu" json:"profile-cpu,omitempty" yaml:"profile-cpu"`
 }
 
-// Return whether or not AnchoreDetails are specified
+// Return whether or not AnchoreDetails are specified, either with a user and password, a bearer token or OAuth2
+// client credentials
 func (anchore *AnchoreInfo) IsValid() bool {
 	return anchore.URL != "" &&
-		anchore.User != "" &&
-		anchore.Password != ""
+		((anchore.User != "" && anchore.Password != "") || anchore.BearerToken() != "" || anchore.OAuth2.IsValid())
+}
+
+// Return the minimum TLS version to use when connecting to Anchore (defaults to TLS 1.2)
+func (httpConfig *HTTPConfig) TLSMinVersion() (uint16, error) {
+	switch httpConfig.MinTLSVersion {
+	case "":
+		return tls.VersionTLS12, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("anchore.http.min-tls-version must be one of [1.0 1.1 1.2 1.3], got %q", httpConfig.MinTLSVersion)
+	}
+}
+
+// Return whether or not OAuth2 client credentials are specified
+func (oauth2 *OAuth2Config) IsValid() bool {
+	return oauth2.TokenURL != "" && oauth2.ClientID != "" && oauth2.ClientSecret != ""
+}
+
+// Return the API key or token to send as a bearer token, if one is specified (the API key takes precedence)
+func (anchore *AnchoreInfo) BearerToken() string {
+	if anchore.APIKey != "" {
+		return anchore.APIKey
+	}
+	return anchore.Token
+}
+
+// ApplyTo replaces the credentials of the Anchore details with the credentials of the account route, keeping the URL
+// and HTTP configuration of the Anchore details
+func (aRD AccountRouteDetails) ApplyTo(anchoreDetails *AnchoreInfo) {
+	anchoreDetails.User = aRD.User
+	anchoreDetails.Password = aRD.Password
+	anchoreDetails.PasswordFile = aRD.PasswordFile
+	anchoreDetails.PasswordSecretRef = aRD.PasswordSecretRef
+	anchoreDetails.APIKey = aRD.APIKey
+	anchoreDetails.Token = aRD.Token
+	anchoreDetails.TokenFile = aRD.TokenFile
+	anchoreDetails.TokenSecretRef = aRD.TokenSecretRef
+	anchoreDetails.Exec = aRD.Exec
+	anchoreDetails.OAuth2 = aRD.OAuth2
+}
+
+// Return whether or not a credential command is specified
+func (execCredential *ExecCredential) IsSet() bool {
+	return execCredential.Command != ""
+}
+
+// Return an error if the credential command is combined with another source of a password or token
+func validateExecCredential(name string, execCredential ExecCredential, passwordOrTokenSources ...string) error {
+	if !execCredential.IsSet() {
+		return nil
+	}
+	for _, source := range passwordOrTokenSources {
+		if source != "" {
+			return fmt.Errorf("%s.exec cannot be combined with a password or token", name)
+		}
+	}
+	return nil
+}
+
+// Return whether or not the Secret reference is specified
+func (ref *SecretRef) IsSet() bool {
+	return ref.Name != ""
+}
+
+// Return an error if a credential is given in more than one way (inline, from a file or from a Secret) or if its
+// Secret reference is incomplete
+func validateCredentialSource(name, value, file string, ref SecretRef) error {
+	sources := 0
+	for _, isSet := range []bool{value != "", file != "", ref.IsSet()} {
+		if isSet {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return fmt.Errorf("only one of %s, %s-file and %s-secret-ref can be specified", name, name, name)
+	}
+	if ref.IsSet() && ref.Key == "" {
+		return fmt.Errorf("%s-secret-ref must specify the key of Secret %s", name, ref.Name)
+	}
+	return nil
 }
 
 func setNonCliDefaultValues(v *viper.Viper) {
@@ -161,9 +377,35 @@ func setNonCliDefaultValues(v *viper.Viper) {
 	v.SetDefault("log.structured", false)
 	v.SetDefault("dev.profile-cpu", false)
 	v.SetDefault("anchore.account", "admin")
+	v.SetDefault("anchore.api-key", "")
+	v.SetDefault("anchore.token", "")
+	v.SetDefault("anchore.password-file", "")
+	v.SetDefault("anchore.token-file", "")
+	v.SetDefault("anchore.password-secret-ref.name", "")
+	v.SetDefault("anchore.password-secret-ref.namespace", "")
+	v.SetDefault("anchore.password-secret-ref.key", "")
+	v.SetDefault("anchore.token-secret-ref.name", "")
+	v.SetDefault("anchore.token-secret-ref.namespace", "")
+	v.SetDefault("anchore.token-secret-ref.key", "")
+	v.SetDefault("anchore.exec.command", "")
+	v.SetDefault("anchore.exec.args", []string{})
+	v.SetDefault("anchore.exec.timeout-seconds", 0)
+	v.SetDefault("anchore.oauth2.token-url", "")
+	v.SetDefault("anchore.oauth2.client-id", "")
+	v.SetDefault("anchore.oauth2.client-secret", "")
 	v.SetDefault("kubeconfig.anchore.account", "admin")
 	v.SetDefault("anchore.http.insecure", false)
 	v.SetDefault("anchore.http.timeout-seconds", 10)
+	v.SetDefault("anchore.http.ca-cert", "")
+	v.SetDefault("anchore.http.ca-cert-file", "")
+	v.SetDefault("anchore.http.client-cert", "")
+	v.SetDefault("anchore.http.client-cert-file", "")
+	v.SetDefault("anchore.http.client-key", "")
+	v.SetDefault("anchore.http.client-key-file", "")
+	v.SetDefault("anchore.http.min-tls-version", "")
+	v.SetDefault("anchore.http.server-name", "")
+	v.SetDefault("anchore.http.proxy-url", "")
+	v.SetDefault("anchore.http.no-proxy", []string{})
 	v.SetDefault("kubernetes-request-timeout-seconds", -1)
 	v.SetDefault("kubernetes.request-timeout-seconds", 60)
 	v.SetDefault("kubernetes.request-batch-size", 100)
@@ -179,12 +421,51 @@ func setNonCliDefaultValues(v *viper.Viper) {
 	v.SetDefault("namespace-selectors.include", []string{})
 	v.SetDefault("namespace-selectors.exclude", []string{})
 	v.SetDefault("namespace-selectors.ignore-empty", false)
+	v.SetDefault("server.enabled", false)
+	v.SetDefault("server.listen-address", ":8080")
+	v.SetDefault("server.shutdown-timeout-seconds", 10)
+	v.SetDefault("anchore-registration.deactivate-on-shutdown", true)
+	v.SetDefault("anchore-registration.deactivation-timeout-seconds", 10)
+	v.SetDefault("anchore-registration.accept-remote-configuration", true)
+	v.SetDefault("tracing.enabled", false)
+	v.SetDefault("tracing.endpoint", "")
+	v.SetDefault("tracing.sample-ratio", 1.0)
+	v.SetDefault("tracing.service-name", "anchore-k8s-inventory")
+	v.SetDefault("leader-election.enabled", false)
+	v.SetDefault("leader-election.lease-name", "anchore-k8s-inventory")
+	v.SetDefault("leader-election.lease-namespace", "")
+	v.SetDefault("leader-election.lease-duration-seconds", 15)
+	v.SetDefault("leader-election.renew-deadline-seconds", 10)
+	v.SetDefault("leader-election.retry-period-seconds", 2)
+	v.SetDefault("sharding.enabled", false)
+	v.SetDefault("sharding.group", "anchore-k8s-inventory")
+	v.SetDefault("sharding.lease-namespace", "")
+	v.SetDefault("sharding.lease-duration-seconds", 30)
+	v.SetDefault("sharding.renew-interval-seconds", 10)
+	v.SetDefault("agent-status.events", false)
+	v.SetDefault("agent-status.config-map", false)
+	v.SetDefault("agent-status.config-map-name", "anchore-k8s-inventory-status")
+	v.SetDefault("agent-status.namespace", "")
 }
 
 // Load the Application Configuration from the Viper specifications
 func LoadConfigFromFile(v *viper.Viper, cliOpts *CliOnlyOptions) (*Application, error) {
-	// the user may not have a config, and this is OK, we can use the default config + default cobra cli values instead
-	setNonCliDefaultValues(v)
+	config, err := Load(v, cliOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.Build()
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return config, nil
+}
+
+// Load reads the Application Configuration from the config file, the environment variables, the flags and the
+// defaults, without building it
+func Load(v *viper.Viper, cliOpts *CliOnlyOptions) (*Application, error) {
 	cfgPath := ""
 	if cliOpts != nil {
 		cfgPath = cliOpts.ConfigPath
@@ -193,6 +474,9 @@ func LoadConfigFromFile(v *viper.Viper, cliOpts *CliOnlyOptions) (*Application,
 	if err != nil && cfgPath != "" {
 		return nil, err
 	}
+	localKeys := locallySetKeys(v)
+	// the user may not have a config, and this is OK, we can use the default config + default cobra cli values instead
+	setNonCliDefaultValues(v)
 
 	config := &Application{
 		CliOptions: *cliOpts,
@@ -202,11 +486,7 @@ func LoadConfigFromFile(v *viper.Viper, cliOpts *CliOnlyOptions) (*Application,
 		return nil, fmt.Errorf("unable to parse config: %w", err)
 	}
 	config.ConfigPath = v.ConfigFileUsed()
-
-	err = config.Build()
-	if err != nil {
-		return nil, fmt.Errorf("invalid config: %w", err)
-	}
+	config.localKeys = localKeys
 
 	return config, nil
 }
@@ -266,10 +546,134 @@ func (cfg *Application) Build() error {
 
 	cfg.handleBackwardsCompatibility()
 
+	if _, err := cfg.AnchoreDetails.HTTP.TLSMinVersion(); err != nil {
+		return err
+	}
+
+	hasClientCert := cfg.AnchoreDetails.HTTP.ClientCert != "" || cfg.AnchoreDetails.HTTP.ClientCertFile != ""
+	hasClientKey := cfg.AnchoreDetails.HTTP.ClientKey != "" || cfg.AnchoreDetails.HTTP.ClientKeyFile != ""
+	if hasClientCert != hasClientKey {
+		return fmt.Errorf("anchore.http client certificate and client key must be specified together")
+	}
+
+	if err := cfg.validateCredentialSources(); err != nil {
+		return err
+	}
+
+	if cfg.AnchoreDetails.HTTP.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.AnchoreDetails.HTTP.ProxyURL)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return fmt.Errorf("anchore.http.proxy-url must be an absolute URL, e.g. http://proxy.example.com:3128")
+		}
+	}
+
+	if cfg.Server.Enabled && cfg.Server.ListenAddress == "" {
+		return fmt.Errorf("server.listen-address is required when the server is enabled")
+	}
+
+	if cfg.Registration.DeactivateOnShutdown && cfg.Registration.DeactivationTimeoutSeconds <= 0 {
+		return fmt.Errorf("anchore-registration.deactivation-timeout-seconds must be greater than 0 when deactivate-on-shutdown is enabled")
+	}
+
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("tracing.sample-ratio must be between 0 and 1")
+	}
+
+	if err := cfg.LeaderElection.validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Sharding.validate(); err != nil {
+		return err
+	}
+
+	if cfg.LeaderElection.Enabled && cfg.Sharding.Enabled {
+		return fmt.Errorf("leader-election and sharding cannot be enabled together")
+	}
+
+	if cfg.AgentStatus.ConfigMap && cfg.AgentStatus.ConfigMapName == "" {
+		return fmt.Errorf("agent-status.config-map-name is required when agent-status.config-map is enabled")
+	}
+
 	if cfg.HealthReportIntervalSeconds < 30 || cfg.HealthReportIntervalSeconds > 600 {
 		return fmt.Errorf("health-report-interval-seconds must be between 30 and 600")
 	}
 
+	if found := cfg.compileMatchers(); len(found) > 0 {
+		return &invalidPatternsError{problems: found}
+	}
+
+	return nil
+}
+
+func (leaderElection *LeaderElectionConfig) validate() error {
+	if !leaderElection.Enabled {
+		return nil
+	}
+	if leaderElection.LeaseName == "" {
+		return fmt.Errorf("leader-election.lease-name is required when leader election is enabled")
+	}
+	if leaderElection.RetryPeriodSeconds <= 0 {
+		return fmt.Errorf("leader-election.retry-period-seconds must be greater than 0")
+	}
+	// the retries are jittered by up to 20%, which must still leave time to renew the lease
+	if float64(leaderElection.RenewDeadlineSeconds) <= 1.2*float64(leaderElection.RetryPeriodSeconds) {
+		return fmt.Errorf("leader-election.renew-deadline-seconds must be greater than 1.2 times retry-period-seconds")
+	}
+	if leaderElection.LeaseDurationSeconds <= leaderElection.RenewDeadlineSeconds {
+		return fmt.Errorf("leader-election.lease-duration-seconds must be greater than renew-deadline-seconds")
+	}
+	return nil
+}
+
+func (sharding *ShardingConfig) validate() error {
+	if !sharding.Enabled {
+		return nil
+	}
+	if sharding.Group == "" {
+		return fmt.Errorf("sharding.group is required when sharding is enabled")
+	}
+	if sharding.RenewIntervalSeconds <= 0 {
+		return fmt.Errorf("sharding.renew-interval-seconds must be greater than 0")
+	}
+	if sharding.LeaseDurationSeconds <= sharding.RenewIntervalSeconds {
+		return fmt.Errorf("sharding.lease-duration-seconds must be greater than renew-interval-seconds")
+	}
+	return nil
+}
+
+func (cfg *Application) validateCredentialSources() error {
+	anchore := cfg.AnchoreDetails
+	if err := validateCredentialSource("anchore.password", anchore.Password, anchore.PasswordFile, anchore.PasswordSecretRef); err != nil {
+		return err
+	}
+	if err := validateCredentialSource("anchore.token", anchore.Token, anchore.TokenFile, anchore.TokenSecretRef); err != nil {
+		return err
+	}
+	if err := validateExecCredential("anchore", anchore.Exec, anchore.Password, anchore.PasswordFile,
+		anchore.PasswordSecretRef.Name, anchore.Token, anchore.TokenFile, anchore.TokenSecretRef.Name); err != nil {
+		return err
+	}
+	for account, route := range cfg.AccountRoutes {
+		prefix := fmt.Sprintf("account-routes.%s.", account)
+		if err := validateCredentialSource(prefix+"password", route.Password, route.PasswordFile, route.PasswordSecretRef); err != nil {
+			return err
+		}
+		if err := validateCredentialSource(prefix+"token", route.Token, route.TokenFile, route.TokenSecretRef); err != nil {
+			return err
+		}
+		if err := validateExecCredential("account-routes."+account, route.Exec, route.Password, route.PasswordFile,
+			route.PasswordSecretRef.Name, route.Token, route.TokenFile, route.TokenSecretRef.Name); err != nil {
+			return err
+		}
+	}
+	user := cfg.KubeConfig.User
+	if user.Token != "" && user.TokenFile != "" {
+		return fmt.Errorf("only one of kubeconfig.user.token and kubeconfig.user.token-file can be specified")
+	}
+	if user.PrivateKey != "" && user.PrivateKeyFile != "" {
+		return fmt.Errorf("only one of kubeconfig.user.private-key and kubeconfig.user.private-key-file can be specified")
+	}
 	return nil
 }
 
@@ -367,6 +771,12 @@ func (anchore AnchoreInfo) MarshalJSON() ([]byte, error) {
 	if aIA.Password != "" {
 		aIA.Password = redacted
 	}
+	if aIA.APIKey != "" {
+		aIA.APIKey = redacted
+	}
+	if aIA.Token != "" {
+		aIA.Token = redacted
+	}
 	return json.Marshal(aIA)
 }
 
@@ -374,6 +784,12 @@ func (anchore AnchoreInfo) MarshalYAML() (interface{}, error) {
 	if anchore.Password != "" {
 		anchore.Password = redacted
 	}
+	if anchore.APIKey != "" {
+		anchore.APIKey = redacted
+	}
+	if anchore.Token != "" {
+		anchore.Token = redacted
+	}
 	return anchore, nil
 }
 
@@ -384,6 +800,12 @@ func (aRD AccountRouteDetails) MarshalJSON() ([]byte, error) {
 	if aRDA.Password != "" {
 		aRDA.Password = redacted
 	}
+	if aRDA.APIKey != "" {
+		aRDA.APIKey = redacted
+	}
+	if aRDA.Token != "" {
+		aRDA.Token = redacted
+	}
 	return json.Marshal(aRDA)
 }
 
@@ -391,5 +813,101 @@ func (aRD AccountRouteDetails) MarshalYAML() (interface{}, error) {
 	if aRD.Password != "" {
 		aRD.Password = redacted
 	}
+	if aRD.APIKey != "" {
+		aRD.APIKey = redacted
+	}
+	if aRD.Token != "" {
+		aRD.Token = redacted
+	}
 	return aRD, nil
 }
+
+func (oauth2 OAuth2Config) MarshalJSON() ([]byte, error) {
+	type oauth2ConfigAlias OAuth2Config // prevent recursion
+
+	oCA := oauth2ConfigAlias(oauth2)
+	if oCA.ClientSecret != "" {
+		oCA.ClientSecret = redacted
+	}
+	return json.Marshal(oCA)
+}
+
+func (oauth2 OAuth2Config) MarshalYAML() (interface{}, error) {
+	if oauth2.ClientSecret != "" {
+		oauth2.ClientSecret = redacted
+	}
+	return oauth2, nil
+}
+
+func (execCredential ExecCredential) MarshalJSON() ([]byte, error) {
+	type execCredentialAlias ExecCredential // prevent recursion
+
+	eCA := execCredentialAlias(execCredential.redact())
+	return json.Marshal(eCA)
+}
+
+func (execCredential ExecCredential) MarshalYAML() (interface{}, error) {
+	return execCredential.redact(), nil
+}
+
+// redact returns a copy of the credential command with the arguments and the environment variable values (which
+// may carry tokens or roles for the secret manager) redacted. The names of flag-style arguments are kept.
+func (execCredential ExecCredential) redact() ExecCredential {
+	if len(execCredential.Args) > 0 {
+		args := make([]string, len(execCredential.Args))
+		for i, arg := range execCredential.Args {
+			switch {
+			case !strings.HasPrefix(arg, "-"):
+				args[i] = redacted
+			case strings.Contains(arg, "="):
+				args[i] = arg[:strings.Index(arg, "=")+1] + redacted
+			default:
+				args[i] = arg
+			}
+		}
+		execCredential.Args = args
+	}
+	if len(execCredential.Env) > 0 {
+		env := make(map[string]string, len(execCredential.Env))
+		for name := range execCredential.Env {
+			env[name] = redacted
+		}
+		execCredential.Env = env
+	}
+	return execCredential
+}
+
+func (httpConfig HTTPConfig) MarshalJSON() ([]byte, error) {
+	type httpConfigAlias HTTPConfig // prevent recursion
+
+	hCA := httpConfigAlias(httpConfig.redact())
+	return json.Marshal(hCA)
+}
+
+func (httpConfig HTTPConfig) MarshalYAML() (interface{}, error) {
+	return httpConfig.redact(), nil
+}
+
+// redact returns a copy of the HTTP configuration with the client key, the proxy password and the header values
+// (which may carry tokens) redacted
+func (httpConfig HTTPConfig) redact() HTTPConfig {
+	if httpConfig.ClientKey != "" {
+		httpConfig.ClientKey = redacted
+	}
+	if proxyURL, err := url.Parse(httpConfig.ProxyURL); err == nil && proxyURL.User != nil {
+		if _, ok := proxyURL.User.Password(); ok {
+			// built by hand, as url.URL would escape the redacted password
+			userInfo := url.User(proxyURL.User.Username()).String() + ":" + redacted + "@"
+			proxyURL.User = nil
+			httpConfig.ProxyURL = strings.Replace(proxyURL.String(), "://", "://"+userInfo, 1)
+		}
+	}
+	if len(httpConfig.Headers) > 0 {
+		headers := make(map[string]string, len(httpConfig.Headers))
+		for name := range httpConfig.Headers {
+			headers[name] = redacted
+		}
+		httpConfig.Headers = headers
+	}
+	return httpConfig
+}
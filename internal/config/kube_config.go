@@ -26,6 +26,9 @@ type KubeConfUser struct {
 	ClientCert   string `mapstructure:"client-cert" json:"client-cert,omitempty" yaml:"client-cert"`
 	PrivateKey   string `mapstructure:"private-key" json:"private-key,omitempty" yaml:"private-key"`
 	Token        string `mapstructure:"token" json:"token,omitempty" yaml:"token"`
+	// Files are read by the Kubernetes client, which picks up changes to them (e.g. rotated tokens)
+	PrivateKeyFile string `mapstructure:"private-key-file" json:"private-key-file,omitempty" yaml:"private-key-file"`
+	TokenFile      string `mapstructure:"token-file" json:"token-file,omitempty" yaml:"token-file"`
 }
 
 func (user KubeConfUser) MarshalJSON() ([]byte, error) {
@@ -62,9 +65,9 @@ func (kubeConf *KubeConf) IsNonFileKubeConfigValid() bool {
 func (user *KubeConfUser) isValid() bool {
 	switch user.UserConfType {
 	case PrivateKey:
-		return user.ClientCert != "" && user.PrivateKey != ""
+		return user.ClientCert != "" && (user.PrivateKey != "" || user.PrivateKeyFile != "")
 	case ServiceAccountToken:
-		return user.Token != ""
+		return user.Token != "" || user.TokenFile != ""
 	default:
 		return true
 	}
@@ -115,6 +118,14 @@ func (kubeConf *KubeConf) getAuthInfosFromConf() (map[string]*api.AuthInfo, erro
 			return nil, fmt.Errorf("failed to base64 decode client cert: %w", err)
 		}
 
+		if userConf.PrivateKeyFile != "" {
+			authInfos[cluster] = &api.AuthInfo{
+				ClientCertificateData: decodedClientCert,
+				ClientKey:             userConf.PrivateKeyFile,
+			}
+			break
+		}
+
 		decodedPrivateKey, err := base64.StdEncoding.DecodeString(userConf.PrivateKey)
 		if err != nil {
 			return nil, fmt.Errorf("failed to base64 decode private key: %w", err)
@@ -126,7 +137,8 @@ func (kubeConf *KubeConf) getAuthInfosFromConf() (map[string]*api.AuthInfo, erro
 		}
 	case ServiceAccountToken:
 		authInfos[cluster] = &api.AuthInfo{
-			Token: kubeConf.User.Token,
+			Token:     kubeConf.User.Token,
+			TokenFile: kubeConf.User.TokenFile,
 		}
 	}
 	return authInfos, nil
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/anchore/k8s-inventory/internal/matcher"
+)
+
+// invalidPatternsError is the error of Build when regular expressions of the configuration do not compile
+type invalidPatternsError struct {
+	problems problems
+}
+
+func (e *invalidPatternsError) Error() string {
+	return e.problems[0].String()
+}
+
+// compileMatchers compiles the regular expressions of the namespace selectors, account routes and metadata
+// collection once, for the inventory and routing to reuse, and returns the problems with those that do not compile
+func (cfg *Application) compileMatchers() problems {
+	found := problems{}
+	compile := func(key string, patterns []string, newMatcher func([]string) (*matcher.Matcher, error)) *matcher.Matcher {
+		m, err := newMatcher(patterns)
+		invalid := &matcher.InvalidPatternError{}
+		if errors.As(err, &invalid) {
+			found.add(fmt.Sprintf("%s[%d]", key, invalid.Index), "%v", invalid)
+		}
+		return m
+	}
+
+	cfg.NamespaceSelectors.excludeMatcher = compile("namespace-selectors.exclude", cfg.NamespaceSelectors.Exclude, matcher.NewNamespaces)
+
+	// the routes are copied rather than updated in place, the map may be shared with a copy of the configuration
+	accountRoutes := make(AccountRoutes, len(cfg.AccountRoutes))
+	for _, account := range cfg.accountRouteNames() {
+		route := cfg.AccountRoutes[account]
+		route.namespaceMatcher = compile("account-routes."+account+".namespaces", route.Namespaces, matcher.New)
+		accountRoutes[account] = route
+	}
+	if cfg.AccountRoutes != nil {
+		cfg.AccountRoutes = accountRoutes
+	}
+
+	for resource, metadata := range map[string]*ResourceMetadata{
+		"nodes":      &cfg.MetadataCollection.Nodes,
+		"namespaces": &cfg.MetadataCollection.Namespace,
+		"pods":       &cfg.MetadataCollection.Pods,
+	} {
+		metadata.annotationMatcher = compile("metadata-collection."+resource+".include-annotations", metadata.Annotations, matcher.New)
+		metadata.labelMatcher = compile("metadata-collection."+resource+".include-labels", metadata.Labels, matcher.New)
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Key < found[j].Key })
+	return found
+}
+
+// ExcludeMatcher returns the namespaces to exclude, either by name or by regular expression, as compiled by Build
+func (selector NamespaceSelector) ExcludeMatcher() *matcher.Matcher {
+	return selector.excludeMatcher
+}
+
+// NamespaceMatcher returns the regular expressions of the namespaces routed to the account, as compiled by Build
+func (aRD AccountRouteDetails) NamespaceMatcher() *matcher.Matcher {
+	return aRD.namespaceMatcher
+}
+
+// AnnotationMatcher returns the regular expressions of the annotations to include, as compiled by Build
+func (metadata ResourceMetadata) AnnotationMatcher() *matcher.Matcher {
+	return metadata.annotationMatcher
+}
+
+// LabelMatcher returns the regular expressions of the labels to include, as compiled by Build
+func (metadata ResourceMetadata) LabelMatcher() *matcher.Matcher {
+	return metadata.labelMatcher
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildCompilesMatchers(t *testing.T) {
+	configPath := writeTestConfig(t, `
+namespace-selectors:
+  exclude:
+    - kube-system
+    - "^openshift-.*"
+account-routes:
+  team-a:
+    namespaces:
+      - "team-a-.*"
+metadata-collection:
+  pods:
+    include-labels:
+      - "^app"
+`)
+	cfg, err := LoadConfigFromFile(viper.New(), &CliOnlyOptions{ConfigPath: configPath})
+	require.NoError(t, err)
+
+	// the matchers are compiled once by Build, not on every call
+	excludes := cfg.NamespaceSelectors.ExcludeMatcher()
+	assert.Same(t, excludes, cfg.NamespaceSelectors.ExcludeMatcher())
+	assert.True(t, excludes.MatchString("kube-system"))
+	assert.False(t, excludes.MatchString("kube-system-2"))
+	assert.True(t, excludes.MatchString("openshift-console"))
+
+	namespaces := cfg.AccountRoutes["team-a"].NamespaceMatcher()
+	assert.Same(t, namespaces, cfg.AccountRoutes["team-a"].NamespaceMatcher())
+	assert.True(t, namespaces.MatchString("team-a-prod"))
+
+	assert.True(t, cfg.MetadataCollection.Pods.LabelMatcher().MatchString("app.kubernetes.io/name"))
+	assert.True(t, cfg.MetadataCollection.Pods.AnnotationMatcher().Empty())
+}
+
+func TestBuildInvalidPattern(t *testing.T) {
+	configPath := writeTestConfig(t, `
+namespace-selectors:
+  exclude:
+    - kube-system
+    - "kube-("
+metadata-collection:
+  nodes:
+    include-annotations:
+      - "*"
+`)
+	_, err := LoadConfigFromFile(viper.New(), &CliOnlyOptions{ConfigPath: configPath})
+	assert.EqualError(t, err, "invalid config: metadata-collection.nodes.include-annotations[0]: invalid regular expression \"*\": error parsing regexp: missing argument to repetition operator: `*`")
+}
+
+func TestCompileMatchersCopiesAccountRoutes(t *testing.T) {
+	routes := AccountRoutes{"team-a": {Namespaces: []string{"team-a-.*"}}}
+	cfg := &Application{AccountRoutes: routes}
+	require.Empty(t, cfg.compileMatchers())
+
+	// a copy of the configuration sharing the routes is not modified
+	assert.Nil(t, routes["team-a"].namespaceMatcher)
+	assert.NotNil(t, cfg.AccountRoutes["team-a"].namespaceMatcher)
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+)
+
+// remoteSetting sets a configuration setting from a value received from Anchore
+type remoteSetting func(cfg *Application, value interface{}) error
+
+// setting returns a remoteSetting that decodes the value into the field of the configuration
+func setting[T any](field func(cfg *Application) *T) remoteSetting {
+	return func(cfg *Application, value interface{}) error {
+		var decoded T
+		if err := mapstructure.WeakDecode(value, &decoded); err != nil {
+			return err
+		}
+		*field(cfg) = decoded
+		return nil
+	}
+}
+
+// remoteSettings are the settings that Anchore administrators may set for a registered agent, in the configuration
+// of its integration, by key in the configuration file
+var remoteSettings = map[string]remoteSetting{
+	"polling-interval-seconds":                           setting(func(cfg *Application) *int { return &cfg.PollingIntervalSeconds }),
+	"namespace-selectors.include":                        setting(func(cfg *Application) *[]string { return &cfg.NamespaceSelectors.Include }),
+	"namespace-selectors.exclude":                        setting(func(cfg *Application) *[]string { return &cfg.NamespaceSelectors.Exclude }),
+	"namespace-selectors.ignore-empty":                   setting(func(cfg *Application) *bool { return &cfg.NamespaceSelectors.IgnoreEmpty }),
+	"metadata-collection.nodes.include-annotations":      setting(func(cfg *Application) *[]string { return &cfg.MetadataCollection.Nodes.Annotations }),
+	"metadata-collection.nodes.include-labels":           setting(func(cfg *Application) *[]string { return &cfg.MetadataCollection.Nodes.Labels }),
+	"metadata-collection.nodes.disable":                  setting(func(cfg *Application) *bool { return &cfg.MetadataCollection.Nodes.Disable }),
+	"metadata-collection.namespaces.include-annotations": setting(func(cfg *Application) *[]string { return &cfg.MetadataCollection.Namespace.Annotations }),
+	"metadata-collection.namespaces.include-labels":      setting(func(cfg *Application) *[]string { return &cfg.MetadataCollection.Namespace.Labels }),
+	"metadata-collection.namespaces.disable":             setting(func(cfg *Application) *bool { return &cfg.MetadataCollection.Namespace.Disable }),
+	"metadata-collection.pods.include-annotations":       setting(func(cfg *Application) *[]string { return &cfg.MetadataCollection.Pods.Annotations }),
+	"metadata-collection.pods.include-labels":            setting(func(cfg *Application) *[]string { return &cfg.MetadataCollection.Pods.Labels }),
+	"metadata-collection.pods.disable":                   setting(func(cfg *Application) *bool { return &cfg.MetadataCollection.Pods.Disable }),
+	"log.level":                                          setting(func(cfg *Application) *string { return &cfg.Log.Level }),
+}
+
+// locallySetKeys returns the remote settings that are set locally, in the configuration file, in environment
+// variables or with command line flags. It must be called before the defaults are set, since viper considers
+// settings with a default value to be set.
+func locallySetKeys(v *viper.Viper) map[string]bool {
+	local := make(map[string]bool)
+	for key := range remoteSettings {
+		if v.IsSet(key) {
+			local[key] = true
+		}
+	}
+	// the deprecated namespaces setting is translated into the include selector
+	if v.IsSet("namespaces") {
+		local["namespace-selectors.include"] = true
+	}
+	return local
+}
+
+// isSetLocally returns whether the remote setting is set locally, and must not be replaced by a remote value
+func (cfg *Application) isSetLocally(key string) bool {
+	if key == "log.level" && (cfg.Quiet || cfg.CliOptions.Verbosity > 0) {
+		return true
+	}
+	return cfg.localKeys[key]
+}
+
+// WithRemoteConfiguration returns a copy of the configuration with the remote configuration received from Anchore
+// applied to it, along with the keys of the remote settings that were ignored, either because they may not be set
+// remotely or because they are set locally. The configuration is left as is if any remote value is invalid.
+func (cfg *Application) WithRemoteConfiguration(remote map[string]interface{}) (*Application, []string, error) {
+	effective := *cfg
+	var ignored []string
+
+	flattened := make(map[string]interface{})
+	flattenRemoteConfiguration("", remote, flattened)
+	keys := make([]string, 0, len(flattened))
+	for key := range flattened {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		set, allowed := remoteSettings[key]
+		if !allowed || cfg.isSetLocally(key) {
+			ignored = append(ignored, key)
+			continue
+		}
+		if err := set(&effective, flattened[key]); err != nil {
+			return cfg, nil, fmt.Errorf("invalid remote value for %s: %w", key, err)
+		}
+		if key == "polling-interval-seconds" && effective.PollingIntervalSeconds <= 0 {
+			return cfg, nil, fmt.Errorf("invalid remote value for %s: must be greater than 0", key)
+		}
+	}
+
+	if err := effective.Build(); err != nil {
+		return cfg, nil, fmt.Errorf("invalid remote configuration: %w", err)
+	}
+	return &effective, ignored, nil
+}
+
+// flattenRemoteConfiguration flattens the nested remote configuration into keys separated by dots, as in the keys of
+// remoteSettings
+func flattenRemoteConfiguration(prefix string, remote map[string]interface{}, flattened map[string]interface{}) {
+	for key, value := range remote {
+		key = strings.ToLower(prefix + key)
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenRemoteConfiguration(key+".", nested, flattened)
+			continue
+		}
+		flattened[key] = value
+	}
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRemoteTestConfig() *Application {
+	return &Application{
+		PollingIntervalSeconds:      300,
+		HealthReportIntervalSeconds: 60,
+		MissingTagPolicy:            MissingTagConf{Policy: "digest"},
+		NamespaceSelectors: NamespaceSelector{
+			Exclude: []string{"kube-system"},
+		},
+		localKeys: map[string]bool{"namespace-selectors.exclude": true},
+	}
+}
+
+func TestWithRemoteConfiguration(t *testing.T) {
+	cfg := newRemoteTestConfig()
+
+	effective, ignored, err := cfg.WithRemoteConfiguration(map[string]interface{}{
+		"polling-interval-seconds": "600",
+		"namespace-selectors": map[string]interface{}{
+			"include":      []interface{}{"default", "prod-.*"},
+			"exclude":      []interface{}{},
+			"ignore-empty": true,
+		},
+		"metadata-collection": map[string]interface{}{
+			"pods": map[string]interface{}{
+				"include-labels": []interface{}{"app"},
+				"disable":        false,
+			},
+		},
+		"log": map[string]interface{}{
+			"level": "debug",
+		},
+		"anchore": map[string]interface{}{
+			"url": "https://attacker.example.com",
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 600, effective.PollingIntervalSeconds)
+	assert.Equal(t, []string{"default", "prod-.*"}, effective.NamespaceSelectors.Include)
+	assert.Equal(t, []string{"kube-system"}, effective.NamespaceSelectors.Exclude)
+	assert.True(t, effective.NamespaceSelectors.IgnoreEmpty)
+	assert.Equal(t, []string{"app"}, effective.MetadataCollection.Pods.Labels)
+	assert.Equal(t, logrus.DebugLevel, effective.Log.LevelOpt)
+	assert.Empty(t, effective.AnchoreDetails.URL)
+	assert.Equal(t, []string{"anchore.url", "namespace-selectors.exclude"}, ignored)
+
+	// the local configuration is left untouched
+	assert.Equal(t, 300, cfg.PollingIntervalSeconds)
+	assert.Empty(t, cfg.NamespaceSelectors.Include)
+	assert.Empty(t, cfg.Log.Level)
+}
+
+func TestWithRemoteConfigurationLogLevelFromCommandLine(t *testing.T) {
+	cfg := newRemoteTestConfig()
+	cfg.CliOptions.Verbosity = 2
+
+	effective, ignored, err := cfg.WithRemoteConfiguration(map[string]interface{}{
+		"log": map[string]interface{}{"level": "error"},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, effective.Log.Level)
+	assert.Equal(t, []string{"log.level"}, ignored)
+}
+
+func TestWithRemoteConfigurationInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote map[string]interface{}
+	}{
+		{
+			name:   "not a number",
+			remote: map[string]interface{}{"polling-interval-seconds": "often"},
+		},
+		{
+			name:   "not positive",
+			remote: map[string]interface{}{"polling-interval-seconds": 0},
+		},
+		{
+			name:   "unknown log level",
+			remote: map[string]interface{}{"log": map[string]interface{}{"level": "chatty"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newRemoteTestConfig()
+			effective, _, err := cfg.WithRemoteConfiguration(tt.remote)
+			assert.Error(t, err)
+			assert.Same(t, cfg, effective)
+		})
+	}
+}
+
+func TestLocallySetKeys(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+polling-interval-seconds: 60
+namespaces:
+  - default
+metadata-collection:
+  pods:
+    disable: true
+`), 0600))
+	t.Setenv("ANCHORE_K8S_INVENTORY_LOG_LEVEL", "info")
+
+	cfg, err := LoadConfigFromFile(viper.New(), &CliOnlyOptions{ConfigPath: configPath})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]bool{
+		"polling-interval-seconds":         true,
+		"namespace-selectors.include":      true,
+		"metadata-collection.pods.disable": true,
+		"log.level":                        true,
+	}, cfg.localKeys)
+}
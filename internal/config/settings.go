@@ -0,0 +1,170 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+
+	"github.com/anchore/k8s-inventory/internal"
+)
+
+// Sources of the values of the settings
+const (
+	SourceDefault = "default"
+	sourceEnv     = "env "
+	sourceFlag    = "flag --"
+	sourceFile    = "file "
+)
+
+// Setting is a setting of the effective configuration, with where its value comes from
+type Setting struct {
+	Key    string      `json:"key"`
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+}
+
+// envVarName returns the environment variable that sets the key, e.g. ANCHORE_K8S_INVENTORY_LOG_LEVEL for log.level
+func envVarName(key string) string {
+	return strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(internal.ApplicationName + "_" + key))
+}
+
+// sourceOf returns where the value of the key comes from, in the order of precedence of viper: a flag that was set
+// (changedFlags maps the keys to the names of the flags that were set), an environment variable, the config file or
+// the defaults
+func sourceOf(v *viper.Viper, key string, changedFlags map[string]string) string {
+	if flag, ok := changedFlags[key]; ok {
+		return sourceFlag + flag
+	}
+	if _, ok := os.LookupEnv(envVarName(key)); ok {
+		return sourceEnv + envVarName(key)
+	}
+	if v.InConfig(key) {
+		return sourceFile + v.ConfigFileUsed()
+	}
+	return SourceDefault
+}
+
+// Settings returns the settings of the effective configuration that was loaded with v, sorted by key, with the secrets
+// redacted and where the value of each setting comes from
+func (cfg *Application) Settings(v *viper.Viper, changedFlags map[string]string) ([]Setting, error) {
+	values, err := cfg.redactedValues()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := v.AllKeys()
+	sort.Strings(keys)
+	settings := make([]Setting, 0, len(keys))
+	for _, key := range keys {
+		value, ok := lookup(values, strings.Split(key, "."))
+		if !ok {
+			// not a setting of the configuration, e.g. a misspelled key
+			continue
+		}
+		if _, isMap := value.(map[string]interface{}); isMap && hasNestedKeys(keys, key) {
+			// the nested settings are listed on their own
+			continue
+		}
+		settings = append(settings, Setting{Key: key, Value: value, Source: sourceOf(v, key, changedFlags)})
+	}
+	return settings, nil
+}
+
+// redactedValues returns the values of the settings as unmarshaled YAML, with the secrets redacted
+func (cfg *Application) redactedValues() (map[interface{}]interface{}, error) {
+	marshaled, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	values := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(marshaled, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func hasNestedKeys(keys []string, key string) bool {
+	for _, other := range keys {
+		if strings.HasPrefix(other, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the value at the path of keys in the unmarshaled YAML, ignoring the case of the keys like viper does
+func lookup(values map[interface{}]interface{}, path []string) (interface{}, bool) {
+	for k, value := range values {
+		key, ok := k.(string)
+		if !ok || !strings.EqualFold(key, path[0]) {
+			continue
+		}
+		if len(path) == 1 {
+			return normalize(value), true
+		}
+		if nested, ok := value.(map[interface{}]interface{}); ok {
+			return lookup(nested, path[1:])
+		}
+	}
+	return nil, false
+}
+
+// normalize converts the maps of the unmarshaled YAML so that they can be marshaled to JSON
+func normalize(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		normalized := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			if key, ok := k.(string); ok {
+				normalized[key] = normalize(v)
+			}
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(value))
+		for i, v := range value {
+			normalized[i] = normalize(v)
+		}
+		return normalized
+	default:
+		return value
+	}
+}
+
+// WriteSettings writes the settings in the output format, table or json
+func WriteSettings(w io.Writer, settings []Setting, output string) error {
+	switch output {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(settings)
+	case "table", "":
+	default:
+		return fmt.Errorf("unsupported output format %q, must be one of [table json]", output)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+	for _, setting := range settings {
+		value := fmt.Sprint(setting.Value)
+		switch setting.Value.(type) {
+		case nil:
+			value = ""
+		case map[string]interface{}, []interface{}:
+			encoded, err := json.Marshal(setting.Value)
+			if err != nil {
+				return err
+			}
+			value = string(encoded)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", setting.Key, value, setting.Source)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettings(t *testing.T) {
+	configPath := writeTestConfig(t, `
+anchore:
+  url: https://anchore.example.com
+  password: foobar
+  http:
+    headers:
+      X-Waf-Token: secret
+namespace-selectors:
+  exclude:
+    - kube-system
+`)
+	t.Setenv("ANCHORE_K8S_INVENTORY_ANCHORE_ACCOUNT", "team-a")
+
+	v := viper.New()
+	cfg, err := LoadConfigFromFile(v, &CliOnlyOptions{ConfigPath: configPath})
+	require.NoError(t, err)
+	settings, err := cfg.Settings(v, map[string]string{"server.enabled": "enable-server"})
+	require.NoError(t, err)
+
+	byKey := map[string]Setting{}
+	for _, setting := range settings {
+		byKey[setting.Key] = setting
+	}
+	source := "file " + configPath
+	assert.Equal(t, Setting{Key: "anchore.url", Value: "https://anchore.example.com", Source: source}, byKey["anchore.url"])
+	assert.Equal(t, Setting{Key: "anchore.password", Value: redacted, Source: source}, byKey["anchore.password"])
+	assert.Equal(t, Setting{Key: "anchore.http.headers.x-waf-token", Value: redacted, Source: source}, byKey["anchore.http.headers.x-waf-token"])
+	assert.Equal(t, Setting{Key: "anchore.account", Value: "team-a", Source: "env ANCHORE_K8S_INVENTORY_ANCHORE_ACCOUNT"}, byKey["anchore.account"])
+	assert.Equal(t, Setting{Key: "namespace-selectors.exclude", Value: []interface{}{"kube-system"}, Source: source}, byKey["namespace-selectors.exclude"])
+	assert.Equal(t, Setting{Key: "server.enabled", Value: false, Source: "flag --enable-server"}, byKey["server.enabled"])
+	assert.Equal(t, Setting{Key: "health-report-interval-seconds", Value: 60, Source: SourceDefault}, byKey["health-report-interval-seconds"])
+	// the maps are listed by their nested settings
+	assert.NotContains(t, byKey, "anchore.http.headers")
+}
+
+func TestWriteSettings(t *testing.T) {
+	settings := []Setting{
+		{Key: "anchore.url", Value: "https://anchore.example.com", Source: "file config.yaml"},
+		{Key: "namespace-selectors.exclude", Value: []interface{}{"kube-system"}, Source: "env ANCHORE_K8S_INVENTORY_NAMESPACE_SELECTORS_EXCLUDE"},
+		{Key: "server.enabled", Value: false, Source: SourceDefault},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSettings(&buf, settings, "table"))
+	assert.Equal(t, `KEY                          VALUE                        SOURCE
+anchore.url                  https://anchore.example.com  file config.yaml
+namespace-selectors.exclude  ["kube-system"]              env ANCHORE_K8S_INVENTORY_NAMESPACE_SELECTORS_EXCLUDE
+server.enabled               false                        default
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteSettings(&buf, settings[:1], "json"))
+	assert.JSONEq(t, `[{"key": "anchore.url", "value": "https://anchore.example.com", "source": "file config.yaml"}]`, buf.String())
+
+	assert.ErrorContains(t, WriteSettings(&buf, settings, "xml"), "unsupported output format")
+}
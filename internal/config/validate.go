@@ -0,0 +1,162 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// kubeConfigInCluster is the kubeconfig path that selects the in-cluster configuration instead of a file
+const kubeConfigInCluster = "use-in-cluster"
+
+// Problem is a problem with the configuration, at the key of the setting it concerns if any
+type Problem struct {
+	Key     string `json:"key,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	switch {
+	case p.Key == "":
+		return p.Message
+	case p.Source == "":
+		return fmt.Sprintf("%s: %s", p.Key, p.Message)
+	default:
+		return fmt.Sprintf("%s (%s): %s", p.Key, p.Source, p.Message)
+	}
+}
+
+type problems []Problem
+
+func (p *problems) add(key, format string, args ...interface{}) {
+	*p = append(*p, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate loads the configuration like LoadConfigFromFile, and returns all the problems with it at once instead of
+// failing on the first one: the configuration does not build, a key is unknown, a regular expression does not
+// compile, an account route has no name, a URL does not parse or a file does not exist. changedFlags maps the keys
+// to the names of the flags that were set, to tell where the values with problems come from.
+func Validate(v *viper.Viper, cliOpts *CliOnlyOptions, changedFlags map[string]string) []Problem {
+	cfg, err := Load(v, cliOpts)
+	if err != nil {
+		return []Problem{{Message: err.Error()}}
+	}
+
+	found := problems{}
+	// the regular expressions are compiled last by Build, their problems are all listed below
+	invalidPatterns := &invalidPatternsError{}
+	if err := cfg.Build(); err != nil && !errors.As(err, &invalidPatterns) {
+		found.add("", "invalid config: %v", err)
+	}
+	found = append(found, cfg.unknownKeys(v)...)
+	found = append(found, cfg.compileMatchers()...)
+	found = append(found, cfg.validateAccountRouteNames()...)
+	found = append(found, cfg.validateURLs()...)
+	found = append(found, cfg.validateFiles()...)
+
+	for i := range found {
+		if found[i].Key != "" {
+			// the problems with an item of a list are at the index of the item, e.g. namespace-selectors.exclude[0]
+			key, _, _ := strings.Cut(found[i].Key, "[")
+			found[i].Source = sourceOf(v, key, changedFlags)
+		}
+	}
+	return found
+}
+
+// unknownKeys returns the keys of the config file that are not settings of the configuration
+func (cfg *Application) unknownKeys(v *viper.Viper) problems {
+	values, err := cfg.redactedValues()
+	if err != nil {
+		return problems{{Message: fmt.Sprintf("unable to read settings: %v", err)}}
+	}
+
+	found := problems{}
+	keys := v.AllKeys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, known := lookup(values, strings.Split(key, ".")); !known && v.InConfig(key) {
+			found.add(key, "unknown key")
+		}
+	}
+	return found
+}
+
+func (cfg *Application) accountRouteNames() []string {
+	accounts := make([]string, 0, len(cfg.AccountRoutes))
+	for account := range cfg.AccountRoutes {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	return accounts
+}
+
+// validateAccountRouteNames checks that the account routes are named after an account
+func (cfg *Application) validateAccountRouteNames() problems {
+	found := problems{}
+	for _, account := range cfg.accountRouteNames() {
+		if strings.TrimSpace(account) == "" {
+			found.add("account-routes", "account route name must not be empty")
+		}
+	}
+	return found
+}
+
+// validateURLs checks that the URLs are absolute
+func (cfg *Application) validateURLs() problems {
+	found := problems{}
+	check := func(key, value string) {
+		if value == "" {
+			return
+		}
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			found.add(key, "must be an absolute URL, e.g. https://anchore.example.com")
+		}
+	}
+
+	check("anchore.url", cfg.AnchoreDetails.URL)
+	check("anchore.oauth2.token-url", cfg.AnchoreDetails.OAuth2.TokenURL)
+	for _, account := range cfg.accountRouteNames() {
+		check("account-routes."+account+".oauth2.token-url", cfg.AccountRoutes[account].OAuth2.TokenURL)
+	}
+	check("kubeconfig.server", cfg.KubeConfig.Server)
+	check("tracing.endpoint", cfg.Tracing.Endpoint)
+	return found
+}
+
+// validateFiles checks that the files to read exist
+func (cfg *Application) validateFiles() problems {
+	found := problems{}
+	check := func(key, path string) {
+		if path == "" {
+			return
+		}
+		if _, err := os.Stat(path); err != nil {
+			found.add(key, "%v", err)
+		}
+	}
+
+	anchore := cfg.AnchoreDetails
+	check("anchore.password-file", anchore.PasswordFile)
+	check("anchore.token-file", anchore.TokenFile)
+	check("anchore.http.ca-cert-file", anchore.HTTP.CACertFile)
+	check("anchore.http.client-cert-file", anchore.HTTP.ClientCertFile)
+	check("anchore.http.client-key-file", anchore.HTTP.ClientKeyFile)
+	for _, account := range cfg.accountRouteNames() {
+		check("account-routes."+account+".password-file", cfg.AccountRoutes[account].PasswordFile)
+		check("account-routes."+account+".token-file", cfg.AccountRoutes[account].TokenFile)
+	}
+	if cfg.KubeConfig.Path != kubeConfigInCluster {
+		check("kubeconfig.path", cfg.KubeConfig.Path)
+	}
+	check("kubeconfig.user.private-key-file", cfg.KubeConfig.User.PrivateKeyFile)
+	check("kubeconfig.user.token-file", cfg.KubeConfig.User.TokenFile)
+	return found
+}
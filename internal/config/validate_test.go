@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestConfig(t *testing.T, content string) string {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0600))
+	return configPath
+}
+
+func TestValidate(t *testing.T) {
+	configPath := writeTestConfig(t, `
+log:
+  level: loud
+polling-intervl-seconds: 60
+anchore:
+  url: not a url
+  password-file: /does/not/exist
+namespace-selectors:
+  exclude:
+    - kube-system
+    - "kube-("
+account-routes:
+  team-a:
+    namespaces:
+      - "*team-a"
+metadata-collection:
+  pods:
+    include-labels:
+      - "app["
+`)
+	t.Setenv("ANCHORE_K8S_INVENTORY_TRACING_ENDPOINT", "otel-collector:4318")
+
+	problems := Validate(viper.New(), &CliOnlyOptions{ConfigPath: configPath}, nil)
+
+	source := "file " + configPath
+	assert.Equal(t, []Problem{
+		{Message: `invalid config: bad log level configured ("loud"): not a valid logrus Level: "loud"`},
+		{Key: "polling-intervl-seconds", Source: source, Message: "unknown key"},
+		{Key: "account-routes.team-a.namespaces[0]", Source: source, Message: "invalid regular expression \"*team-a\": error parsing regexp: missing argument to repetition operator: `*`"},
+		{Key: "metadata-collection.pods.include-labels[0]", Source: source, Message: "invalid regular expression \"app[\": error parsing regexp: missing closing ]: `[`"},
+		{Key: "namespace-selectors.exclude[1]", Source: source, Message: "invalid regular expression \"kube-(\": error parsing regexp: missing closing ): `kube-(`"},
+		{Key: "anchore.url", Source: source, Message: "must be an absolute URL, e.g. https://anchore.example.com"},
+		{Key: "tracing.endpoint", Source: "env ANCHORE_K8S_INVENTORY_TRACING_ENDPOINT", Message: "must be an absolute URL, e.g. https://anchore.example.com"},
+		{Key: "anchore.password-file", Source: source, Message: "stat /does/not/exist: no such file or directory"},
+	}, problems)
+}
+
+func TestValidateValid(t *testing.T) {
+	problems := Validate(viper.New(), &CliOnlyOptions{ConfigPath: "../../anchore-k8s-inventory.yaml"}, nil)
+	assert.Empty(t, problems)
+}
+
+func TestValidateMissingConfig(t *testing.T) {
+	problems := Validate(viper.New(), &CliOnlyOptions{ConfigPath: "/does/not/exist.yaml"}, nil)
+	assert.Equal(t, []Problem{{Message: "unable to read config: /does/not/exist.yaml"}}, problems)
+}
+
+func TestProblem_String(t *testing.T) {
+	assert.Equal(t, "invalid config", Problem{Message: "invalid config"}.String())
+	assert.Equal(t, "anchore.url: must be an absolute URL", Problem{Key: "anchore.url", Message: "must be an absolute URL"}.String())
+	assert.Equal(t, "anchore.url (env ANCHORE_K8S_INVENTORY_ANCHORE_URL): must be an absolute URL",
+		Problem{Key: "anchore.url", Source: "env ANCHORE_K8S_INVENTORY_ANCHORE_URL", Message: "must be an absolute URL"}.String())
+}
@@ -0,0 +1,169 @@
+// Package healtherrors collects the collection and delivery failures of the agent so that they can be surfaced to
+// Anchore in the health reports. Errors are deduplicated and rate limited between health reports.
+package healtherrors
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+
+	jstime "github.com/anchore/k8s-inventory/internal/time"
+)
+
+// Code identifies the kind of failure an error reports
+type Code string
+
+// Error codes
+const (
+	CodeKubernetesClient         Code = "K8S_CLIENT_FAILED"
+	CodeKubernetesTimeout        Code = "K8S_REQUEST_TIMEOUT"
+	CodeKubernetesServerVersion  Code = "K8S_SERVER_VERSION_FAILED"
+	CodeListNamespaces           Code = "K8S_LIST_NAMESPACES_FAILED"
+	CodeListNodes                Code = "K8S_LIST_NODES_FAILED"
+	CodeListNodesForbidden       Code = "K8S_LIST_NODES_FORBIDDEN"
+	CodeListPods                 Code = "K8S_LIST_PODS_FAILED"
+	CodeInventoryCollection      Code = "INVENTORY_COLLECTION_FAILED"
+	CodeInventoryReportDelivery  Code = "INVENTORY_REPORT_DELIVERY_FAILED"
+	CodeAnchoreAccountNotFound   Code = "ANCHORE_ACCOUNT_NOT_FOUND"
+	CodeRegistrationRetried      Code = "REGISTRATION_RETRIED"
+	CodeIntegrationNotFound      Code = "INTEGRATION_NOT_FOUND"
+	CodeRegistrationReplicaCount Code = "REGISTRATION_REPLICA_COUNT_FAILED"
+)
+
+// MaxErrorsPerCode is the number of distinct errors kept per code between health reports. Further errors with the
+// same code are only counted, in a single suppressed entry.
+const MaxErrorsPerCode = 10
+
+// SuppressedMessage is the message of the entry counting the errors suppressed for a code
+const SuppressedMessage = "further errors were suppressed"
+
+// Error is a deduplicated error, with the number of times it occurred since the last health report
+type Error struct {
+	Code      Code            `json:"code"`
+	Message   string          `json:"message"`
+	Count     int             `json:"count"`
+	FirstSeen jstime.Datetime `json:"first_seen"`
+	LastSeen  jstime.Datetime `json:"last_seen"`
+}
+
+type key struct {
+	code    Code
+	message string
+}
+
+type _Now func() time.Time
+
+// Collector deduplicates and rate limits errors until they are drained into a health report
+type Collector struct {
+	lock   sync.Mutex
+	errors map[key]*Error
+	counts map[Code]int
+	now    _Now
+}
+
+func NewCollector() *Collector {
+	return newCollector(time.Now)
+}
+
+func newCollector(now _Now) *Collector {
+	return &Collector{
+		errors: make(map[key]*Error),
+		counts: make(map[Code]int),
+		now:    now,
+	}
+}
+
+var defaultCollector = NewCollector()
+
+// Record adds an error with the code to the default collector
+func Record(code Code, err error) {
+	defaultCollector.Record(code, err)
+}
+
+// Drain returns and removes the errors of the default collector
+func Drain() []Error {
+	return defaultCollector.Drain()
+}
+
+// Requeue adds errors drained from the default collector back, e.g. when the health report could not be sent
+func Requeue(errs []Error) {
+	defaultCollector.Requeue(errs)
+}
+
+// KubernetesCode returns the code for a failed Kubernetes request, which is CodeKubernetesTimeout if the request
+// timed out and the given code otherwise
+func KubernetesCode(code Code, err error) Code {
+	if k8sErrors.IsTimeout(err) || k8sErrors.IsServerTimeout(err) || errors.Is(err, context.DeadlineExceeded) {
+		return CodeKubernetesTimeout
+	}
+	return code
+}
+
+func (c *Collector) Record(code Code, err error) {
+	if err == nil {
+		return
+	}
+	now := jstime.Datetime{Time: c.now().UTC()}
+	c.add(Error{Code: code, Message: err.Error(), Count: 1, FirstSeen: now, LastSeen: now})
+}
+
+func (c *Collector) Requeue(errs []Error) {
+	for _, e := range errs {
+		c.add(e)
+	}
+}
+
+func (c *Collector) add(e Error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	k := key{code: e.Code, message: e.Message}
+	if _, exists := c.errors[k]; !exists && c.counts[e.Code] >= MaxErrorsPerCode {
+		k.message = SuppressedMessage
+	}
+
+	existing, exists := c.errors[k]
+	if !exists {
+		e.Message = k.message
+		c.errors[k] = &e
+		if k.message != SuppressedMessage {
+			c.counts[e.Code]++
+		}
+		return
+	}
+	existing.Count += e.Count
+	if e.FirstSeen.Before(existing.FirstSeen.Time) {
+		existing.FirstSeen = e.FirstSeen
+	}
+	if e.LastSeen.After(existing.LastSeen.Time) {
+		existing.LastSeen = e.LastSeen
+	}
+}
+
+// Drain returns the errors collected since the last drain, ordered by code and first occurrence, and removes them
+func (c *Collector) Drain() []Error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	errs := make([]Error, 0, len(c.errors))
+	for _, e := range c.errors {
+		errs = append(errs, *e)
+	}
+	c.errors = make(map[key]*Error)
+	c.counts = make(map[Code]int)
+
+	sort.Slice(errs, func(i, j int) bool {
+		if errs[i].Code != errs[j].Code {
+			return errs[i].Code < errs[j].Code
+		}
+		if !errs[i].FirstSeen.Equal(errs[j].FirstSeen.Time) {
+			return errs[i].FirstSeen.Before(errs[j].FirstSeen.Time)
+		}
+		return errs[i].Message < errs[j].Message
+	})
+	return errs
+}
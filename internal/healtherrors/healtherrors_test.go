@@ -0,0 +1,117 @@
+package healtherrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	jstime "github.com/anchore/k8s-inventory/internal/time"
+)
+
+func newTestCollector() (*Collector, *time.Time) {
+	now := time.Date(2024, 10, 4, 10, 11, 12, 0, time.UTC)
+	return newCollector(func() time.Time { return now }), &now
+}
+
+func TestCollectorDeduplicates(t *testing.T) {
+	c, now := newTestCollector()
+	first := *now
+
+	c.Record(CodeListPods, errors.New("failed to list pods in namespace default"))
+	*now = now.Add(time.Minute)
+	c.Record(CodeListPods, errors.New("failed to list pods in namespace default"))
+	c.Record(CodeListPods, errors.New("failed to list pods in namespace kube-system"))
+	c.Record(CodeListNodesForbidden, errors.New("nodes is forbidden"))
+	c.Record(CodeListNodes, nil)
+
+	assert.Equal(t, []Error{
+		{
+			Code:      CodeListNodesForbidden,
+			Message:   "nodes is forbidden",
+			Count:     1,
+			FirstSeen: jstime.Datetime{Time: *now},
+			LastSeen:  jstime.Datetime{Time: *now},
+		},
+		{
+			Code:      CodeListPods,
+			Message:   "failed to list pods in namespace default",
+			Count:     2,
+			FirstSeen: jstime.Datetime{Time: first},
+			LastSeen:  jstime.Datetime{Time: *now},
+		},
+		{
+			Code:      CodeListPods,
+			Message:   "failed to list pods in namespace kube-system",
+			Count:     1,
+			FirstSeen: jstime.Datetime{Time: *now},
+			LastSeen:  jstime.Datetime{Time: *now},
+		},
+	}, c.Drain())
+
+	assert.Empty(t, c.Drain())
+}
+
+func TestCollectorRateLimits(t *testing.T) {
+	c, _ := newTestCollector()
+
+	for i := 0; i < MaxErrorsPerCode+5; i++ {
+		c.Record(CodeListPods, fmt.Errorf("failed to list pods in namespace ns-%d", i))
+	}
+	// errors already kept are still counted
+	c.Record(CodeListPods, errors.New("failed to list pods in namespace ns-0"))
+	c.Record(CodeListNamespaces, errors.New("failed to list namespaces"))
+
+	errs := c.Drain()
+	assert.Len(t, errs, MaxErrorsPerCode+2)
+
+	suppressed := 0
+	for _, e := range errs {
+		switch e.Message {
+		case SuppressedMessage:
+			assert.Equal(t, CodeListPods, e.Code)
+			suppressed = e.Count
+		case "failed to list pods in namespace ns-0":
+			assert.Equal(t, 2, e.Count)
+		}
+	}
+	assert.Equal(t, 5, suppressed)
+
+	// the limit is reset by draining
+	c.Record(CodeListPods, errors.New("failed to list pods in namespace ns-20"))
+	assert.Len(t, c.Drain(), 1)
+}
+
+func TestCollectorRequeue(t *testing.T) {
+	c, now := newTestCollector()
+	first := *now
+
+	c.Record(CodeInventoryReportDelivery, errors.New("anchore is unavailable"))
+	drained := c.Drain()
+
+	*now = now.Add(time.Minute)
+	c.Record(CodeInventoryReportDelivery, errors.New("anchore is unavailable"))
+	c.Requeue(drained)
+
+	assert.Equal(t, []Error{
+		{
+			Code:      CodeInventoryReportDelivery,
+			Message:   "anchore is unavailable",
+			Count:     2,
+			FirstSeen: jstime.Datetime{Time: first},
+			LastSeen:  jstime.Datetime{Time: *now},
+		},
+	}, c.Drain())
+}
+
+func TestKubernetesCode(t *testing.T) {
+	assert.Equal(t, CodeKubernetesTimeout, KubernetesCode(CodeListPods, k8sErrors.NewTimeoutError("list pods", 1)))
+	assert.Equal(t, CodeKubernetesTimeout, KubernetesCode(CodeListPods, k8sErrors.NewServerTimeout(schema.GroupResource{Resource: "pods"}, "list", 1)))
+	assert.Equal(t, CodeKubernetesTimeout, KubernetesCode(CodeListPods, fmt.Errorf("failed: %w", context.DeadlineExceeded)))
+	assert.Equal(t, CodeListPods, KubernetesCode(CodeListPods, errors.New("connection refused")))
+}
@@ -1,6 +1,10 @@
 package log
 
-import "github.com/anchore/k8s-inventory/pkg/logger"
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/anchore/k8s-inventory/pkg/logger"
+)
 
 var Log logger.Logger = &nopLogger{}
 
@@ -27,3 +31,10 @@ func Debugf(format string, args ...interface{}) {
 func Debug(args ...interface{}) {
 	Log.Debug(args...)
 }
+
+// SetLevel changes the level of the logger, if the logger supports changing it while running
+func SetLevel(level logrus.Level) {
+	if l, ok := Log.(interface{ SetLevel(logrus.Level) }); ok {
+		l.SetLevel(level)
+	}
+}
@@ -80,6 +80,12 @@ func NewLogrusLogger(cfg LogrusConfig) *LogrusLogger {
 	}
 }
 
+// SetLevel changes the level of the logger, e.g. when it is set in the remote configuration
+func (l *LogrusLogger) SetLevel(level logrus.Level) {
+	l.Config.Level = level
+	l.Logger.SetLevel(level)
+}
+
 func (l *LogrusLogger) Debugf(format string, args ...interface{}) {
 	l.Logger.Debugf(format, args...)
 }
@@ -0,0 +1,94 @@
+// Package matcher matches names against the regular expressions of the configuration. The expressions are compiled
+// once when the configuration is built, so that an invalid pattern is a configuration error rather than a panic in
+// the middle of a collection, and matching does not recompile them for every label of every pod.
+package matcher
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// validNamespaceRegex determines whether a string is a valid namespace (valid dns name)
+var validNamespaceRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// InvalidPatternError is the error for a pattern that is not a valid regular expression, at its index in the list
+type InvalidPatternError struct {
+	Index   int
+	Pattern string
+	Err     error
+}
+
+func (e *InvalidPatternError) Error() string {
+	return fmt.Sprintf("invalid regular expression %q: %v", e.Pattern, e.Err)
+}
+
+func (e *InvalidPatternError) Unwrap() error {
+	return e.Err
+}
+
+// Matcher matches strings against a list of compiled regular expressions. A nil Matcher matches nothing.
+type Matcher struct {
+	patterns []string
+	regexes  []*regexp.Regexp
+}
+
+// New compiles the patterns, returning an *InvalidPatternError for the first one that does not compile
+func New(patterns []string) (*Matcher, error) {
+	return compile(patterns, regexp.Compile)
+}
+
+// NewNamespaces compiles namespace patterns: a valid namespace name only matches that namespace, anything else is
+// a regular expression
+func NewNamespaces(patterns []string) (*Matcher, error) {
+	return compile(patterns, func(pattern string) (*regexp.Regexp, error) {
+		if validNamespaceRegex.MatchString(pattern) {
+			return regexp.Compile("^" + regexp.QuoteMeta(pattern) + "$")
+		}
+		return regexp.Compile(pattern)
+	})
+}
+
+// MustNew is like New but panics if a pattern does not compile
+func MustNew(patterns ...string) *Matcher {
+	m, err := New(patterns)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func compile(patterns []string, compileFn func(string) (*regexp.Regexp, error)) (*Matcher, error) {
+	m := &Matcher{patterns: patterns, regexes: make([]*regexp.Regexp, 0, len(patterns))}
+	for i, pattern := range patterns {
+		regex, err := compileFn(pattern)
+		if err != nil {
+			return nil, &InvalidPatternError{Index: i, Pattern: pattern, Err: err}
+		}
+		m.regexes = append(m.regexes, regex)
+	}
+	return m, nil
+}
+
+// Empty returns whether there are no patterns to match
+func (m *Matcher) Empty() bool {
+	return m == nil || len(m.regexes) == 0
+}
+
+// Match returns the first pattern, as configured, that matches the value
+func (m *Matcher) Match(value string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	for i, regex := range m.regexes {
+		if regex.MatchString(value) {
+			return m.patterns[i], true
+		}
+	}
+	return "", false
+}
+
+// MatchString returns whether any of the patterns matches the value
+func (m *Matcher) MatchString(value string) bool {
+	_, ok := m.Match(value)
+	return ok
+}
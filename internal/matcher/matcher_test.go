@@ -0,0 +1,47 @@
+package matcher
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	m, err := New([]string{"^app$", "team-.*"})
+	require.NoError(t, err)
+
+	pattern, ok := m.Match("my-team-a")
+	assert.True(t, ok)
+	assert.Equal(t, "team-.*", pattern)
+	assert.True(t, m.MatchString("app"))
+	assert.False(t, m.MatchString("application"))
+	assert.False(t, m.Empty())
+
+	_, err = New([]string{"app", "kube-("})
+	invalid := &InvalidPatternError{}
+	require.True(t, errors.As(err, &invalid))
+	assert.Equal(t, 1, invalid.Index)
+	assert.Equal(t, "kube-(", invalid.Pattern)
+	assert.EqualError(t, err, "invalid regular expression \"kube-(\": error parsing regexp: missing closing ): `kube-(`")
+}
+
+func TestNewNamespaces(t *testing.T) {
+	m, err := NewNamespaces([]string{"default", "kube-.*"})
+	require.NoError(t, err)
+
+	// a namespace name only matches that namespace
+	assert.True(t, m.MatchString("default"))
+	assert.False(t, m.MatchString("not-default"))
+	assert.True(t, m.MatchString("kube-system"))
+	assert.False(t, m.MatchString("anchore"))
+}
+
+func TestNilMatcher(t *testing.T) {
+	var m *Matcher
+	assert.True(t, m.Empty())
+	assert.False(t, m.MatchString("default"))
+	assert.True(t, MustNew().Empty())
+	assert.Panics(t, func() { MustNew("*") })
+}
@@ -0,0 +1,167 @@
+// Package metrics defines the Prometheus metrics of the agent, registered with the default registry and served on
+// /metrics when the server is enabled
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "anchore_k8s_inventory"
+
+// Collection phases
+const (
+	PhaseInventory      = "inventory"
+	PhaseListNamespaces = "list_namespaces"
+	PhaseListNodes      = "list_nodes"
+	PhaseListPods       = "list_pods"
+	PhaseCollect        = "collect"
+	PhaseBatch          = "batch"
+)
+
+// Registration states
+const (
+	RegistrationPending     = "pending"
+	RegistrationRegistered  = "registered"
+	RegistrationUnsupported = "unsupported"
+	RegistrationFailed      = "failed"
+)
+
+var registrationStates = []string{RegistrationPending, RegistrationRegistered, RegistrationUnsupported, RegistrationFailed}
+
+var (
+	PhaseDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "collection_phase_duration_seconds",
+		Help:      "Time taken by each phase of the inventory collection.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 120, 300, 600, 1200},
+	}, []string{"phase"})
+
+	NamespacesCollected = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "namespaces_collected",
+		Help:      "Number of namespaces in the last inventory collected for the account.",
+	}, []string{"account"})
+
+	PodsCollected = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pods_collected",
+		Help:      "Number of pods in the last inventory collected for the account.",
+	}, []string{"account"})
+
+	ContainersCollected = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "containers_collected",
+		Help:      "Number of containers in the last inventory collected for the account.",
+	}, []string{"account"})
+
+	BatchesBuilt = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "batches_built_total",
+		Help:      "Number of inventory report batches built for the account.",
+	}, []string{"account"})
+
+	BatchSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "batch_size_bytes",
+		Help:      "Size of the inventory reports sent to the account.",
+		Buckets:   prometheus.ExponentialBuckets(1024, 4, 10),
+	}, []string{"account"})
+
+	AnchoreRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "anchore_requests_total",
+		Help: "Number of requests to Anchore by operation, account and status: the HTTP status code of error " +
+			"responses, 2xx for success, or error if there was no response.",
+	}, []string{"operation", "account", "status"})
+
+	AnchoreRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "anchore_request_duration_seconds",
+		Help:      "Time taken by the requests to Anchore by operation and account.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "account"})
+
+	LastSuccessfulReport = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_successful_report_timestamp_seconds",
+		Help:      "Unix time of the last inventory report successfully sent to the account.",
+	}, []string{"account"})
+
+	DefaultAccountFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "default_account_fallbacks_total",
+		Help:      "Number of inventory reports sent to the default account because the account does not exist in Anchore.",
+	}, []string{"account"})
+
+	NormalizationDrops = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "normalization_dropped_records_total",
+		Help:      "Number of records dropped or modified by normalization before sending, by kind and reason.",
+	}, []string{"kind", "reason"})
+
+	Reregistrations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reregistrations_total",
+		Help:      "Number of times the agent registered again because Anchore no longer knew its integration.",
+	})
+
+	Leader = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "leader",
+		Help:      "1 if the agent is the leader that collects and reports the inventory, 0 if it stands by. Only set when leader election is enabled.",
+	})
+
+	ShardReplicas = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "shard_replicas",
+		Help:      "Number of live replicas that the namespaces are split between. Only set when sharding is enabled.",
+	})
+
+	RegistrationState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "registration_state",
+		Help:      "State of the integration registration with Anchore, 1 for the current state.",
+	}, []string{"state"})
+)
+
+func init() {
+	SetRegistrationState(RegistrationPending)
+}
+
+// ObservePhase records the time taken by a collection phase since start
+func ObservePhase(phase string, start time.Time) {
+	PhaseDuration.WithLabelValues(phase).Observe(time.Since(start).Seconds())
+}
+
+// ObserveAnchoreRequest records the outcome and duration of a request to Anchore. statusCode is the HTTP status
+// code of the error response, if any.
+func ObserveAnchoreRequest(operation, account string, start time.Time, statusCode int, err error) {
+	AnchoreRequestDuration.WithLabelValues(operation, account).Observe(time.Since(start).Seconds())
+	AnchoreRequests.WithLabelValues(operation, account, status(statusCode, err)).Inc()
+}
+
+func status(statusCode int, err error) string {
+	switch {
+	case err == nil:
+		return "2xx"
+	case statusCode != 0:
+		return strconv.Itoa(statusCode)
+	default:
+		return "error"
+	}
+}
+
+// SetRegistrationState sets the current state of the integration registration
+func SetRegistrationState(state string) {
+	for _, s := range registrationStates {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		RegistrationState.WithLabelValues(s).Set(value)
+	}
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserveAnchoreRequest(t *testing.T) {
+	AnchoreRequests.Reset()
+	AnchoreRequestDuration.Reset()
+
+	start := time.Now()
+	ObserveAnchoreRequest("inventory report", "admin", start, 0, nil)
+	ObserveAnchoreRequest("inventory report", "admin", start, 0, nil)
+	ObserveAnchoreRequest("inventory report", "team-a", start, 403, errors.New("forbidden"))
+	ObserveAnchoreRequest("health report", "admin", start, 0, errors.New("connection refused"))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(AnchoreRequests.WithLabelValues("inventory report", "admin", "2xx")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(AnchoreRequests.WithLabelValues("inventory report", "team-a", "403")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(AnchoreRequests.WithLabelValues("health report", "admin", "error")))
+	assert.Equal(t, 3, testutil.CollectAndCount(AnchoreRequestDuration))
+}
+
+func TestSetRegistrationState(t *testing.T) {
+	assert.Equal(t, 1.0, testutil.ToFloat64(RegistrationState.WithLabelValues(RegistrationPending)))
+
+	SetRegistrationState(RegistrationRegistered)
+	for _, state := range registrationStates {
+		expected := 0.0
+		if state == RegistrationRegistered {
+			expected = 1
+		}
+		assert.Equal(t, expected, testutil.ToFloat64(RegistrationState.WithLabelValues(state)), state)
+	}
+}
+
+func TestObservePhase(t *testing.T) {
+	PhaseDuration.Reset()
+	ObservePhase(PhaseListNodes, time.Now().Add(-2*time.Second))
+
+	assert.Equal(t, 1, testutil.CollectAndCount(PhaseDuration))
+}
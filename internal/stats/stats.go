@@ -0,0 +1,166 @@
+// Package stats accumulates the statistics of an inventory collection cycle, so that they can be summarized in the
+// health reports. The statistics are carried in the context of the cycle.
+package stats
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	jstime "github.com/anchore/k8s-inventory/internal/time"
+)
+
+// Collection accumulates the statistics of a collection cycle. All methods are safe for concurrent use, and are
+// no-ops on a nil Collection.
+type Collection struct {
+	start                  time.Time
+	namespacesScanned      atomic.Int64
+	namespacesSkipped      atomic.Int64
+	namespacesFailed       atomic.Int64
+	pods                   atomic.Int64
+	containers             atomic.Int64
+	nodes                  atomic.Int64
+	normalizationDrops     atomic.Int64
+	kubernetesAPICalls     atomic.Int64
+	kubernetesAPIThrottled atomic.Int64
+	kubernetesAPIErrors    atomic.Int64
+	anchoreAPICalls        atomic.Int64
+	anchoreAPIErrors       atomic.Int64
+}
+
+// Summary is the summary of a collection cycle, sent in the health reports
+type Summary struct {
+	StartTime              jstime.Datetime  `json:"start_time"`               // when the cycle started
+	EndTime                jstime.Datetime  `json:"end_time"`                 // when the cycle ended, after the inventory reports were sent
+	Duration               *jstime.Duration `json:"duration"`                 // duration of the cycle in seconds
+	NamespacesScanned      int              `json:"namespaces_scanned"`       // namespaces collected into the inventory
+	NamespacesSkipped      int              `json:"namespaces_skipped"`       // namespaces excluded by the namespace selectors or for having no pods
+	NamespacesFailed       int              `json:"namespaces_failed"`        // namespaces whose pods could not be listed
+	Pods                   int              `json:"pods"`                     // pods found
+	Containers             int              `json:"containers"`               // containers found
+	Nodes                  int              `json:"nodes"`                    // nodes seen
+	NormalizationDrops     int              `json:"normalization_drops"`      // records dropped or modified by normalization
+	KubernetesAPICalls     int              `json:"kubernetes_api_calls"`     // requests to the Kubernetes API
+	KubernetesAPIThrottled int              `json:"kubernetes_api_throttled"` // requests to the Kubernetes API that were throttled (429)
+	KubernetesAPIErrors    int              `json:"kubernetes_api_errors"`    // requests to the Kubernetes API that failed or returned a server error
+	AnchoreAPICalls        int              `json:"anchore_api_calls"`        // inventory reports sent to Anchore
+	AnchoreAPIErrors       int              `json:"anchore_api_errors"`       // inventory reports that could not be sent to Anchore
+	Error                  string           `json:"error,omitempty"`          // why the inventory could not be collected, if it could not
+}
+
+type contextKey struct{}
+
+// NewContext starts the statistics of a collection cycle, returning a context carrying them
+func NewContext(ctx context.Context, start time.Time) (context.Context, *Collection) {
+	c := &Collection{start: start}
+	return context.WithValue(ctx, contextKey{}, c), c
+}
+
+// FromContext returns the statistics carried by the context, or nil if there are none
+func FromContext(ctx context.Context) *Collection {
+	c, _ := ctx.Value(contextKey{}).(*Collection)
+	return c
+}
+
+func (c *Collection) AddNamespaceScanned(pods, containers int) {
+	if c == nil {
+		return
+	}
+	c.namespacesScanned.Add(1)
+	c.pods.Add(int64(pods))
+	c.containers.Add(int64(containers))
+}
+
+func (c *Collection) AddNamespacesSkipped(count int) {
+	if c == nil {
+		return
+	}
+	c.namespacesSkipped.Add(int64(count))
+}
+
+func (c *Collection) AddNamespaceFailed() {
+	if c == nil {
+		return
+	}
+	c.namespacesFailed.Add(1)
+}
+
+// SetNodes sets the number of nodes seen. The nodes are listed for each account, so they are not added up.
+func (c *Collection) SetNodes(count int) {
+	if c == nil {
+		return
+	}
+	c.nodes.Store(int64(count))
+}
+
+func (c *Collection) AddNormalizationDrops(count int) {
+	if c == nil {
+		return
+	}
+	c.normalizationDrops.Add(int64(count))
+}
+
+func (c *Collection) AddAnchoreAPICall(err error) {
+	if c == nil {
+		return
+	}
+	c.anchoreAPICalls.Add(1)
+	if err != nil {
+		c.anchoreAPIErrors.Add(1)
+	}
+}
+
+func (c *Collection) addKubernetesAPICall(resp *http.Response, err error) {
+	if c == nil {
+		return
+	}
+	c.kubernetesAPICalls.Add(1)
+	switch {
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		c.kubernetesAPIErrors.Add(1)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		c.kubernetesAPIThrottled.Add(1)
+	}
+}
+
+// Summary summarizes the cycle as ending at the given time, with the collection error, if any
+func (c *Collection) Summary(end time.Time, err error) Summary {
+	summary := Summary{
+		StartTime:              jstime.Datetime{Time: c.start.UTC()},
+		EndTime:                jstime.Datetime{Time: end.UTC()},
+		Duration:               &jstime.Duration{Duration: end.Sub(c.start)},
+		NamespacesScanned:      int(c.namespacesScanned.Load()),
+		NamespacesSkipped:      int(c.namespacesSkipped.Load()),
+		NamespacesFailed:       int(c.namespacesFailed.Load()),
+		Pods:                   int(c.pods.Load()),
+		Containers:             int(c.containers.Load()),
+		Nodes:                  int(c.nodes.Load()),
+		NormalizationDrops:     int(c.normalizationDrops.Load()),
+		KubernetesAPICalls:     int(c.kubernetesAPICalls.Load()),
+		KubernetesAPIThrottled: int(c.kubernetesAPIThrottled.Load()),
+		KubernetesAPIErrors:    int(c.kubernetesAPIErrors.Load()),
+		AnchoreAPICalls:        int(c.anchoreAPICalls.Load()),
+		AnchoreAPIErrors:       int(c.anchoreAPIErrors.Load()),
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	return summary
+}
+
+type countingTransport struct {
+	next http.RoundTripper
+}
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	FromContext(req.Context()).addKubernetesAPICall(resp, err)
+	return resp, err
+}
+
+// WrapTransport counts the requests sent through the transport, e.g. to the Kubernetes API, in the statistics
+// carried by the context of each request
+func WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return countingTransport{next: rt}
+}
@@ -0,0 +1,100 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	jstime "github.com/anchore/k8s-inventory/internal/time"
+)
+
+func TestSummary(t *testing.T) {
+	start := time.Date(2024, 10, 4, 10, 11, 12, 0, time.UTC)
+	ctx, c := NewContext(context.Background(), start)
+	assert.Same(t, c, FromContext(ctx))
+
+	FromContext(ctx).AddNamespaceScanned(3, 5)
+	FromContext(ctx).AddNamespaceScanned(0, 0)
+	FromContext(ctx).AddNamespacesSkipped(2)
+	FromContext(ctx).AddNamespaceFailed()
+	FromContext(ctx).SetNodes(4)
+	FromContext(ctx).SetNodes(4)
+	FromContext(ctx).AddNormalizationDrops(1)
+	FromContext(ctx).AddAnchoreAPICall(nil)
+	FromContext(ctx).AddAnchoreAPICall(errors.New("anchore is unavailable"))
+
+	assert.Equal(t, Summary{
+		StartTime:          jstime.Datetime{Time: start},
+		EndTime:            jstime.Datetime{Time: start.Add(90 * time.Second)},
+		Duration:           &jstime.Duration{Duration: 90 * time.Second},
+		NamespacesScanned:  2,
+		NamespacesSkipped:  2,
+		NamespacesFailed:   1,
+		Pods:               3,
+		Containers:         5,
+		Nodes:              4,
+		NormalizationDrops: 1,
+		AnchoreAPICalls:    2,
+		AnchoreAPIErrors:   1,
+		Error:              "",
+	}, c.Summary(start.Add(90*time.Second), nil))
+
+	assert.Equal(t, "failed to list namespaces", c.Summary(start, errors.New("failed to list namespaces")).Error)
+}
+
+func TestNoCollection(t *testing.T) {
+	c := FromContext(context.Background())
+	assert.Nil(t, c)
+
+	assert.NotPanics(t, func() {
+		c.AddNamespaceScanned(1, 1)
+		c.AddNamespacesSkipped(1)
+		c.AddNamespaceFailed()
+		c.SetNodes(1)
+		c.AddNormalizationDrops(1)
+		c.AddAnchoreAPICall(nil)
+		c.addKubernetesAPICall(nil, errors.New("connection refused"))
+	})
+}
+
+func TestWrapTransport(t *testing.T) {
+	statuses := map[string]int{
+		"/ok":        http.StatusOK,
+		"/throttled": http.StatusTooManyRequests,
+		"/error":     http.StatusInternalServerError,
+		"/missing":   http.StatusNotFound,
+	}
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statuses[r.URL.Path])
+	}))
+	defer apiServer.Close()
+
+	httpClient := http.Client{Transport: WrapTransport(http.DefaultTransport)}
+	ctx, c := NewContext(context.Background(), time.Now())
+	get := func(ctx context.Context, path string) {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, apiServer.URL+path, nil)
+		require.NoError(t, err)
+		response, err := httpClient.Do(request)
+		require.NoError(t, err)
+		response.Body.Close()
+	}
+
+	get(ctx, "/ok")
+	get(ctx, "/ok")
+	get(ctx, "/throttled")
+	get(ctx, "/error")
+	get(ctx, "/missing")
+	// requests outside of a collection cycle are not counted
+	get(context.Background(), "/ok")
+
+	summary := c.Summary(time.Now(), nil)
+	assert.Equal(t, 5, summary.KubernetesAPICalls)
+	assert.Equal(t, 1, summary.KubernetesAPIThrottled)
+	assert.Equal(t, 1, summary.KubernetesAPIErrors)
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/anchore/k8s-inventory/internal/log"
+	"github.com/anchore/k8s-inventory/internal/metrics"
 )
 
 // TrackFunctionTime is a function that tracks the time it takes to execute a function
@@ -25,3 +26,10 @@ func TrackFunctionTime(start time.Time, msg string) {
 	elapsed := time.Since(start)
 	log.Log.Debugf("%s took %s", msg, elapsed)
 }
+
+// TrackPhaseTime logs the time it took to execute a function like TrackFunctionTime, and records it in the
+// collection phase duration metric for the given phase (see the metrics package)
+func TrackPhaseTime(start time.Time, phase string, msg string) {
+	TrackFunctionTime(start, msg)
+	metrics.ObservePhase(phase, start)
+}
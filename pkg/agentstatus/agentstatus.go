@@ -0,0 +1,321 @@
+// Package agentstatus surfaces the state of the agent to cluster administrators without access to Anchore, as
+// Kubernetes Events on the pod of the agent and in a status ConfigMap, so that kubectl describe tells whether the agent
+// works.
+package agentstatus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/internal/log"
+	jstime "github.com/anchore/k8s-inventory/internal/time"
+	"github.com/anchore/k8s-inventory/pkg/client"
+	"github.com/anchore/k8s-inventory/pkg/leader"
+)
+
+// Reasons of the Events
+const (
+	ReasonRegistered           = "Registered"
+	ReasonRegistrationFailed   = "RegistrationFailed"
+	ReasonCollectionFailed     = "InventoryCollectionFailed"
+	ReasonReportDeliveryFailed = "InventoryReportDeliveryFailed"
+	ReasonKubernetesForbidden  = "Forbidden"
+)
+
+const (
+	eventSourceComponent = "anchore-k8s-inventory"
+	podLookupTimeout     = 10 * time.Second
+)
+
+// States of the registration with Anchore
+const (
+	registrationStatePending    = "pending"
+	registrationStateRegistered = "registered"
+	registrationStateFailed     = "failed"
+)
+
+// current is the reporter that the agent runs, if the state of the agent is surfaced in the cluster
+var current atomic.Pointer[Reporter]
+
+// Status is the state of the agent, kept in the status ConfigMap under the name of the pod of the agent
+type Status struct {
+	Mode                     string                   `json:"mode"`           // periodic or adhoc
+	Role                     string                   `json:"role,omitempty"` // leader or standby, if leader election is enabled
+	Registration             string                   `json:"registration"`   // pending, registered or failed
+	IntegrationUUID          string                   `json:"integration_uuid,omitempty"`
+	LastSuccessfulCollection *jstime.Datetime         `json:"last_successful_collection,omitempty"`
+	Accounts                 map[string]AccountStatus `json:"accounts,omitempty"`
+	Updated                  jstime.Datetime          `json:"updated"`
+}
+
+// AccountStatus is the state of the inventory reports of an account
+type AccountStatus struct {
+	LastSuccessfulSend *jstime.Datetime `json:"last_successful_send,omitempty"`
+	BatchErrors        []string         `json:"batch_errors,omitempty"` // errors of the batches of the latest report
+}
+
+// Reporter records the Events and keeps the status ConfigMap up to date
+type Reporter struct {
+	clientset     kubernetes.Interface
+	namespace     string
+	configMapName string
+	recorder      record.EventRecorder
+	broadcaster   record.EventBroadcaster
+	pod           *corev1.ObjectReference
+	lock          sync.Mutex
+	status        Status
+	changed       chan struct{}
+}
+
+// NewForConfig returns a reporter for the configuration, recording the Events on the pod of the agent (HOSTNAME)
+func NewForConfig(appConfig *config.Application) (*Reporter, error) {
+	namespace := appConfig.AgentStatus.Namespace
+	if namespace == "" {
+		namespace = os.Getenv("POD_NAMESPACE")
+	}
+	if namespace == "" {
+		return nil, fmt.Errorf("agent-status.namespace is required when POD_NAMESPACE is not set")
+	}
+	podName, err := client.PodIdentity()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine pod for agent status: %w", err)
+	}
+
+	kubeconfig, err := client.GetKubeConfig(appConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig for agent status: %w", err)
+	}
+	clientset, err := client.GetClientSet(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clientset for agent status: %w", err)
+	}
+	r := New(appConfig.AgentStatus, clientset, namespace, podName, appConfig.RunMode.String())
+	// kubectl describe only shows the Events of the pod with the same UID
+	ctx, cancel := context.WithTimeout(context.Background(), podLookupTimeout)
+	defer cancel()
+	if pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{}); err != nil {
+		log.Warnf("Failed to get pod %s to record Events on: %v", podName, err)
+	} else {
+		r.pod.UID = pod.UID
+	}
+	return r, nil
+}
+
+// New returns a reporter that records the Events on the pod and keeps the status ConfigMap in the namespace
+func New(cfg config.AgentStatusConfig, clientset kubernetes.Interface, namespace, podName, mode string) *Reporter {
+	r := &Reporter{
+		clientset: clientset,
+		namespace: namespace,
+		pod: &corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Namespace:  namespace,
+			Name:       podName,
+		},
+		status:  Status{Mode: mode, Registration: registrationStatePending},
+		changed: make(chan struct{}, 1),
+	}
+	if cfg.ConfigMap {
+		r.configMapName = cfg.ConfigMapName
+	}
+	if cfg.Events {
+		r.broadcaster = record.NewBroadcaster()
+		r.broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events(namespace)})
+		r.recorder = r.broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventSourceComponent})
+	}
+	return r
+}
+
+// Start records the state of the agent from now on, and keeps the status ConfigMap up to date in the background until
+// the context is done
+func (r *Reporter) Start(ctx context.Context) {
+	current.Store(r)
+	go r.run(ctx)
+}
+
+func (r *Reporter) run(ctx context.Context) {
+	defer current.CompareAndSwap(r, nil)
+	if r.broadcaster != nil {
+		defer r.broadcaster.Shutdown()
+	}
+	r.update(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.changed:
+			r.update(ctx)
+		}
+	}
+}
+
+func (r *Reporter) update(ctx context.Context) {
+	if r.configMapName == "" {
+		return
+	}
+	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error { return r.writeConfigMap(ctx) }); err != nil {
+		log.Warnf("Failed to update status ConfigMap %s: %v", r.configMapName, err)
+	}
+}
+
+// Status returns a copy of the state of the agent
+func (r *Reporter) Status() Status {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	status := r.status
+	status.Role = leader.Role()
+	status.Accounts = make(map[string]AccountStatus, len(r.status.Accounts))
+	for account, accountStatus := range r.status.Accounts {
+		status.Accounts[account] = accountStatus
+	}
+	return status
+}
+
+// writeConfigMap creates or updates the status ConfigMap with the state of the agent, and removes the state of the
+// replicas whose pod no longer exists
+func (r *Reporter) writeConfigMap(ctx context.Context) error {
+	status := r.Status()
+	status.Updated = jstime.Datetime{Time: time.Now().UTC()}
+	encoded, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal status: %w", err)
+	}
+
+	configMaps := r.clientset.CoreV1().ConfigMaps(r.namespace)
+	configMap, err := configMaps.Get(ctx, r.configMapName, metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: r.configMapName, Namespace: r.namespace},
+			Data:       map[string]string{r.pod.Name: string(encoded)},
+		}
+		_, err = configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	data := map[string]string{r.pod.Name: string(encoded)}
+	for podName, podStatus := range configMap.Data {
+		if podName == r.pod.Name {
+			continue
+		}
+		_, err := r.clientset.CoreV1().Pods(r.namespace).Get(ctx, podName, metav1.GetOptions{})
+		if k8sErrors.IsNotFound(err) {
+			continue
+		}
+		data[podName] = podStatus
+	}
+	configMap.Data = data
+	_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+	return err
+}
+
+// setStatus changes the state of the agent, and has the status ConfigMap updated
+func (r *Reporter) setStatus(change func(status *Status)) {
+	r.lock.Lock()
+	change(&r.status)
+	r.lock.Unlock()
+	select {
+	case r.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Reporter) event(eventType, reason, messageFmt string, args ...interface{}) {
+	if r.recorder != nil {
+		r.recorder.Eventf(r.pod, eventType, reason, messageFmt, args...)
+	}
+}
+
+// RegistrationSucceeded records that the agent registered with Anchore as the integration
+func RegistrationSucceeded(integrationUUID string) {
+	r := current.Load()
+	if r == nil {
+		return
+	}
+	r.setStatus(func(status *Status) {
+		status.Registration = registrationStateRegistered
+		status.IntegrationUUID = integrationUUID
+	})
+	r.event(corev1.EventTypeNormal, ReasonRegistered, "Registered with Anchore as integration %s", integrationUUID)
+}
+
+// RegistrationFailed records that the agent failed to register with Anchore
+func RegistrationFailed(err error) {
+	r := current.Load()
+	if r == nil {
+		return
+	}
+	r.setStatus(func(status *Status) {
+		status.Registration = registrationStateFailed
+	})
+	r.event(corev1.EventTypeWarning, ReasonRegistrationFailed, "Failed to register with Anchore: %v", err)
+}
+
+// SetCollectionResult records the result of an inventory collection cycle
+func SetCollectionResult(err error) {
+	r := current.Load()
+	if r == nil {
+		return
+	}
+	if err != nil {
+		r.event(corev1.EventTypeWarning, ReasonCollectionFailed, "Failed to collect the inventory: %v", err)
+		return
+	}
+	now := jstime.Datetime{Time: time.Now().UTC()}
+	r.setStatus(func(status *Status) {
+		status.LastSuccessfulCollection = &now
+	})
+}
+
+// SetReportResult records the result of sending the inventory report of the account in batches, with the errors of
+// the batches that failed. The report counts as sent if any of its batches was sent.
+func SetReportResult(account string, batches int, batchErrors []string) {
+	r := current.Load()
+	if r == nil {
+		return
+	}
+	now := jstime.Datetime{Time: time.Now().UTC()}
+	r.setStatus(func(status *Status) {
+		if status.Accounts == nil {
+			status.Accounts = map[string]AccountStatus{}
+		}
+		accountStatus := status.Accounts[account]
+		if len(batchErrors) < batches {
+			accountStatus.LastSuccessfulSend = &now
+		}
+		accountStatus.BatchErrors = batchErrors
+		status.Accounts[account] = accountStatus
+	})
+	if len(batchErrors) > 0 {
+		r.event(corev1.EventTypeWarning, ReasonReportDeliveryFailed,
+			"Failed to send %d of %d inventory report batches to Anchore account %s: %s",
+			len(batchErrors), batches, account, batchErrors[0])
+	}
+}
+
+// KubernetesError records a failed Kubernetes request if the agent is not allowed to make it, so that its RBAC can be
+// fixed
+func KubernetesError(err error) {
+	r := current.Load()
+	if r == nil || !k8sErrors.IsForbidden(err) {
+		return
+	}
+	r.event(corev1.EventTypeWarning, ReasonKubernetesForbidden, "Missing RBAC permission: %v", err)
+}
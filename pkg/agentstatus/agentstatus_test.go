@@ -0,0 +1,147 @@
+package agentstatus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+)
+
+var agentStatusConfig = config.AgentStatusConfig{
+	ConfigMap:     true,
+	ConfigMapName: "anchore-k8s-inventory-status",
+}
+
+// newTestReporter returns a reporter that records the Events in a fake recorder, as the current reporter
+func newTestReporter(t *testing.T, clientset *fake.Clientset) (*Reporter, *record.FakeRecorder) {
+	t.Cleanup(func() { current.Store(nil) })
+	r := New(agentStatusConfig, clientset, "anchore", "pod-a", "periodic")
+	recorder := record.NewFakeRecorder(10)
+	r.recorder = recorder
+	current.Store(r)
+	return r, recorder
+}
+
+func readStatus(t *testing.T, clientset *fake.Clientset, podName string) Status {
+	configMap, err := clientset.CoreV1().ConfigMaps("anchore").Get(context.Background(), "anchore-k8s-inventory-status", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Contains(t, configMap.Data, podName)
+	status := Status{}
+	require.NoError(t, json.Unmarshal([]byte(configMap.Data[podName]), &status))
+	return status
+}
+
+func TestEvents(t *testing.T) {
+	_, recorder := newTestReporter(t, fake.NewClientset())
+
+	RegistrationSucceeded("c8d2b8c2-33fe-4be8-8e4a-2f7e8ef1c7a9")
+	RegistrationFailed(errors.New("401 Unauthorized"))
+	SetCollectionResult(errors.New("context deadline exceeded"))
+	SetReportResult("team-a", 2, []string{"500 Internal Server Error"})
+	SetReportResult("team-b", 1, nil)
+	KubernetesError(k8sErrors.NewForbidden(schema.GroupResource{Resource: "nodes"}, "", errors.New("no RBAC")))
+	KubernetesError(k8sErrors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, "default"))
+
+	close(recorder.Events)
+	events := make([]string, 0)
+	for event := range recorder.Events {
+		events = append(events, event)
+	}
+	assert.Equal(t, []string{
+		"Normal Registered Registered with Anchore as integration c8d2b8c2-33fe-4be8-8e4a-2f7e8ef1c7a9",
+		"Warning RegistrationFailed Failed to register with Anchore: 401 Unauthorized",
+		"Warning InventoryCollectionFailed Failed to collect the inventory: context deadline exceeded",
+		"Warning InventoryReportDeliveryFailed Failed to send 1 of 2 inventory report batches to Anchore account team-a: 500 Internal Server Error",
+		`Warning Forbidden Missing RBAC permission: nodes is forbidden: no RBAC`,
+	}, events)
+}
+
+func TestStatus(t *testing.T) {
+	r, _ := newTestReporter(t, fake.NewClientset())
+
+	SetCollectionResult(nil)
+	SetReportResult("team-a", 2, []string{"500 Internal Server Error"})
+	SetReportResult("team-b", 1, []string{"account does not exist"})
+
+	status := r.Status()
+	assert.Equal(t, "periodic", status.Mode)
+	assert.Equal(t, registrationStatePending, status.Registration)
+	assert.NotNil(t, status.LastSuccessfulCollection)
+	assert.NotNil(t, status.Accounts["team-a"].LastSuccessfulSend)
+	assert.Equal(t, []string{"500 Internal Server Error"}, status.Accounts["team-a"].BatchErrors)
+	// no batch of the report was sent
+	assert.Nil(t, status.Accounts["team-b"].LastSuccessfulSend)
+
+	// the batch errors are those of the latest report
+	SetReportResult("team-a", 2, nil)
+	assert.Empty(t, r.Status().Accounts["team-a"].BatchErrors)
+}
+
+func TestWriteConfigMap(t *testing.T) {
+	clientset := fake.NewClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-a", Namespace: "anchore"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-b", Namespace: "anchore"}},
+	)
+	r, _ := newTestReporter(t, clientset)
+
+	require.NoError(t, r.writeConfigMap(context.Background()))
+	assert.Equal(t, registrationStatePending, readStatus(t, clientset, "pod-a").Registration)
+
+	// the replicas keep their state side by side, and the state of replicas whose pod is gone is removed
+	configMaps := clientset.CoreV1().ConfigMaps("anchore")
+	configMap, err := configMaps.Get(context.Background(), "anchore-k8s-inventory-status", metav1.GetOptions{})
+	require.NoError(t, err)
+	configMap.Data["pod-b"] = `{"mode": "periodic"}`
+	configMap.Data["pod-gone"] = `{"mode": "periodic"}`
+	_, err = configMaps.Update(context.Background(), configMap, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	RegistrationSucceeded("c8d2b8c2-33fe-4be8-8e4a-2f7e8ef1c7a9")
+	require.NoError(t, r.writeConfigMap(context.Background()))
+	status := readStatus(t, clientset, "pod-a")
+	assert.Equal(t, registrationStateRegistered, status.Registration)
+	assert.Equal(t, "c8d2b8c2-33fe-4be8-8e4a-2f7e8ef1c7a9", status.IntegrationUUID)
+
+	configMap, err = configMaps.Get(context.Background(), "anchore-k8s-inventory-status", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, configMap.Data, "pod-b")
+	assert.NotContains(t, configMap.Data, "pod-gone")
+}
+
+func TestStart(t *testing.T) {
+	clientset := fake.NewClientset()
+	r := New(agentStatusConfig, clientset, "anchore", "pod-a", "periodic")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.Start(ctx)
+	defer current.Store(nil)
+
+	SetCollectionResult(nil)
+	assert.Eventually(t, func() bool {
+		configMap, err := clientset.CoreV1().ConfigMaps("anchore").Get(ctx, "anchore-k8s-inventory-status", metav1.GetOptions{})
+		if err != nil {
+			return false
+		}
+		status := Status{}
+		return json.Unmarshal([]byte(configMap.Data["pod-a"]), &status) == nil && status.LastSuccessfulCollection != nil
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestWithoutAgentStatus(t *testing.T) {
+	// nothing is recorded when the state of the agent is not surfaced in the cluster
+	RegistrationSucceeded("c8d2b8c2-33fe-4be8-8e4a-2f7e8ef1c7a9")
+	SetReportResult("team-a", 1, []string{"500 Internal Server Error"})
+	assert.Nil(t, current.Load())
+}
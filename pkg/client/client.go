@@ -13,6 +13,8 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/internal/stats"
+	"github.com/anchore/k8s-inventory/pkg/tracing"
 )
 
 const UseInCluster = "use-in-cluster"
@@ -32,7 +34,21 @@ func GetClientSet(kubeConfig *rest.Config) (*kubernetes.Clientset, error) {
 	return clientset, nil
 }
 
+// GetKubeConfig retrieves the kube config from the application configuration. The requests to the Kubernetes API
+// are counted in the collection statistics, and traced if tracing is enabled.
 func GetKubeConfig(appConfig *config.Application) (*rest.Config, error) {
+	kubeConfig, err := getKubeConfig(appConfig)
+	if err != nil {
+		return nil, err
+	}
+	kubeConfig.Wrap(stats.WrapTransport)
+	if appConfig.Tracing.Enabled {
+		kubeConfig.Wrap(tracing.WrapTransport)
+	}
+	return kubeConfig, nil
+}
+
+func getKubeConfig(appConfig *config.Application) (*rest.Config, error) {
 	switch {
 	case appConfig.KubeConfig.IsKubeConfigFromFile():
 		if appConfig.KubeConfig.Path == UseInCluster {
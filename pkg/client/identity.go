@@ -0,0 +1,19 @@
+package client
+
+import (
+	"fmt"
+	"os"
+)
+
+// PodIdentity returns the name of the pod the agent runs in (HOSTNAME), or the hostname when running outside of
+// Kubernetes, to tell the replicas of the agent apart
+func PodIdentity() (string, error) {
+	if hostname := os.Getenv("HOSTNAME"); hostname != "" {
+		return hostname, nil
+	}
+	identity, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine pod identity: %w", err)
+	}
+	return identity, nil
+}
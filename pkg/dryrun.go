@@ -0,0 +1,111 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/pkg/reporter"
+)
+
+// DryRunPlan describes what would be sent to Anchore, without sending anything
+type DryRunPlan struct {
+	URL      string          `json:"url"`
+	Accounts []AccountDryRun `json:"accounts"`
+}
+
+type AccountDryRun struct {
+	Account    string        `json:"account"`
+	Namespaces []string      `json:"namespaces"`
+	Pods       int           `json:"pods"`
+	Containers int           `json:"containers"`
+	Batches    []BatchDryRun `json:"batches"`
+}
+
+type BatchDryRun struct {
+	Namespaces int                      `json:"namespaces"`
+	Pods       int                      `json:"pods"`
+	Containers int                      `json:"containers"`
+	SizeBytes  int                      `json:"size_bytes"`
+	Dropped    []reporter.DroppedRecord `json:"dropped"`
+}
+
+// GetDryRunPlan runs the whole inventory collection, routing, batching and normalization, and returns what would
+// be sent to each account
+func GetDryRunPlan(cfg *config.Application) (DryRunPlan, error) {
+	reports, err := GetInventoryReports(context.Background(), cfg)
+	if err != nil {
+		return DryRunPlan{}, err
+	}
+	return newDryRunPlan(cfg.AnchoreDetails.URL, reports)
+}
+
+func newDryRunPlan(url string, reports BatchedReports) (DryRunPlan, error) {
+	plan := DryRunPlan{URL: url, Accounts: make([]AccountDryRun, 0, len(reports))}
+	for account, reportsForAccount := range reports {
+		accountPlan := AccountDryRun{
+			Account:    account,
+			Namespaces: make([]string, 0),
+			Batches:    make([]BatchDryRun, 0, len(reportsForAccount)),
+		}
+		for _, report := range reportsForAccount {
+			// the same normalization and serialization as when the report is sent
+			normalized, dropped := reporter.NormalizeWithDetails(report)
+			body, err := json.Marshal(normalized)
+			if err != nil {
+				return DryRunPlan{}, fmt.Errorf("failed to serialize report for account %s: %w", account, err)
+			}
+
+			for _, ns := range normalized.Namespaces {
+				accountPlan.Namespaces = append(accountPlan.Namespaces, ns.Name)
+			}
+			accountPlan.Pods += len(normalized.Pods)
+			accountPlan.Containers += len(normalized.Containers)
+			accountPlan.Batches = append(accountPlan.Batches, BatchDryRun{
+				Namespaces: len(normalized.Namespaces),
+				Pods:       len(normalized.Pods),
+				Containers: len(normalized.Containers),
+				SizeBytes:  len(body),
+				Dropped:    dropped,
+			})
+		}
+		sort.Strings(accountPlan.Namespaces)
+		plan.Accounts = append(plan.Accounts, accountPlan)
+	}
+	sort.Slice(plan.Accounts, func(i, j int) bool {
+		return plan.Accounts[i].Account < plan.Accounts[j].Account
+	})
+	return plan, nil
+}
+
+// WriteDryRunPlan writes a human readable summary of the plan
+func WriteDryRunPlan(w io.Writer, plan DryRunPlan) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Dry run, nothing was sent to Anchore (%s)\n", plan.URL)
+	if len(plan.Accounts) == 0 {
+		sb.WriteString("\nNo inventory would be reported\n")
+	}
+	for _, account := range plan.Accounts {
+		fmt.Fprintf(&sb, "\nAccount: %s\n", account.Account)
+		fmt.Fprintf(&sb, "  Namespaces (%d): %s\n", len(account.Namespaces), strings.Join(account.Namespaces, ", "))
+		fmt.Fprintf(&sb, "  Pods: %d, Containers: %d\n", account.Pods, account.Containers)
+		fmt.Fprintf(&sb, "  Batches: %d\n", len(account.Batches))
+		for i, batch := range account.Batches {
+			fmt.Fprintf(&sb, "    %d: %d namespaces, %d pods, %d containers, %d bytes\n",
+				i+1, batch.Namespaces, batch.Pods, batch.Containers, batch.SizeBytes)
+			for _, dropped := range batch.Dropped {
+				name := dropped.Name
+				if dropped.ID != "" {
+					name = fmt.Sprintf("%s (%s)", dropped.Name, dropped.ID)
+				}
+				fmt.Fprintf(&sb, "       dropped %s %s: %s\n", dropped.Kind, name, dropped.Reason)
+			}
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
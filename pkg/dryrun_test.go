@@ -0,0 +1,66 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/k8s-inventory/pkg/inventory"
+	"github.com/anchore/k8s-inventory/pkg/reporter"
+)
+
+func TestDryRunPlan(t *testing.T) {
+	reports := BatchedReports{
+		"team-a": {
+			{
+				Namespaces: []inventory.Namespace{{Name: "team-a", UID: "ns-1"}},
+				Nodes:      []inventory.Node{{Name: "node-1", UID: "node-1"}},
+				Pods: []inventory.Pod{
+					{Name: "web", UID: "pod-1", NamespaceUID: "ns-1", NodeUID: "node-1"},
+					{Name: "db", UID: "pod-2", NamespaceUID: "ns-1", NodeUID: "node-1"},
+				},
+				Containers: []inventory.Container{
+					{Name: "nginx", ID: "c-1", PodUID: "pod-1"},
+					{Name: "postgres", ID: "c-2", PodUID: "pod-2"},
+					{Name: "sidecar", ID: "c-3", PodUID: "pod-3"},
+				},
+			},
+			{
+				Namespaces: []inventory.Namespace{{Name: "team-a-dev", UID: "ns-2"}},
+				Nodes:      []inventory.Node{{Name: "node-1", UID: "node-1"}},
+			},
+		},
+		"admin": {
+			{
+				Namespaces: []inventory.Namespace{{Name: "default", UID: "ns-3"}},
+			},
+		},
+	}
+
+	plan, err := newDryRunPlan("https://ancho.re", reports)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "https://ancho.re", plan.URL)
+	assert.Len(t, plan.Accounts, 2)
+	assert.Equal(t, "admin", plan.Accounts[0].Account)
+
+	teamA := plan.Accounts[1]
+	assert.Equal(t, "team-a", teamA.Account)
+	assert.Equal(t, []string{"team-a", "team-a-dev"}, teamA.Namespaces)
+	assert.Equal(t, 2, teamA.Pods)
+	assert.Equal(t, 2, teamA.Containers)
+	assert.Len(t, teamA.Batches, 2)
+	assert.Greater(t, teamA.Batches[0].SizeBytes, teamA.Batches[1].SizeBytes)
+	assert.Equal(t, []reporter.DroppedRecord{
+		{Kind: "Container", Name: "sidecar", ID: "c-3", Reason: "references a pod that is not in the report"},
+	}, teamA.Batches[0].Dropped)
+
+	var sb strings.Builder
+	assert.NoError(t, WriteDryRunPlan(&sb, plan))
+	output := sb.String()
+	assert.Contains(t, output, "Dry run, nothing was sent to Anchore (https://ancho.re)")
+	assert.Contains(t, output, "Account: team-a\n  Namespaces (2): team-a, team-a-dev\n  Pods: 2, Containers: 2\n  Batches: 2\n")
+	assert.Contains(t, output, "dropped Container sidecar (c-3): references a pod that is not in the report")
+	assert.Less(t, strings.Index(output, "Account: admin"), strings.Index(output, "Account: team-a"))
+}
@@ -1,7 +1,9 @@
 package healthreporter
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"sync"
 	"time"
 
@@ -9,13 +11,18 @@ import (
 
 	"github.com/anchore/k8s-inventory/internal/anchore"
 	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/internal/healtherrors"
 	"github.com/anchore/k8s-inventory/internal/log"
+	"github.com/anchore/k8s-inventory/internal/stats"
 	jstime "github.com/anchore/k8s-inventory/internal/time"
 	intg "github.com/anchore/k8s-inventory/pkg/integration"
+	"github.com/anchore/k8s-inventory/pkg/leader"
+	"github.com/anchore/k8s-inventory/pkg/secrets"
+	"github.com/anchore/k8s-inventory/pkg/sharding"
 )
 
 const healthProtocolVersion = 1
-const healthDataVersion = 1
+const healthDataVersion = 2
 const healthDataType = "k8s_inventory_agent"
 const HealthReportAPIPathV2 = "v2/system/integrations/{{id}}/health-report"
 
@@ -31,12 +38,16 @@ type HealthReport struct {
 type HealthData struct {
 	Type    string             `json:"type,omitempty"`    // type of health data
 	Version int                `json:"version,omitempty"` // format version
-	Errors  HealthReportErrors `json:"errors,omitempty"`  // list of errors
+	Errors  HealthReportErrors `json:"errors,omitempty"`  // collection and delivery errors since the last health report
+	Role    string             `json:"role,omitempty"`    // leader or standby, if leader election is enabled
+	Shard   *sharding.Status   `json:"shard,omitempty"`   // share of the namespaces of the agent, if sharding is enabled
 	// Anything below this line is specific to k8s-inventory-agent
 	AccountK8sInventoryReports AccountK8SInventoryReports `json:"account_k8s_inventory_reports,omitempty"` // latest inventory reports per account
+	LastCollection             *stats.Summary             `json:"last_collection,omitempty"`               // summary of the latest inventory collection cycle
+	Configuration              *config.Application        `json:"configuration,omitempty"`                 // configuration used for the latest inventory collection cycle, with the remote configuration applied
 }
 
-type HealthReportErrors []string
+type HealthReportErrors []healtherrors.Error
 
 // AccountK8SInventoryReports holds per account information about latest inventory reports from the same batch set
 type AccountK8SInventoryReports map[string]InventoryReportInfo
@@ -66,9 +77,11 @@ type BatchInfo struct {
 // We therefore use a map (key'ed by account) to store information about the latest sent inventory
 // reports This map is shared by the go routine that generates inventory reports and the go
 // routine that sends health reports. Access to the map is coordinated by a mutex.
+// The summary of the latest inventory collection cycle is shared the same way.
 type GatedReportInfo struct {
 	AccessGate              sync.RWMutex
 	AccountInventoryReports AccountK8SInventoryReports
+	LastCollection          *stats.Summary
 }
 
 type _NewUUID func() uuid.UUID
@@ -81,51 +94,137 @@ func GetGatedReportInfo() *GatedReportInfo {
 	}
 }
 
-func PeriodicallySendHealthReport(cfg *config.Application, ch intg.Channels, gatedReportInfo *GatedReportInfo) {
+// PeriodicallySendHealthReport sends a health report every health report interval once the agent is registered,
+// until the context is done
+func PeriodicallySendHealthReport(ctx context.Context, cfg *config.Application, ch intg.Channels, gatedReportInfo *GatedReportInfo) {
 	// Wait for registration with Enterprise to be completed
-	integration := <-ch.IntegrationObj
+	var integration *intg.Integration
+	select {
+	case integration = <-ch.IntegrationObj:
+	case <-ctx.Done():
+		return
+	}
 	log.Info("Health reporting started")
 
 	ticker := time.NewTicker(time.Duration(cfg.HealthReportIntervalSeconds) * time.Second)
+	defer ticker.Stop()
 
 	for {
 		log.Infof("Waiting %d seconds to send health report...", cfg.HealthReportIntervalSeconds)
 
-		_, _ = sendHealthReport(cfg, integration, gatedReportInfo, uuid.New, time.Now)
+		integration = sendHealthReportOrReregister(cfg, integration, gatedReportInfo)
+		if err := intg.RefreshConfiguration(cfg, integration); err != nil {
+			log.Warnf("Failed to refresh remote configuration: %v", err)
+		}
 		// log.Debugf("Start new health report: %s", <-ticker.C)
-		<-ticker.C
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Info("Health reporting stopped")
+			return
+		}
+	}
+}
+
+// sendHealthReportOrReregister sends a health report and, if Anchore no longer knows the integration, registers the
+// agent again. It returns the integration to send the next health report for.
+func sendHealthReportOrReregister(cfg *config.Application, integration *intg.Integration, gatedReportInfo *GatedReportInfo) *intg.Integration {
+	_, err := sendHealthReport(cfg, integration, gatedReportInfo, uuid.New, time.Now)
+	if !anchore.IntegrationNotFound(err) {
+		return integration
+	}
+
+	healtherrors.Record(healtherrors.CodeIntegrationNotFound, err)
+	reregistered, err := intg.Reregister(cfg, integration)
+	if err != nil {
+		log.Errorf("Failed to register again after Anchore lost integration %s: %v", integration.UUID, err)
+		return integration
+	}
+	return reregistered
+}
+
+// SendFinalHealthReport sends a last health report when the agent shuts down, giving up when the context is done
+func SendFinalHealthReport(ctx context.Context, cfg *config.Application, integration *intg.Integration, gatedReportInfo *GatedReportInfo) error {
+	return untilDone(ctx, "sending final health report", func() error {
+		log.Info("Sending final health report")
+		_, err := sendHealthReport(cfg, integration, gatedReportInfo, uuid.New, time.Now)
+		return err
+	})
+}
+
+// Deactivate marks the integration as deactivated in Anchore, giving up when the context is done
+func Deactivate(ctx context.Context, cfg *config.Application, integration *intg.Integration, reason string) error {
+	return untilDone(ctx, "deactivating integration "+integration.UUID, func() error {
+		anchoreDetails, err := secrets.ResolveAnchoreDetails(cfg, cfg.AnchoreDetails)
+		if err != nil {
+			return fmt.Errorf("failed to resolve Anchore credentials for integration deactivation: %w", err)
+		}
+		return intg.Deactivate(integration, anchoreDetails, reason, time.Now)
+	})
+}
+
+// untilDone runs the function in the background and returns its error, or gives up on the action when the context is
+// done first
+func untilDone(ctx context.Context, action string, f func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("gave up %s: %w", action, ctx.Err())
 	}
 }
 
 func sendHealthReport(cfg *config.Application, integration *intg.Integration, gatedReportInfo *GatedReportInfo, newUUID _NewUUID, _now _Now) (*HealthReport, error) {
 	healthReportID := newUUID().String()
 	lastReports := GetAccountReportInfoNoBlocking(gatedReportInfo, cfg, _now)
+	lastCollection := GetCollectionSummaryNoBlocking(gatedReportInfo)
+	errs := healtherrors.Drain()
 
 	now := _now().UTC()
-	integration.Uptime = &jstime.Duration{Duration: now.Sub(integration.StartedAt.Time)}
+	// the uptime is not set on the integration, which is shared with the final health report on shutdown
+	uptime := &jstime.Duration{Duration: now.Sub(integration.StartedAt.Time)}
 	healthReport := HealthReport{
 		UUID:            healthReportID,
 		ProtocolVersion: healthProtocolVersion,
 		Timestamp:       jstime.Datetime{Time: now},
-		Uptime:          integration.Uptime,
+		Uptime:          uptime,
 		HealthData: HealthData{
 			Type:                       healthDataType,
 			Version:                    healthDataVersion,
-			Errors:                     make(HealthReportErrors, 0),
+			Errors:                     errs,
+			Role:                       leader.Role(),
+			Shard:                      sharding.GetStatus(),
 			AccountK8sInventoryReports: lastReports,
+			LastCollection:             lastCollection,
+			Configuration:              intg.LastEffectiveConfig(),
 		},
 		HealthReportInterval: cfg.HealthReportIntervalSeconds,
 	}
 
-	log.Infof("Sending health report (uuid:%s) covering %d accounts", healthReport.UUID, len(healthReport.HealthData.AccountK8sInventoryReports))
+	log.Infof("Sending health report (uuid:%s) covering %d accounts with %d errors", healthReport.UUID,
+		len(healthReport.HealthData.AccountK8sInventoryReports), len(healthReport.HealthData.Errors))
 	requestBody, err := json.Marshal(healthReport)
 	if err != nil {
 		log.Errorf("failed to serialize integration registration as JSON: %v", err)
+		healtherrors.Requeue(errs)
 		return nil, err
 	}
-	_, err = anchore.Post(requestBody, integration.UUID, HealthReportAPIPathV2, cfg.AnchoreDetails, "health report")
+	anchoreDetails, err := secrets.ResolveAnchoreDetails(cfg, cfg.AnchoreDetails)
+	if err != nil {
+		log.Errorf("Failed to resolve Anchore credentials for health report: %v", err)
+		healtherrors.Requeue(errs)
+		return nil, err
+	}
+	_, err = anchore.Post(requestBody, integration.UUID, HealthReportAPIPathV2, anchoreDetails, "health report")
 	if err != nil {
 		log.Errorf("Failed to send health report to Anchore: %v", err)
+		// keep the errors for the next health report
+		healtherrors.Requeue(errs)
 		return nil, err
 	}
 	return &healthReport, nil
@@ -184,3 +283,25 @@ func SetReportInfoNoBlocking(accountName string, count int, reportInfo Inventory
 			reportInfo.Batches[count].SendTimestamp)
 	}
 }
+
+func GetCollectionSummaryNoBlocking(gatedReportInfo *GatedReportInfo) *stats.Summary {
+	locked := gatedReportInfo.AccessGate.TryLock()
+	if locked {
+		defer gatedReportInfo.AccessGate.Unlock()
+		return gatedReportInfo.LastCollection
+	}
+	log.Debugf("Unable to obtain mutex lock to get the latest collection summary. Continuing.")
+	return nil
+}
+
+func SetCollectionSummaryNoBlocking(summary stats.Summary, gatedReportInfo *GatedReportInfo) {
+	locked := gatedReportInfo.AccessGate.TryLock()
+	if locked {
+		defer gatedReportInfo.AccessGate.Unlock()
+		gatedReportInfo.LastCollection = &summary
+	} else {
+		// we prioritize no blocking over actually bookkeeping every collection summary
+		log.Debugf("Unable to obtain mutex lock to include the collection summary started %s in health report. Continuing.",
+			summary.StartTime)
+	}
+}
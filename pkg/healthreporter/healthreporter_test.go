@@ -1,14 +1,20 @@
 package healthreporter
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/anchore/k8s-inventory/internal/anchore"
 	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/internal/healtherrors"
+	"github.com/anchore/k8s-inventory/internal/stats"
 	jstime "github.com/anchore/k8s-inventory/internal/time"
 	"github.com/anchore/k8s-inventory/pkg/integration"
 	"github.com/google/uuid"
@@ -154,6 +160,288 @@ func TestSendHealthReport(t *testing.T) {
 	}
 }
 
+func TestSendHealthReportDrainsErrors(t *testing.T) {
+	defer gock.Off()
+	healtherrors.Drain()
+
+	integrationUUID := uuid.New().String()
+	postURL := fmt.Sprintf("/v2/system/integrations/%s/health-report", integrationUUID)
+	cfg := config.Application{
+		AnchoreDetails: config.AnchoreInfo{
+			URL:  "https://ancho.re",
+			User: "admin",
+		},
+		PollingIntervalSeconds:      30 * 60,
+		HealthReportIntervalSeconds: 60,
+	}
+	integrationInstance := &integration.Integration{
+		UUID:      integrationUUID,
+		StartedAt: jstime.Datetime{Time: now.UTC()},
+	}
+	nowMock := func() time.Time { return now }
+
+	healtherrors.Record(healtherrors.CodeListNodesForbidden, errors.New("nodes is forbidden"))
+	healtherrors.Record(healtherrors.CodeListNodesForbidden, errors.New("nodes is forbidden"))
+
+	// the errors are kept for the next health report if sending fails
+	gock.New("https://ancho.re").
+		Post(postURL).
+		Reply(http.StatusServiceUnavailable)
+	_, err := sendHealthReport(&cfg, integrationInstance, GetGatedReportInfo(), uuid.New, nowMock)
+	assert.Error(t, err)
+
+	gock.New("https://ancho.re").
+		Post(postURL).
+		MatchType("json").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return false, err
+			}
+			return strings.Contains(string(body), `"code":"K8S_LIST_NODES_FORBIDDEN"`), nil
+		}).
+		Reply(200)
+	result, err := sendHealthReport(&cfg, integrationInstance, GetGatedReportInfo(), uuid.New, nowMock)
+	assert.NoError(t, err)
+	if assert.Len(t, result.HealthData.Errors, 1) {
+		assert.Equal(t, healtherrors.CodeListNodesForbidden, result.HealthData.Errors[0].Code)
+		assert.Equal(t, "nodes is forbidden", result.HealthData.Errors[0].Message)
+		assert.Equal(t, 2, result.HealthData.Errors[0].Count)
+	}
+	assert.True(t, gock.IsDone())
+
+	assert.Empty(t, healtherrors.Drain())
+}
+
+func TestSendHealthReportIncludesCollectionSummary(t *testing.T) {
+	defer gock.Off()
+
+	integrationUUID := uuid.New().String()
+	cfg := config.Application{
+		AnchoreDetails: config.AnchoreInfo{
+			URL:  "https://ancho.re",
+			User: "admin",
+		},
+		PollingIntervalSeconds:      30 * 60,
+		HealthReportIntervalSeconds: 60,
+	}
+	integrationInstance := &integration.Integration{
+		UUID:      integrationUUID,
+		StartedAt: jstime.Datetime{Time: now.UTC()},
+	}
+	nowMock := func() time.Time { return now }
+
+	_, collectionStats := stats.NewContext(context.Background(), now)
+	collectionStats.AddNamespaceScanned(2, 3)
+	collectionStats.SetNodes(1)
+	summary := collectionStats.Summary(now.Add(5*time.Second), nil)
+
+	gatedReportInfo := GetGatedReportInfo()
+	SetCollectionSummaryNoBlocking(summary, gatedReportInfo)
+
+	gock.New("https://ancho.re").
+		Post(fmt.Sprintf("/v2/system/integrations/%s/health-report", integrationUUID)).
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return false, err
+			}
+			return strings.Contains(string(body), `"last_collection":{"start_time"`), nil
+		}).
+		Reply(200)
+	result, err := sendHealthReport(&cfg, integrationInstance, gatedReportInfo, uuid.New, nowMock)
+	assert.NoError(t, err)
+	assert.Equal(t, &summary, result.HealthData.LastCollection)
+	assert.True(t, gock.IsDone())
+}
+
+func TestSetCollectionSummaryNoBlockingSkipsWhenLockAlreadyTaken(t *testing.T) {
+	gatedReportInfo := GetGatedReportInfo()
+	gatedReportInfo.AccessGate.Lock()
+
+	SetCollectionSummaryNoBlocking(stats.Summary{Pods: 1}, gatedReportInfo)
+	assert.Nil(t, gatedReportInfo.LastCollection)
+	assert.Nil(t, GetCollectionSummaryNoBlocking(gatedReportInfo))
+
+	gatedReportInfo.AccessGate.Unlock()
+	SetCollectionSummaryNoBlocking(stats.Summary{Pods: 1}, gatedReportInfo)
+	assert.Equal(t, &stats.Summary{Pods: 1}, GetCollectionSummaryNoBlocking(gatedReportInfo))
+}
+
+func TestDeactivate(t *testing.T) {
+	defer gock.Off()
+	healtherrors.Drain()
+
+	integrationUUID := uuid.New().String()
+	cfg := config.Application{
+		AnchoreDetails: config.AnchoreInfo{
+			URL:  "https://ancho.re",
+			User: "admin",
+		},
+		PollingIntervalSeconds:      30 * 60,
+		HealthReportIntervalSeconds: 60,
+	}
+	integrationInstance := &integration.Integration{
+		UUID:      integrationUUID,
+		StartedAt: jstime.Datetime{Time: now.UTC()},
+	}
+	statusURL := fmt.Sprintf("/v2/system/integrations/%s/status", integrationUUID)
+
+	t.Run("deactivation", func(t *testing.T) {
+		gock.New("https://ancho.re").
+			Put(statusURL).
+			BodyString(`"state":"DEACTIVATED","reason":"agent was shut down"`).
+			Reply(200).
+			JSON(map[string]interface{}{})
+
+		err := Deactivate(context.Background(), &cfg, integrationInstance, "agent was shut down")
+		assert.NoError(t, err)
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("gives up after the timeout", func(t *testing.T) {
+		gock.New("https://ancho.re").
+			Put(statusURL).
+			Reply(200).
+			Delay(time.Second).
+			JSON(map[string]interface{}{})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := Deactivate(ctx, &cfg, integrationInstance, "agent was shut down")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
+
+func TestSendFinalHealthReport(t *testing.T) {
+	defer gock.Off()
+	healtherrors.Drain()
+
+	integrationUUID := uuid.New().String()
+	cfg := config.Application{
+		AnchoreDetails: config.AnchoreInfo{
+			URL:  "https://ancho.re",
+			User: "admin",
+		},
+		PollingIntervalSeconds:      30 * 60,
+		HealthReportIntervalSeconds: 60,
+	}
+	integrationInstance := &integration.Integration{
+		UUID:      integrationUUID,
+		StartedAt: jstime.Datetime{Time: now.UTC()},
+	}
+	healthReportURL := fmt.Sprintf("/v2/system/integrations/%s/health-report", integrationUUID)
+
+	t.Run("final health report", func(t *testing.T) {
+		gock.New("https://ancho.re").
+			Post(healthReportURL).
+			Reply(200)
+
+		err := SendFinalHealthReport(context.Background(), &cfg, integrationInstance, GetGatedReportInfo())
+		assert.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		// the uptime is only set in the health report
+		assert.Nil(t, integrationInstance.Uptime)
+	})
+
+	t.Run("gives up after the timeout", func(t *testing.T) {
+		gock.New("https://ancho.re").
+			Post(healthReportURL).
+			Reply(200).
+			Delay(time.Second)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := SendFinalHealthReport(ctx, &cfg, integrationInstance, GetGatedReportInfo())
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
+
+func TestPeriodicallySendHealthReportStops(t *testing.T) {
+	cfg := config.Application{HealthReportIntervalSeconds: 60}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		PeriodicallySendHealthReport(ctx, &cfg, integration.GetChannels(), GetGatedReportInfo())
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("health reporting did not stop when the context was done")
+	}
+}
+
+func TestSendHealthReportOrReregister(t *testing.T) {
+	defer gock.Off()
+
+	integrationUUID := uuid.New().String()
+	postURL := fmt.Sprintf("/v2/system/integrations/%s/health-report", integrationUUID)
+	cfg := config.Application{
+		AnchoreDetails: config.AnchoreInfo{
+			URL:  "https://ancho.re",
+			User: "admin",
+		},
+		PollingIntervalSeconds:      30 * 60,
+		HealthReportIntervalSeconds: 60,
+	}
+	integrationInstance := &integration.Integration{
+		UUID:      integrationUUID,
+		StartedAt: jstime.Datetime{Time: now.UTC()},
+	}
+
+	t.Run("health report sent", func(t *testing.T) {
+		healtherrors.Drain()
+		gock.New("https://ancho.re").
+			Post(postURL).
+			Reply(200)
+
+		assert.Same(t, integrationInstance, sendHealthReportOrReregister(&cfg, integrationInstance, GetGatedReportInfo()))
+		assert.Empty(t, healtherrors.Drain())
+	})
+
+	t.Run("health report API not supported", func(t *testing.T) {
+		healtherrors.Drain()
+		gock.New("https://ancho.re").
+			Post(postURL).
+			Reply(http.StatusNotFound).
+			JSON(map[string]interface{}{
+				"type":   "about:blank",
+				"title":  "Not Found",
+				"detail": "The requested URL was not found on the server. If you entered the URL manually please check your spelling and try again.",
+				"status": http.StatusNotFound,
+			})
+
+		assert.Same(t, integrationInstance, sendHealthReportOrReregister(&cfg, integrationInstance, GetGatedReportInfo()))
+		assert.Empty(t, healtherrors.Drain())
+	})
+
+	t.Run("integration not found", func(t *testing.T) {
+		healtherrors.Drain()
+		gock.New("https://ancho.re").
+			Post(postURL).
+			Reply(http.StatusNotFound).
+			JSON(map[string]interface{}{
+				"message":  "Integration not found",
+				"detail":   map[string]interface{}{},
+				"httpcode": http.StatusNotFound,
+			})
+
+		// the integration was not registered by this agent, so it cannot register again and is kept
+		assert.Same(t, integrationInstance, sendHealthReportOrReregister(&cfg, integrationInstance, GetGatedReportInfo()))
+		errs := healtherrors.Drain()
+		if assert.Len(t, errs, 1) {
+			assert.Equal(t, healtherrors.CodeIntegrationNotFound, errs[0].Code)
+		}
+	})
+}
+
 func TestGetAccountReportInfoNoBlockingWhenObtainingLockRemovesExpired(t *testing.T) {
 	gatedReportInfo := GatedReportInfo{
 		AccountInventoryReports: make(AccountK8SInventoryReports, 2),
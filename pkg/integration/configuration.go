@@ -0,0 +1,90 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/anchore/k8s-inventory/pkg/secrets"
+
+	"github.com/anchore/k8s-inventory/internal/anchore"
+	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/internal/log"
+)
+
+const IntegrationAPIPathV2 = "v2/system/integrations/{{id}}"
+
+// remoteConfiguration is the configuration that Anchore administrators set for the integration
+var remoteConfiguration atomic.Pointer[map[string]interface{}]
+
+// effectiveConfig is the configuration that was used for the last cycle
+var effectiveConfig atomic.Pointer[config.Application]
+
+func setRemoteConfiguration(configuration map[string]interface{}) {
+	previous := remoteConfiguration.Swap(&configuration)
+	if previous == nil && len(configuration) == 0 {
+		return
+	}
+	if previous == nil || !reflect.DeepEqual(*previous, configuration) {
+		log.Infof("Received remote configuration from Anchore, applying it from the next cycle")
+	}
+}
+
+// RefreshConfiguration retrieves the integration from Anchore to pick up changes to the remote configuration
+func RefreshConfiguration(appConfig *config.Application, integration *Integration) error {
+	if !appConfig.Registration.AcceptRemoteConfiguration {
+		return nil
+	}
+	anchoreDetails, err := secrets.ResolveAnchoreDetails(appConfig, appConfig.AnchoreDetails)
+	if err != nil {
+		return fmt.Errorf("unable to resolve Anchore credentials to get integration: %w", err)
+	}
+	responseBody, err := anchore.Get(integration.UUID, IntegrationAPIPathV2, anchoreDetails, "integration get")
+	if err != nil {
+		return fmt.Errorf("failed to get integration %s: %w", integration.UUID, err)
+	}
+	refreshed := Integration{}
+	if err := json.Unmarshal(*responseBody, &refreshed); err != nil {
+		return fmt.Errorf("failed to parse integration %s: %w", integration.UUID, err)
+	}
+	setRemoteConfiguration(refreshed.Configuration)
+	return nil
+}
+
+// EffectiveConfig returns the configuration to use for the next cycle, which is the local configuration with the
+// remote configuration applied to it. Settings that are set locally take precedence over the remote ones, and the
+// remote configuration is ignored entirely if any of its values is invalid.
+func EffectiveConfig(appConfig *config.Application) *config.Application {
+	effective := appConfig
+	remote := remoteConfiguration.Load()
+	if appConfig.Registration.AcceptRemoteConfiguration && remote != nil && len(*remote) > 0 {
+		withRemote, ignored, err := appConfig.WithRemoteConfiguration(*remote)
+		if err != nil {
+			log.Errorf("Ignoring remote configuration from Anchore: %v", err)
+		} else {
+			effective = withRemote
+		}
+		if len(ignored) > 0 {
+			log.Debugf("Ignoring remote settings that may not be set remotely or are set locally: %v", ignored)
+		}
+	}
+
+	previousInterval, previousLevel := appConfig.PollingIntervalSeconds, appConfig.Log.LevelOpt
+	if previous := effectiveConfig.Swap(effective); previous != nil {
+		previousInterval, previousLevel = previous.PollingIntervalSeconds, previous.Log.LevelOpt
+	}
+	if effective.PollingIntervalSeconds != previousInterval {
+		log.Infof("Polling interval changed to %d seconds", effective.PollingIntervalSeconds)
+	}
+	if effective.Log.LevelOpt != previousLevel {
+		log.Infof("Log level changed to %s", effective.Log.LevelOpt)
+		log.SetLevel(effective.Log.LevelOpt)
+	}
+	return effective
+}
+
+// LastEffectiveConfig returns the configuration that was used for the last cycle, or nil if no cycle was run yet
+func LastEffectiveConfig() *config.Application {
+	return effectiveConfig.Load()
+}
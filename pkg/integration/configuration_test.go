@@ -0,0 +1,103 @@
+package integration
+
+import (
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+)
+
+func resetConfiguration() {
+	remoteConfiguration.Store(nil)
+	effectiveConfig.Store(nil)
+}
+
+func newConfigurationTestConfig() *config.Application {
+	return &config.Application{
+		AnchoreDetails: config.AnchoreInfo{
+			URL:      "https://ancho.re",
+			User:     "admin",
+			Password: "foobar",
+		},
+		Registration:                config.RegistrationOptions{AcceptRemoteConfiguration: true},
+		PollingIntervalSeconds:      300,
+		HealthReportIntervalSeconds: 60,
+		MissingTagPolicy:            config.MissingTagConf{Policy: "digest"},
+		Log:                         config.Logging{LevelOpt: logrus.InfoLevel},
+	}
+}
+
+func TestRefreshConfiguration(t *testing.T) {
+	defer gock.Off()
+	defer resetConfiguration()
+
+	appConfig := newConfigurationTestConfig()
+	gock.New("https://ancho.re").
+		Get("v2/system/integrations/" + integrationInstance.UUID).
+		Reply(200).
+		JSON(map[string]interface{}{
+			"uuid": integrationInstance.UUID,
+			"configuration": map[string]interface{}{
+				"polling-interval-seconds": 600,
+			},
+		})
+
+	err := RefreshConfiguration(appConfig, &integrationInstance)
+	assert.NoError(t, err)
+	assert.True(t, gock.IsDone())
+	assert.Equal(t, map[string]interface{}{"polling-interval-seconds": float64(600)}, *remoteConfiguration.Load())
+
+	gock.New("https://ancho.re").
+		Get("v2/system/integrations/" + integrationInstance.UUID).
+		Reply(500)
+	err = RefreshConfiguration(appConfig, &integrationInstance)
+	assert.ErrorContains(t, err, "failed to get integration")
+	// the remote configuration is kept when it cannot be refreshed
+	assert.NotNil(t, remoteConfiguration.Load())
+}
+
+func TestRefreshConfigurationNotAccepted(t *testing.T) {
+	defer gock.Off()
+	defer resetConfiguration()
+
+	appConfig := newConfigurationTestConfig()
+	appConfig.Registration.AcceptRemoteConfiguration = false
+
+	err := RefreshConfiguration(appConfig, &integrationInstance)
+	assert.NoError(t, err)
+	assert.Nil(t, remoteConfiguration.Load())
+	assert.False(t, gock.HasUnmatchedRequest())
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	defer resetConfiguration()
+
+	appConfig := newConfigurationTestConfig()
+	assert.Same(t, appConfig, EffectiveConfig(appConfig))
+	assert.Same(t, appConfig, LastEffectiveConfig())
+
+	setRemoteConfiguration(map[string]interface{}{
+		"polling-interval-seconds": 600,
+		"log":                      map[string]interface{}{"level": "debug"},
+	})
+	effective := EffectiveConfig(appConfig)
+	assert.Equal(t, 600, effective.PollingIntervalSeconds)
+	assert.Equal(t, logrus.DebugLevel, effective.Log.LevelOpt)
+	assert.Same(t, effective, LastEffectiveConfig())
+	assert.Equal(t, 300, appConfig.PollingIntervalSeconds)
+
+	// an invalid remote configuration is ignored as a whole
+	setRemoteConfiguration(map[string]interface{}{
+		"polling-interval-seconds": 600,
+		"log":                      map[string]interface{}{"level": "chatty"},
+	})
+	assert.Same(t, appConfig, EffectiveConfig(appConfig))
+
+	// the remote configuration is ignored when it is not accepted
+	setRemoteConfiguration(map[string]interface{}{"polling-interval-seconds": 600})
+	appConfig.Registration.AcceptRemoteConfiguration = false
+	assert.Same(t, appConfig, EffectiveConfig(appConfig))
+}
@@ -5,16 +5,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sync/atomic"
 	"time"
 
+	"github.com/anchore/k8s-inventory/pkg/agentstatus"
 	"github.com/anchore/k8s-inventory/pkg/client"
+	"github.com/anchore/k8s-inventory/pkg/secrets"
 	"github.com/google/uuid"
 	"github.com/hashicorp/go-version"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/anchore/k8s-inventory/internal/anchore"
 	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/internal/healtherrors"
 	"github.com/anchore/k8s-inventory/internal/log"
+	"github.com/anchore/k8s-inventory/internal/metrics"
 	jstime "github.com/anchore/k8s-inventory/internal/time"
 	akiVersion "github.com/anchore/k8s-inventory/internal/version"
 )
@@ -23,8 +29,13 @@ var requiredAnchoreVersion, _ = version.NewVersion("5.11")
 
 var inventoryReportingActive = false
 
+// current is the integration that the agent is currently registered as
+var current atomic.Pointer[Integration]
+
 const Type = "k8s_inventory_agent"
 const RegisterAPIPathV2 = "v2/system/integrations/registration"
+const StatusAPIPathV2 = "v2/system/integrations/{{id}}/status"
+const LifeCycleStateDeactivated = "DEACTIVATED"
 const AppVersionLabel = "app.kubernetes.io/version"
 const devVersion = "dev"
 
@@ -72,6 +83,7 @@ type Integration struct {
 	HealthReportInterval   int                    `json:"health_report_interval,omitempty"`   // time in seconds between health reports
 	RegistrationID         string                 `json:"registration_id,omitempty"`          // uuid that integration used during registration
 	RegistrationInstanceID string                 `json:"registration_instance_id,omitempty"` // instance id used by the integration during registration
+	registration           *Registration          // registration that the integration was registered with, to register again
 }
 
 type Registration struct {
@@ -90,6 +102,7 @@ type Registration struct {
 	ClusterName            string              `json:"cluster_name,omitempty"`             // name of cluster where the integration instance runs
 	Namespace              string              `json:"namespace,omitempty"`                // uuid for namespace that the integration instance belongs to
 	HealthReportInterval   int                 `json:"health_report_interval,omitempty"`   // time in seconds between health reports
+	sharedInstanceID       bool                // registration instance id is the name of the Deployment, shared with the pods replacing this one
 }
 
 type _NewUUID func() uuid.UUID
@@ -99,8 +112,18 @@ type _Now func() time.Time
 func PerformRegistration(appConfig *config.Application, ch Channels) (*Integration, error) {
 	defer closeChannels(ch)
 
-	_, err := awaitVersion(appConfig.AnchoreDetails, ch, -1, 2*time.Second, 1*time.Hour)
+	anchoreDetails, err := secrets.ResolveAnchoreDetails(appConfig, appConfig.AnchoreDetails)
+	if err != nil {
+		log.Errorf("Unable to resolve Anchore credentials for registration: %v", err)
+		metrics.SetRegistrationState(metrics.RegistrationFailed)
+		agentstatus.RegistrationFailed(err)
+		return nil, err
+	}
+
+	_, err = awaitVersion(anchoreDetails, ch, -1, 2*time.Second, 1*time.Hour)
 	if err != nil {
+		metrics.SetRegistrationState(metrics.RegistrationFailed)
+		agentstatus.RegistrationFailed(err)
 		return nil, err
 	}
 
@@ -111,18 +134,28 @@ func PerformRegistration(appConfig *config.Application, ch Channels) (*Integrati
 	replicaCount, err := getReplicaCountFromK8s(k8sClient, namespace, name)
 	if err != nil {
 		log.Errorf("Failed to get replica count from K8s: %v", err)
+		healtherrors.Record(healtherrors.CodeRegistrationReplicaCount, err)
 	}
 	log.Debugf("Determined replica count from K8s: %d", replicaCount)
 	registrationInfo := getRegistrationInfo(appConfig, k8sClient, namespace, name, replicaCount, uuid.New, time.Now)
 
 	// Register this agent with enterprise
-	registeredIntegration, err := register(registrationInfo, appConfig.AnchoreDetails, -1,
+	registeredIntegration, err := register(registrationInfo, anchoreDetails, -1,
 		2*time.Second, 10*time.Minute, time.Now)
 	if err != nil {
 		log.Errorf("Unable to register agent: %v", err)
+		metrics.SetRegistrationState(metrics.RegistrationFailed)
+		agentstatus.RegistrationFailed(err)
 		return nil, err
 	}
 
+	registeredIntegration.registration = registrationInfo
+	current.Store(registeredIntegration)
+	if appConfig.Registration.AcceptRemoteConfiguration {
+		setRemoteConfiguration(registeredIntegration.Configuration)
+	}
+	metrics.SetRegistrationState(metrics.RegistrationRegistered)
+	agentstatus.RegistrationSucceeded(registeredIntegration.UUID)
 	enableHealthReporting(ch, registeredIntegration)
 
 	if !inventoryReportingActive {
@@ -132,6 +165,48 @@ func PerformRegistration(appConfig *config.Application, ch Channels) (*Integrati
 	return registeredIntegration, nil
 }
 
+// Current returns the integration that the agent is currently registered as, or nil if it is not registered
+func Current() *Integration {
+	return current.Load()
+}
+
+// Reregister registers the agent again with the registration that the stale integration was registered with, e.g.
+// after Anchore was restored from a backup or the integration was deleted, and returns the new integration
+func Reregister(appConfig *config.Application, stale *Integration) (*Integration, error) {
+	if stale.registration == nil {
+		return nil, fmt.Errorf("integration %s was not registered by this agent", stale.UUID)
+	}
+	log.Warnf("Anchore no longer knows integration %s, registering again", stale.UUID)
+	metrics.Reregistrations.Inc()
+
+	anchoreDetails, err := secrets.ResolveAnchoreDetails(appConfig, appConfig.AnchoreDetails)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve Anchore credentials for registration: %w", err)
+	}
+
+	if effective := LastEffectiveConfig(); effective != nil {
+		stale.registration.Configuration = effective
+	}
+	registeredIntegration, err := register(stale.registration, anchoreDetails, -1,
+		2*time.Second, 10*time.Minute, time.Now)
+	if err != nil {
+		metrics.SetRegistrationState(metrics.RegistrationFailed)
+		agentstatus.RegistrationFailed(err)
+		return nil, err
+	}
+
+	registeredIntegration.registration = stale.registration
+	// only replace the current integration if it was not replaced in the meantime
+	current.CompareAndSwap(stale, registeredIntegration)
+	if appConfig.Registration.AcceptRemoteConfiguration {
+		setRemoteConfiguration(registeredIntegration.Configuration)
+	}
+	metrics.SetRegistrationState(metrics.RegistrationRegistered)
+	agentstatus.RegistrationSucceeded(registeredIntegration.UUID)
+	log.Infof("Registered again, integration %s replaced by %s", stale.UUID, registeredIntegration.UUID)
+	return registeredIntegration, nil
+}
+
 func awaitVersion(anchoreDetails config.AnchoreInfo, ch Channels, maxRetry int, startBackoff, maxBackoff time.Duration) (*anchore.Version, error) {
 	attempt := 0
 	for {
@@ -150,6 +225,7 @@ func awaitVersion(anchoreDetails config.AnchoreInfo, ch Channels, maxRetry int,
 					log.Infof("Proceeding with integration registration since Enterprise v%s supports that", anchoreVersion.Service.Version)
 					return anchoreVersion, nil
 				}
+				metrics.SetRegistrationState(metrics.RegistrationUnsupported)
 				if !inventoryReportingActive {
 					log.Infof("Proceeding without integration registration and health reporting since Enterprise v%s does not support that",
 						anchoreVersion.Service.Version)
@@ -167,6 +243,7 @@ func awaitVersion(anchoreDetails config.AnchoreInfo, ch Channels, maxRetry int,
 
 		if anchore.ServerIsOffline(err) {
 			log.Infof("Anchore is offline. Will try again in %s", startBackoff)
+			healtherrors.Record(healtherrors.CodeRegistrationRetried, err)
 			retry = true
 		}
 
@@ -256,6 +333,7 @@ func register(registrationInfo *Registration, anchoreDetails config.AnchoreInfo,
 
 		if anchore.ServerIsOffline(err) {
 			log.Infof("Anchore is offline. Will try again in %s", startBackoff)
+			healtherrors.Record(healtherrors.CodeRegistrationRetried, err)
 			time.Sleep(startBackoff)
 			if startBackoff < maxBackoff {
 				startBackoff = min(startBackoff*2, maxBackoff)
@@ -298,9 +376,77 @@ func doRegister(registrationInfo *Registration, anchoreDetails config.AnchoreInf
 	return &registeredIntegration, err
 }
 
+// DeactivatesOnShutdown returns whether the integration is deactivated when the agent shuts down. A single replica
+// registers with the name of its Deployment as registration instance id, which the pod replacing it (e.g. during a
+// rolling update or a node drain) registers with too: its integration is only deactivated when the Deployment is
+// deleted or scaled to zero, not when the pod is replaced.
+func (i *Integration) DeactivatesOnShutdown(appConfig *config.Application) bool {
+	if !appConfig.Registration.DeactivateOnShutdown {
+		return false
+	}
+	return i.deactivatesOnShutdown(getK8sClient(appConfig), os.Getenv("POD_NAMESPACE"))
+}
+
+func (i *Integration) deactivatesOnShutdown(k8sClient *client.Client, namespace string) bool {
+	if i.registration == nil || !i.registration.sharedInstanceID {
+		return true
+	}
+	replaced, err := isBeingReplaced(k8sClient, namespace, i.RegistrationInstanceID)
+	if err != nil {
+		log.Warnf("Failed to determine whether the agent is being replaced, deactivating integration %s: %v", i.UUID, err)
+		return true
+	}
+	if replaced {
+		log.Infof("Not deactivating integration %s, Deployment %s still wants replicas which register as %s",
+			i.UUID, i.RegistrationInstanceID, i.RegistrationInstanceID)
+		return false
+	}
+	return true
+}
+
+// isBeingReplaced returns whether the Deployment still wants replicas, i.e. whether a pod shutting down is replaced by
+// another one rather than the Deployment being deleted or scaled to zero
+func isBeingReplaced(k8sClient *client.Client, namespace string, deploymentName string) (bool, error) {
+	if k8sClient == nil {
+		return false, fmt.Errorf("kubernetes client not initialized")
+	}
+	deployment, err := k8sClient.Clientset.AppsV1().Deployments(namespace).Get(context.Background(), deploymentName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	if deployment.DeletionTimestamp != nil {
+		return false, nil
+	}
+	return deployment.Spec.Replicas == nil || *deployment.Spec.Replicas > 0, nil
+}
+
+// Deactivate marks the integration as deactivated in Anchore, so that it is not expected to send health reports
+// anymore
+func Deactivate(integration *Integration, anchoreDetails config.AnchoreInfo, reason string, now _Now) error {
+	status := LifeCycleStatus{
+		State:     LifeCycleStateDeactivated,
+		Reason:    reason,
+		UpdatedAt: jstime.Datetime{Time: now().UTC()},
+	}
+	requestBody, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to serialize integration lifecycle status as JSON: %w", err)
+	}
+	_, err = anchore.Put(requestBody, integration.UUID, StatusAPIPathV2, anchoreDetails, "integration deactivation")
+	if err != nil {
+		return fmt.Errorf("failed to deactivate integration %s: %w", integration.UUID, err)
+	}
+	log.Infof("Deactivated integration %s (reason: %s)", integration.UUID, reason)
+	return nil
+}
+
 func getRegistrationInfo(appConfig *config.Application, k8sClient *client.Client,
 	namespace string, name string, replicaCount int32, newUUID _NewUUID, now _Now) *Registration {
 	var registrationID, registrationInstanceID, instanceName, description string
+	sharedInstanceID := false
 
 	log.Debugf("Attempting to determine values from K8s Deployment for Pod: %s in Namespace: %s",
 		name, namespace)
@@ -323,6 +469,7 @@ func getRegistrationInfo(appConfig *config.Application, k8sClient *client.Client
 	case instanceName != "":
 		log.Debugf("Using registration_instance_id: %s", instanceName)
 		registrationInstanceID = instanceName
+		sharedInstanceID = true
 	default:
 		log.Debugf("Generating UUIDv4 to use as registration_instance_id")
 		registrationInstanceID = newUUID().String()
@@ -352,6 +499,7 @@ func getRegistrationInfo(appConfig *config.Application, k8sClient *client.Client
 	instance := Registration{
 		RegistrationID:         registrationID,
 		RegistrationInstanceID: registrationInstanceID,
+		sharedInstanceID:       sharedInstanceID,
 		Type:                   Type,
 		Name:                   instanceName,
 		Description:            description,
@@ -361,7 +509,7 @@ func getRegistrationInfo(appConfig *config.Application, k8sClient *client.Client
 		Username:               appConfig.AnchoreDetails.User,
 		ExplicitlyAccountBound: explicitlyAccountBound,
 		Namespaces:             namespaces,
-		Configuration:          nil,
+		Configuration:          appConfig,
 		ClusterName:            appConfig.KubeConfig.Cluster,
 		Namespace:              namespace,
 		HealthReportInterval:   appConfig.HealthReportIntervalSeconds,
@@ -601,6 +601,141 @@ func TestRegister(t *testing.T) {
 	}
 }
 
+func TestDeactivate(t *testing.T) {
+	defer gock.Off()
+
+	anchoreDetails := config.AnchoreInfo{
+		URL:      "https://ancho.re",
+		User:     "admin",
+		Password: "foobar",
+	}
+	nowMock := func() time.Time { return now }
+
+	gock.New("https://ancho.re").
+		Put(fmt.Sprintf("v2/system/integrations/%s/status", integrationInstance.UUID)).
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"state":      LifeCycleStateDeactivated,
+			"reason":     "agent was shut down",
+			"updated_at": now.UTC().Format(time.RFC3339),
+		}).
+		Reply(200).
+		JSON(map[string]interface{}{})
+	err := Deactivate(&integrationInstance, anchoreDetails, "agent was shut down", nowMock)
+	assert.NoError(t, err)
+	assert.True(t, gock.IsDone())
+
+	gock.New("https://ancho.re").
+		Put(fmt.Sprintf("v2/system/integrations/%s/status", integrationInstance.UUID)).
+		Reply(http.StatusForbidden)
+	err = Deactivate(&integrationInstance, anchoreDetails, "agent was shut down", nowMock)
+	assert.ErrorContains(t, err, "failed to deactivate integration")
+	var apiClientError *anchore.APIClientError
+	if assert.ErrorAs(t, err, &apiClientError) {
+		assert.Equal(t, http.StatusForbidden, apiClientError.HTTPStatusCode)
+	}
+}
+
+func TestDeactivatesOnShutdown(t *testing.T) {
+	assert.False(t, (&Integration{UUID: "1"}).DeactivatesOnShutdown(&config.Application{}))
+
+	scaledToZero := *deployment.DeepCopy()
+	scaledToZero.Spec.Replicas = new(int32)
+	deleted := *deployment.DeepCopy()
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	deleted.Finalizers = []string{"foregroundDeletion"}
+
+	// a single replica shares the registration identity of its Deployment with the pod replacing it
+	shared := &Integration{UUID: "1", RegistrationInstanceID: deployment.Name, registration: &Registration{sharedInstanceID: true}}
+	perPod := &Integration{UUID: "2", RegistrationInstanceID: "test-pod", registration: &Registration{}}
+	tests := []struct {
+		name        string
+		integration *Integration
+		k8sClient   *client.Client
+		want        bool
+	}{
+		{
+			name:        "pod with its own registration identity",
+			integration: perPod,
+			k8sClient:   &client.Client{Clientset: fake.NewClientset(&deployment)},
+			want:        true,
+		},
+		{
+			name:        "single replica replaced by another pod",
+			integration: shared,
+			k8sClient:   &client.Client{Clientset: fake.NewClientset(&deployment)},
+			want:        false,
+		},
+		{
+			name:        "single replica of a deleted Deployment",
+			integration: shared,
+			k8sClient:   &client.Client{Clientset: fake.NewClientset()},
+			want:        true,
+		},
+		{
+			name:        "single replica of a Deployment being deleted",
+			integration: shared,
+			k8sClient:   &client.Client{Clientset: fake.NewClientset(&deleted)},
+			want:        true,
+		},
+		{
+			name:        "single replica of a Deployment scaled to zero",
+			integration: shared,
+			k8sClient:   &client.Client{Clientset: fake.NewClientset(&scaledToZero)},
+			want:        true,
+		},
+		{
+			name:        "single replica without Kubernetes client",
+			integration: shared,
+			k8sClient:   nil,
+			want:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.integration.deactivatesOnShutdown(tt.k8sClient, deployment.Namespace))
+		})
+	}
+}
+
+func TestReregister(t *testing.T) {
+	defer gock.Off()
+	defer current.Store(nil)
+
+	appConfig := config.Application{
+		AnchoreDetails: config.AnchoreInfo{
+			URL:      "https://ancho.re",
+			User:     "admin",
+			Password: "foobar",
+		},
+	}
+	registration := &Registration{
+		RegistrationID:         "de7ef8ab-6f2a-4ab3-9fea-9b2e5a6b3b9c",
+		RegistrationInstanceID: "1111223344",
+		Type:                   Type,
+		StartedAt:              jstime.Datetime{Time: now.UTC()},
+	}
+	stale := &Integration{UUID: "stale-uuid", registration: registration}
+	current.Store(stale)
+
+	gock.New("https://ancho.re").
+		Post("v2/system/integrations/registration").
+		MatchType("json").
+		BodyString(`"registration_id":"de7ef8ab-6f2a-4ab3-9fea-9b2e5a6b3b9c","registration_instance_id":"1111223344"`).
+		Reply(200).
+		JSON(integration)
+
+	reregistered, err := Reregister(&appConfig, stale)
+	assert.NoError(t, err)
+	assert.Equal(t, integrationInstance.UUID, reregistered.UUID)
+	assert.Same(t, registration, reregistered.registration)
+	assert.Same(t, reregistered, Current())
+	assert.True(t, gock.IsDone())
+
+	_, err = Reregister(&appConfig, &Integration{UUID: "unknown-uuid"})
+	assert.ErrorContains(t, err, "was not registered by this agent")
+}
+
 func TestGetRegistrationInfo(t *testing.T) {
 	uuids := []uuid.UUID{uuid.New(), uuid.New()}
 	timestamps := []time.Time{time.Now()}
@@ -651,7 +786,6 @@ func TestGetRegistrationInfo(t *testing.T) {
 				Username:               "admin",
 				ExplicitlyAccountBound: []string{"account3"},
 				Namespaces:             []string{"ns3"},
-				Configuration:          nil,
 				ClusterName:            "k8s-cluster1",
 				Namespace:              "test-namespace",
 				HealthReportInterval:   60,
@@ -696,7 +830,6 @@ func TestGetRegistrationInfo(t *testing.T) {
 				Username:               "admin",
 				ExplicitlyAccountBound: []string{"account3"},
 				Namespaces:             []string{"ns3"},
-				Configuration:          nil,
 				ClusterName:            "k8s-cluster1",
 				Namespace:              "test-namespace",
 				HealthReportInterval:   60,
@@ -739,7 +872,6 @@ func TestGetRegistrationInfo(t *testing.T) {
 				Username:               "admin",
 				ExplicitlyAccountBound: []string{"account3"},
 				Namespaces:             []string{"ns3"},
-				Configuration:          nil,
 				ClusterName:            "k8s-cluster1",
 				Namespace:              "test-namespace",
 				HealthReportInterval:   60,
@@ -773,6 +905,7 @@ func TestGetRegistrationInfo(t *testing.T) {
 			want: &Registration{
 				RegistrationID:         "test-deployment-uid",
 				RegistrationInstanceID: deployment.Name,
+				sharedInstanceID:       true,
 				Type:                   Type,
 				Name:                   "test-deployment-k8s-inventory",
 				Description:            "",
@@ -782,7 +915,6 @@ func TestGetRegistrationInfo(t *testing.T) {
 				Username:               "admin",
 				ExplicitlyAccountBound: []string{"account3"},
 				Namespaces:             []string{"ns3"},
-				Configuration:          nil,
 				ClusterName:            "k8s-cluster1",
 				Namespace:              "test-namespace",
 				HealthReportInterval:   60,
@@ -806,6 +938,8 @@ func TestGetRegistrationInfo(t *testing.T) {
 			result := getRegistrationInfo(tt.args.config, tt.args.c, tt.args.namespace,
 				tt.args.name, tt.args.replicaCount, NewUUIDMock, nowMock)
 			assert.NotNil(t, result)
+			// the configuration is sent along, so that Anchore knows the effective configuration of the agent
+			tt.want.Configuration = tt.args.config
 			assert.Equal(t, tt.want, result)
 		})
 	}
@@ -0,0 +1,246 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DiffObject is a namespace, pod or container that was added or removed between two reports. Name is the full name
+// of the object, i.e. namespace/pod/container for containers.
+type DiffObject struct {
+	Name        string `json:"name"`
+	UID         string `json:"uid"`
+	ImageTag    string `json:"image_tag,omitempty"`
+	ImageDigest string `json:"image_digest,omitempty"`
+}
+
+// ImageChange is a container (matched by its pod UID and name) that runs a different image in the new report
+type ImageChange struct {
+	Name      string `json:"name"`
+	OldTag    string `json:"old_image_tag"`
+	NewTag    string `json:"new_image_tag"`
+	OldDigest string `json:"old_image_digest"`
+	NewDigest string `json:"new_image_digest"`
+}
+
+type ReportDiff struct {
+	AddedNamespaces   []DiffObject `json:"added_namespaces"`
+	RemovedNamespaces []DiffObject `json:"removed_namespaces"`
+	AddedPods         []DiffObject `json:"added_pods"`
+	RemovedPods       []DiffObject `json:"removed_pods"`
+	AddedContainers   []DiffObject `json:"added_containers"`
+	RemovedContainers []DiffObject `json:"removed_containers"`
+	// TagChanges are containers whose image tag changed
+	TagChanges []ImageChange `json:"image_tag_changes"`
+	// DigestChanges are containers whose image tag is unchanged, but now refers to a different digest
+	DigestChanges []ImageChange `json:"image_digest_changes"`
+}
+
+// names resolves the display names of the objects of a report
+type names struct {
+	namespaces map[string]string
+	pods       map[string]string
+}
+
+func newNames(report Report) names {
+	n := names{namespaces: make(map[string]string), pods: make(map[string]string)}
+	for _, ns := range report.Namespaces {
+		n.namespaces[ns.UID] = ns.Name
+	}
+	for _, pod := range report.Pods {
+		n.pods[pod.UID] = n.namespace(pod.NamespaceUID) + "/" + pod.Name
+	}
+	return n
+}
+
+func (n names) namespace(uid string) string {
+	if name, ok := n.namespaces[uid]; ok {
+		return name
+	}
+	return "<unknown namespace>"
+}
+
+func (n names) pod(uid string) string {
+	if name, ok := n.pods[uid]; ok {
+		return name
+	}
+	return "<unknown pod>"
+}
+
+func containerKey(container Container) string {
+	return container.PodUID + "/" + container.Name
+}
+
+// Diff compares two inventory reports. Namespaces and pods are matched by their UIDs, and containers by the UID of
+// their pod and their name (the container ID changes whenever a container is restarted).
+func Diff(oldReport, newReport Report) ReportDiff {
+	oldNames, newNames := newNames(oldReport), newNames(newReport)
+	diff := ReportDiff{
+		AddedNamespaces:   make([]DiffObject, 0),
+		RemovedNamespaces: make([]DiffObject, 0),
+		AddedPods:         make([]DiffObject, 0),
+		RemovedPods:       make([]DiffObject, 0),
+		AddedContainers:   make([]DiffObject, 0),
+		RemovedContainers: make([]DiffObject, 0),
+		TagChanges:        make([]ImageChange, 0),
+		DigestChanges:     make([]ImageChange, 0),
+	}
+
+	oldNamespaces := make(map[string]Namespace)
+	for _, ns := range oldReport.Namespaces {
+		oldNamespaces[ns.UID] = ns
+	}
+	newNamespaces := make(map[string]Namespace)
+	for _, ns := range newReport.Namespaces {
+		newNamespaces[ns.UID] = ns
+		if _, ok := oldNamespaces[ns.UID]; !ok {
+			diff.AddedNamespaces = append(diff.AddedNamespaces, DiffObject{Name: ns.Name, UID: ns.UID})
+		}
+	}
+	for _, ns := range oldReport.Namespaces {
+		if _, ok := newNamespaces[ns.UID]; !ok {
+			diff.RemovedNamespaces = append(diff.RemovedNamespaces, DiffObject{Name: ns.Name, UID: ns.UID})
+		}
+	}
+
+	oldPods := make(map[string]Pod)
+	for _, pod := range oldReport.Pods {
+		oldPods[pod.UID] = pod
+	}
+	newPods := make(map[string]Pod)
+	for _, pod := range newReport.Pods {
+		newPods[pod.UID] = pod
+		if _, ok := oldPods[pod.UID]; !ok {
+			diff.AddedPods = append(diff.AddedPods, DiffObject{Name: newNames.pod(pod.UID), UID: pod.UID})
+		}
+	}
+	for _, pod := range oldReport.Pods {
+		if _, ok := newPods[pod.UID]; !ok {
+			diff.RemovedPods = append(diff.RemovedPods, DiffObject{Name: oldNames.pod(pod.UID), UID: pod.UID})
+		}
+	}
+
+	diffContainers(&diff, oldReport, newReport, oldNames, newNames)
+	diff.sort()
+	return diff
+}
+
+func diffContainers(diff *ReportDiff, oldReport, newReport Report, oldNames, newNames names) {
+	containerObject := func(container Container, n names) DiffObject {
+		return DiffObject{
+			Name:        n.pod(container.PodUID) + "/" + container.Name,
+			UID:         container.ID,
+			ImageTag:    container.ImageTag,
+			ImageDigest: container.ImageDigest,
+		}
+	}
+
+	oldContainers := make(map[string]Container)
+	for _, container := range oldReport.Containers {
+		oldContainers[containerKey(container)] = container
+	}
+	newContainers := make(map[string]Container)
+	for _, container := range newReport.Containers {
+		newContainers[containerKey(container)] = container
+
+		old, ok := oldContainers[containerKey(container)]
+		if !ok {
+			diff.AddedContainers = append(diff.AddedContainers, containerObject(container, newNames))
+			continue
+		}
+		change := ImageChange{
+			Name:      newNames.pod(container.PodUID) + "/" + container.Name,
+			OldTag:    old.ImageTag,
+			NewTag:    container.ImageTag,
+			OldDigest: old.ImageDigest,
+			NewDigest: container.ImageDigest,
+		}
+		switch {
+		case old.ImageTag != container.ImageTag:
+			diff.TagChanges = append(diff.TagChanges, change)
+		case old.ImageDigest != container.ImageDigest:
+			diff.DigestChanges = append(diff.DigestChanges, change)
+		}
+	}
+	for _, container := range oldReport.Containers {
+		if _, ok := newContainers[containerKey(container)]; !ok {
+			diff.RemovedContainers = append(diff.RemovedContainers, containerObject(container, oldNames))
+		}
+	}
+}
+
+func (diff *ReportDiff) sort() {
+	for _, objects := range [][]DiffObject{diff.AddedNamespaces, diff.RemovedNamespaces, diff.AddedPods,
+		diff.RemovedPods, diff.AddedContainers, diff.RemovedContainers} {
+		sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	}
+	for _, changes := range [][]ImageChange{diff.TagChanges, diff.DigestChanges} {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	}
+}
+
+// IsEmpty returns whether the reports have the same namespaces, pods and containers, running the same images
+func (diff *ReportDiff) IsEmpty() bool {
+	return len(diff.AddedNamespaces)+len(diff.RemovedNamespaces)+len(diff.AddedPods)+len(diff.RemovedPods)+
+		len(diff.AddedContainers)+len(diff.RemovedContainers)+len(diff.TagChanges)+len(diff.DigestChanges) == 0
+}
+
+// WriteDiff writes the differences between two reports as text or JSON
+func WriteDiff(w io.Writer, diff ReportDiff, output string) error {
+	switch output {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	case "text", "":
+	default:
+		return fmt.Errorf("unsupported output format %q, must be one of [text json]", output)
+	}
+
+	var sb strings.Builder
+	if diff.IsEmpty() {
+		sb.WriteString("No differences\n")
+	}
+	writeObjects := func(kind string, added, removed []DiffObject) {
+		if len(added) == 0 && len(removed) == 0 {
+			return
+		}
+		fmt.Fprintf(&sb, "%s: %d added, %d removed\n", kind, len(added), len(removed))
+		writeObject := func(prefix string, object DiffObject) {
+			fmt.Fprintf(&sb, "  %s %s (%s)", prefix, object.Name, object.UID)
+			if object.ImageTag != "" || object.ImageDigest != "" {
+				fmt.Fprintf(&sb, " %s@%s", object.ImageTag, object.ImageDigest)
+			}
+			sb.WriteString("\n")
+		}
+		for _, object := range added {
+			writeObject("+", object)
+		}
+		for _, object := range removed {
+			writeObject("-", object)
+		}
+	}
+	writeObjects("Namespaces", diff.AddedNamespaces, diff.RemovedNamespaces)
+	writeObjects("Pods", diff.AddedPods, diff.RemovedPods)
+	writeObjects("Containers", diff.AddedContainers, diff.RemovedContainers)
+
+	if len(diff.TagChanges) > 0 {
+		fmt.Fprintf(&sb, "Image tag changes: %d\n", len(diff.TagChanges))
+		for _, change := range diff.TagChanges {
+			fmt.Fprintf(&sb, "  ~ %s: %s -> %s\n", change.Name, change.OldTag, change.NewTag)
+		}
+	}
+	if len(diff.DigestChanges) > 0 {
+		fmt.Fprintf(&sb, "Image digest changes under the same tag: %d\n", len(diff.DigestChanges))
+		for _, change := range diff.DigestChanges {
+			fmt.Fprintf(&sb, "  ~ %s: %s %s -> %s\n", change.Name, change.NewTag, change.OldDigest, change.NewDigest)
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,87 @@
+package inventory
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	oldDiffReport = Report{
+		Namespaces: []Namespace{{Name: "default", UID: "ns-1"}, {Name: "legacy", UID: "ns-2"}},
+		Pods: []Pod{
+			{Name: "web", UID: "pod-1", NamespaceUID: "ns-1"},
+			{Name: "batch", UID: "pod-2", NamespaceUID: "ns-2"},
+		},
+		Containers: []Container{
+			{ID: "c-1", Name: "nginx", PodUID: "pod-1", ImageTag: "nginx:1.24", ImageDigest: "sha256:aaa"},
+			{ID: "c-2", Name: "redis", PodUID: "pod-1", ImageTag: "redis:latest", ImageDigest: "sha256:bbb"},
+			{ID: "c-3", Name: "job", PodUID: "pod-2", ImageTag: "job:1", ImageDigest: "sha256:ccc"},
+		},
+	}
+	newDiffReport = Report{
+		Namespaces: []Namespace{{Name: "default", UID: "ns-1"}, {Name: "team-a", UID: "ns-3"}},
+		Pods: []Pod{
+			{Name: "web", UID: "pod-1", NamespaceUID: "ns-1"},
+			{Name: "api", UID: "pod-3", NamespaceUID: "ns-3"},
+		},
+		Containers: []Container{
+			// restarted, so the container ID changed
+			{ID: "c-4", Name: "nginx", PodUID: "pod-1", ImageTag: "nginx:1.25", ImageDigest: "sha256:ddd"},
+			{ID: "c-2", Name: "redis", PodUID: "pod-1", ImageTag: "redis:latest", ImageDigest: "sha256:eee"},
+			{ID: "c-5", Name: "api", PodUID: "pod-3", ImageTag: "api:2", ImageDigest: "sha256:fff"},
+		},
+	}
+)
+
+func TestDiff(t *testing.T) {
+	diff := Diff(oldDiffReport, newDiffReport)
+
+	assert.Equal(t, []DiffObject{{Name: "team-a", UID: "ns-3"}}, diff.AddedNamespaces)
+	assert.Equal(t, []DiffObject{{Name: "legacy", UID: "ns-2"}}, diff.RemovedNamespaces)
+	assert.Equal(t, []DiffObject{{Name: "team-a/api", UID: "pod-3"}}, diff.AddedPods)
+	assert.Equal(t, []DiffObject{{Name: "legacy/batch", UID: "pod-2"}}, diff.RemovedPods)
+	assert.Equal(t, []DiffObject{{Name: "team-a/api/api", UID: "c-5", ImageTag: "api:2", ImageDigest: "sha256:fff"}},
+		diff.AddedContainers)
+	assert.Equal(t, []DiffObject{{Name: "legacy/batch/job", UID: "c-3", ImageTag: "job:1", ImageDigest: "sha256:ccc"}},
+		diff.RemovedContainers)
+	assert.Equal(t, []ImageChange{{Name: "default/web/nginx", OldTag: "nginx:1.24", NewTag: "nginx:1.25",
+		OldDigest: "sha256:aaa", NewDigest: "sha256:ddd"}}, diff.TagChanges)
+	assert.Equal(t, []ImageChange{{Name: "default/web/redis", OldTag: "redis:latest", NewTag: "redis:latest",
+		OldDigest: "sha256:bbb", NewDigest: "sha256:eee"}}, diff.DigestChanges)
+	assert.False(t, diff.IsEmpty())
+
+	same := Diff(oldDiffReport, oldDiffReport)
+	assert.True(t, same.IsEmpty())
+}
+
+func TestWriteDiff(t *testing.T) {
+	diff := Diff(oldDiffReport, newDiffReport)
+
+	var sb strings.Builder
+	assert.NoError(t, WriteDiff(&sb, diff, "text"))
+	assert.Equal(t, `Namespaces: 1 added, 1 removed
+  + team-a (ns-3)
+  - legacy (ns-2)
+Pods: 1 added, 1 removed
+  + team-a/api (pod-3)
+  - legacy/batch (pod-2)
+Containers: 1 added, 1 removed
+  + team-a/api/api (c-5) api:2@sha256:fff
+  - legacy/batch/job (c-3) job:1@sha256:ccc
+Image tag changes: 1
+  ~ default/web/nginx: nginx:1.24 -> nginx:1.25
+Image digest changes under the same tag: 1
+  ~ default/web/redis: redis:latest sha256:bbb -> sha256:eee
+`, sb.String())
+
+	sb.Reset()
+	assert.NoError(t, WriteDiff(&sb, diff, "json"))
+	decoded := ReportDiff{}
+	assert.NoError(t, json.Unmarshal([]byte(sb.String()), &decoded))
+	assert.Equal(t, diff, decoded)
+
+	assert.Error(t, WriteDiff(&sb, diff, "yaml"))
+}
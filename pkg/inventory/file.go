@@ -0,0 +1,52 @@
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ReadReport reads an inventory report from a JSON file
+func ReadReport(path string) (Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to open inventory report: %w", err)
+	}
+	defer f.Close()
+
+	report := Report{}
+	if err := json.NewDecoder(f).Decode(&report); err != nil {
+		return Report{}, fmt.Errorf("failed to parse inventory report %s: %w", path, err)
+	}
+	return report, nil
+}
+
+// ReadReports reads all the inventory reports from a file, either a single JSON report or a stream of reports
+// (e.g. NDJSON, or the output of --verbose-inventory-reports for a batched inventory)
+func ReadReports(path string) ([]Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open inventory report: %w", err)
+	}
+	defer f.Close()
+
+	reports := make([]Report, 0)
+	dec := json.NewDecoder(f)
+	for {
+		report := Report{}
+		err := dec.Decode(&report)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse inventory report %d in %s: %w", len(reports)+1, path, err)
+		}
+		reports = append(reports, report)
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("no inventory reports found in %s", path)
+	}
+	return reports, nil
+}
@@ -0,0 +1,56 @@
+package inventory
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	contents, err := json.Marshal(oldDiffReport)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, contents, 0600))
+
+	report, err := ReadReport(path)
+	assert.NoError(t, err)
+	assert.Equal(t, oldDiffReport, report)
+
+	assert.NoError(t, os.WriteFile(path, []byte("not json"), 0600))
+	_, err = ReadReport(path)
+	assert.ErrorContains(t, err, "failed to parse inventory report")
+}
+
+func TestReadReports(t *testing.T) {
+	dir := t.TempDir()
+	oldContents, err := json.Marshal(oldDiffReport)
+	assert.NoError(t, err)
+	newContents, err := json.MarshalIndent(newDiffReport, "", "  ")
+	assert.NoError(t, err)
+
+	single := filepath.Join(dir, "single.json")
+	assert.NoError(t, os.WriteFile(single, newContents, 0600))
+	reports, err := ReadReports(single)
+	assert.NoError(t, err)
+	assert.Equal(t, []Report{newDiffReport}, reports)
+
+	// NDJSON, and the indented output of --verbose-inventory-reports
+	stream := filepath.Join(dir, "stream.json")
+	assert.NoError(t, os.WriteFile(stream, append(append(oldContents, '\n'), newContents...), 0600))
+	reports, err = ReadReports(stream)
+	assert.NoError(t, err)
+	assert.Equal(t, []Report{oldDiffReport, newDiffReport}, reports)
+
+	empty := filepath.Join(dir, "empty.json")
+	assert.NoError(t, os.WriteFile(empty, []byte("\n"), 0600))
+	_, err = ReadReports(empty)
+	assert.ErrorContains(t, err, "no inventory reports found")
+
+	invalid := filepath.Join(dir, "invalid.json")
+	assert.NoError(t, os.WriteFile(invalid, append(oldContents, []byte("\n{")...), 0600))
+	_, err = ReadReports(invalid)
+	assert.ErrorContains(t, err, "failed to parse inventory report 2")
+}
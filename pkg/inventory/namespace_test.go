@@ -1,16 +1,28 @@
 package inventory
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake"
 
+	"github.com/anchore/k8s-inventory/internal/matcher"
+	"github.com/anchore/k8s-inventory/internal/stats"
 	"github.com/anchore/k8s-inventory/pkg/client"
 )
 
+func excludeMatcher(t *testing.T, excludes []string) *matcher.Matcher {
+	m, err := matcher.NewNamespaces(excludes)
+	require.NoError(t, err)
+	return m
+}
+
 func Test_fetchNamespaces(t *testing.T) {
 	type args struct {
 		c                  client.Client
@@ -448,13 +460,14 @@ func Test_fetchNamespaces(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got, err := FetchNamespaces(
+				context.Background(),
 				tt.args.c,
 				tt.args.batchSize,
 				tt.args.timeout,
-				tt.args.excludes,
+				excludeMatcher(t, tt.args.excludes),
 				tt.args.includes,
-				tt.args.includeAnnotations,
-				tt.args.includeLabels,
+				matcher.MustNew(tt.args.includeAnnotations...),
+				matcher.MustNew(tt.args.includeLabels...),
 				tt.args.disableMetadata,
 			)
 			if (err != nil) != tt.wantErr {
@@ -464,3 +477,37 @@ func Test_fetchNamespaces(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchNamespacesCountsSkipped(t *testing.T) {
+	namespace := func(name string) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name + "-uid")}}
+	}
+	c := client.Client{
+		Clientset: fake.NewClientset(namespace("default"), namespace("kube-system"), namespace("kube-public"), namespace("app")),
+	}
+
+	ctx, collectionStats := stats.NewContext(context.Background(), time.Now())
+	got, err := FetchNamespaces(ctx, c, 100, 10, excludeMatcher(t, []string{"kube-.*"}), nil, nil, nil, false)
+	assert.NoError(t, err)
+	assert.Len(t, got, 2)
+	assert.Equal(t, 2, collectionStats.Summary(time.Now(), nil).NamespacesSkipped)
+
+	ctx, collectionStats = stats.NewContext(context.Background(), time.Now())
+	got, err = FetchNamespaces(ctx, c, 100, 10, nil, []string{"app"}, nil, nil, false)
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+	assert.Equal(t, 3, collectionStats.Summary(time.Now(), nil).NamespacesSkipped)
+}
+
+func TestSelectNamespaces(t *testing.T) {
+	namespaces := []v1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "default", UID: "default-uid", Labels: map[string]string{"team": "a", "tier": "web"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: "kube-system-uid"}},
+	}
+
+	got := SelectNamespaces(namespaces, excludeMatcher(t, []string{"kube-.*"}), nil, nil, matcher.MustNew("^team$"), false)
+	assert.Equal(t, []Namespace{{Name: "default", UID: "default-uid", Labels: map[string]string{"team": "a"}}}, got)
+
+	got = SelectNamespaces(namespaces, nil, []string{"kube-system", "missing"}, nil, nil, true)
+	assert.Equal(t, []Namespace{{Name: "kube-system", UID: "kube-system-uid"}}, got)
+}
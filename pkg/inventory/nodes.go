@@ -3,14 +3,22 @@ package inventory
 import (
 	"context"
 	"fmt"
+	"time"
 
-	"github.com/anchore/k8s-inventory/internal/log"
+	"github.com/anchore/k8s-inventory/internal/healtherrors"
+	"github.com/anchore/k8s-inventory/internal/matcher"
+	"github.com/anchore/k8s-inventory/internal/metrics"
+	"github.com/anchore/k8s-inventory/internal/tracker"
 	"github.com/anchore/k8s-inventory/pkg/client"
+	"github.com/anchore/k8s-inventory/pkg/tracing"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func FetchNodes(c client.Client, batchSize, timeout int64, includeAnnotations, includeLabels []string, disableMetadata bool) (map[string]Node, error) {
+func FetchNodes(ctx context.Context, c client.Client, batchSize, timeout int64, includeAnnotations, includeLabels *matcher.Matcher, disableMetadata bool) (_ map[string]Node, err error) {
+	defer tracker.TrackPhaseTime(time.Now(), metrics.PhaseListNodes, "Fetching nodes")
+	ctx, span := tracing.Tracer().Start(ctx, "FetchNodes")
+	defer func() { tracing.End(span, err) }()
 	nodes := make(map[string]Node)
 
 	cont := ""
@@ -21,11 +29,14 @@ func FetchNodes(c client.Client, batchSize, timeout int64, includeAnnotations, i
 			TimeoutSeconds: &timeout,
 		}
 
-		list, err := c.Clientset.CoreV1().Nodes().List(context.Background(), opts)
+		list, err := c.Clientset.CoreV1().Nodes().List(ctx, opts)
 		if err != nil {
 			if k8sErrors.IsForbidden(err) {
-				log.Warnf("failed to list nodes: %w", err)
-				return nil, nil
+				// the caller decides whether to collect the inventory without the nodes, k8sErrors.IsForbidden
+				// still reports the wrapped error
+				healtherrors.Record(healtherrors.CodeListNodesForbidden, err)
+			} else {
+				healtherrors.Record(healtherrors.KubernetesCode(healtherrors.CodeListNodes, err), err)
 			}
 			return nil, fmt.Errorf("failed to list nodes: %w", err)
 		}
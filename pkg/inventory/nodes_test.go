@@ -1,13 +1,20 @@
 package inventory
 
 import (
+	"context"
+	"errors"
 	"testing"
 
+	"github.com/anchore/k8s-inventory/internal/healtherrors"
+	"github.com/anchore/k8s-inventory/internal/matcher"
 	"github.com/anchore/k8s-inventory/pkg/client"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestFetchNodes(t *testing.T) {
@@ -174,7 +181,7 @@ func TestFetchNodes(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := FetchNodes(tt.args.c, tt.args.batchSize, tt.args.timeout, tt.args.includeAnnotations, tt.args.includeLabels, tt.args.disableMetadata)
+			got, err := FetchNodes(context.Background(), tt.args.c, tt.args.batchSize, tt.args.timeout, matcher.MustNew(tt.args.includeAnnotations...), matcher.MustNew(tt.args.includeLabels...), tt.args.disableMetadata)
 			if (err != nil) != tt.wantErr {
 				assert.Error(t, err)
 			}
@@ -182,3 +189,52 @@ func TestFetchNodes(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchNodesRecordsHealthErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode healtherrors.Code
+		wantErr  bool
+	}{
+		{
+			name:     "forbidden",
+			err:      k8sErrors.NewForbidden(v1.Resource("nodes"), "", errors.New("not allowed")),
+			wantCode: healtherrors.CodeListNodesForbidden,
+			wantErr:  true,
+		},
+		{
+			name:     "timeout",
+			err:      k8sErrors.NewTimeoutError("list nodes", 1),
+			wantCode: healtherrors.CodeKubernetesTimeout,
+			wantErr:  true,
+		},
+		{
+			name:     "other failure",
+			err:      k8sErrors.NewInternalError(errors.New("etcd is unavailable")),
+			wantCode: healtherrors.CodeListNodes,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewClientset()
+			clientset.PrependReactor("list", "nodes", func(k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, tt.err
+			})
+			healtherrors.Drain()
+
+			got, err := FetchNodes(context.Background(), client.Client{Clientset: clientset}, 100, 100, nil, nil, false)
+			assert.Nil(t, got)
+			assert.Equal(t, tt.wantErr, err != nil)
+			// the caller tells a forbidden list, collected without the nodes, from the other failures
+			assert.Equal(t, tt.wantCode == healtherrors.CodeListNodesForbidden, k8sErrors.IsForbidden(err))
+
+			recorded := healtherrors.Drain()
+			if assert.Len(t, recorded, 1) {
+				assert.Equal(t, tt.wantCode, recorded[0].Code)
+				assert.Equal(t, tt.err.Error(), recorded[0].Message)
+			}
+		})
+	}
+}
@@ -5,15 +5,22 @@ import (
 	"fmt"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/anchore/k8s-inventory/internal/matcher"
+	"github.com/anchore/k8s-inventory/internal/metrics"
 	"github.com/anchore/k8s-inventory/internal/tracker"
 	"github.com/anchore/k8s-inventory/pkg/client"
+	"github.com/anchore/k8s-inventory/pkg/tracing"
 )
 
-func FetchPodsInNamespace(c client.Client, batchSize, timeout int64, namespace string) ([]v1.Pod, error) {
-	defer tracker.TrackFunctionTime(time.Now(), "Fetching pods in namespace")
+func FetchPodsInNamespace(ctx context.Context, c client.Client, batchSize, timeout int64, namespace string) (_ []v1.Pod, err error) {
+	defer tracker.TrackPhaseTime(time.Now(), metrics.PhaseListPods, "Fetching pods in namespace")
+	ctx, span := tracing.Tracer().Start(ctx, "FetchPodsInNamespace", trace.WithAttributes(attribute.String("namespace", namespace)))
+	defer func() { tracing.End(span, err) }()
 	var podList []v1.Pod
 
 	cont := ""
@@ -24,7 +31,7 @@ func FetchPodsInNamespace(c client.Client, batchSize, timeout int64, namespace s
 			TimeoutSeconds: &timeout,
 		}
 
-		list, err := c.Clientset.CoreV1().Pods(namespace).List(context.Background(), opts)
+		list, err := c.Clientset.CoreV1().Pods(namespace).List(ctx, opts)
 		if err != nil {
 			return nil, fmt.Errorf("failed to list pods in namespace %s: %w", namespace, err)
 		}
@@ -40,7 +47,7 @@ func FetchPodsInNamespace(c client.Client, batchSize, timeout int64, namespace s
 	return podList, nil
 }
 
-func ProcessPods(pods []v1.Pod, namespaceUID string, nodes map[string]Node, includeAnnotations, includeLabels []string, disableMetadata bool) []Pod {
+func ProcessPods(pods []v1.Pod, namespaceUID string, nodes map[string]Node, includeAnnotations, includeLabels *matcher.Matcher, disableMetadata bool) []Pod {
 	var podList []Pod
 
 	for _, p := range pods {
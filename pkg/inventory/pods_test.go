@@ -1,8 +1,10 @@
 package inventory
 
 import (
+	"context"
 	"testing"
 
+	"github.com/anchore/k8s-inventory/internal/matcher"
 	"github.com/anchore/k8s-inventory/pkg/client"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
@@ -62,7 +64,7 @@ func TestFetchPodsInNamespace(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := FetchPodsInNamespace(tt.args.c, tt.args.batchSize, tt.args.timeout, tt.args.namespace)
+			got, err := FetchPodsInNamespace(context.Background(), tt.args.c, tt.args.batchSize, tt.args.timeout, tt.args.namespace)
 			if (err != nil) != tt.wantErr {
 				assert.Error(t, err)
 			}
@@ -225,7 +227,7 @@ func TestProcessPods(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := ProcessPods(tt.args.pods, tt.args.namespaceUID, tt.args.nodes, tt.args.includeAnnotations, tt.args.includeLabels, tt.args.disableMetadata)
+			got := ProcessPods(tt.args.pods, tt.args.namespaceUID, tt.args.nodes, matcher.MustNew(tt.args.includeAnnotations...), matcher.MustNew(tt.args.includeLabels...), tt.args.disableMetadata)
 			assert.Equal(t, tt.want, got)
 		})
 	}
@@ -47,6 +47,7 @@ type Report struct {
 	Namespaces            []Namespace   `json:"namespaces,omitempty"`
 	Nodes                 []Node        `json:"nodes,omitempty"`
 	Pods                  []Pod         `json:"pods,omitempty"`
+	SchemaVersion         string        `json:"schema_version,omitempty"` // The SchemaVersion of the report format
 	ServerVersionMetadata *version.Info `json:"serverVersionMetadata"`
 	Timestamp             string        `json:"timestamp,omitempty"` // Should be generated using time.Now.UTC() and formatted according to RFC Y-M-DTH:M:SZ
 }
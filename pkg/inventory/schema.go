@@ -0,0 +1,124 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/invopop/jsonschema"
+	validator "github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// SchemaVersion is the version of the inventory report format, sent as schema_version in every report. It must be
+// bumped whenever the report types change (major for breaking changes, minor for new fields).
+const SchemaVersion = "1.0.0"
+
+// SchemaID is the identifier of the JSON Schema of the current version of the inventory report
+const SchemaID = "https://anchore.io/schema/k8s-inventory/json/schema-" + SchemaVersion + ".json"
+
+var (
+	compileSchemaOnce sync.Once
+	compiledSchema    *validator.Schema
+	errCompileSchema  error
+)
+
+// JSONSchemaExtend allows the fields that are serialized as null when unset
+func (Report) JSONSchemaExtend(schema *jsonschema.Schema) {
+	for _, name := range []string{"containers", "serverVersionMetadata"} {
+		if property, ok := schema.Properties.Get(name); ok {
+			schema.Properties.Set(name, &jsonschema.Schema{
+				AnyOf: []*jsonschema.Schema{property, {Type: "null"}},
+			})
+		}
+	}
+}
+
+// Schema returns the JSON Schema of the inventory report, generated from the report types
+func Schema() ([]byte, error) {
+	// additional properties are allowed so that consumers can validate reports from newer minor versions
+	reflector := jsonschema.Reflector{AllowAdditionalProperties: true}
+	schema := reflector.Reflect(&Report{})
+	schema.ID = SchemaID
+	schema.Title = "Anchore Kubernetes Inventory Report"
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func getCompiledSchema() (*validator.Schema, error) {
+	compileSchemaOnce.Do(func() {
+		schema, err := Schema()
+		if err != nil {
+			errCompileSchema = fmt.Errorf("failed to generate inventory report schema: %w", err)
+			return
+		}
+		doc, err := validator.UnmarshalJSON(bytes.NewReader(schema))
+		if err != nil {
+			errCompileSchema = fmt.Errorf("failed to parse inventory report schema: %w", err)
+			return
+		}
+		compiler := validator.NewCompiler()
+		if err := compiler.AddResource(SchemaID, doc); err != nil {
+			errCompileSchema = fmt.Errorf("failed to load inventory report schema: %w", err)
+			return
+		}
+		compiledSchema, errCompileSchema = compiler.Compile(SchemaID)
+	})
+	return compiledSchema, errCompileSchema
+}
+
+// ValidateReportJSON validates a serialized inventory report against the schema
+func ValidateReportJSON(data []byte) error {
+	schema, err := getCompiledSchema()
+	if err != nil {
+		return err
+	}
+	instance, err := validator.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to parse inventory report: %w", err)
+	}
+	return schema.Validate(instance)
+}
+
+// ValidateReport validates an inventory report, as it would be serialized, against the schema
+func ValidateReport(report Report) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to serialize inventory report: %w", err)
+	}
+	return ValidateReportJSON(data)
+}
+
+// ValidateReportFile validates all the reports in a file (see ReadReports) against the schema, and returns the
+// number of reports that were validated
+func ValidateReportFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open inventory report: %w", err)
+	}
+	defer f.Close()
+
+	count := 0
+	dec := json.NewDecoder(f)
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return count, fmt.Errorf("failed to parse inventory report %d in %s: %w", count+1, path, err)
+		}
+		count++
+		if err := ValidateReportJSON(raw); err != nil {
+			return count, fmt.Errorf("inventory report %d in %s is invalid: %w", count, path, err)
+		}
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("no inventory reports found in %s", path)
+	}
+	return count, nil
+}
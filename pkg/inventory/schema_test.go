@@ -0,0 +1,131 @@
+package inventory
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anchore/go-testutils"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+var update = flag.Bool("update", false, "update the *.golden files for the inventory report schema")
+
+// The schema is generated from the report types, so this fails whenever they change. When it does, bump
+// SchemaVersion and update the snapshot with -update.
+func TestSchema(t *testing.T) {
+	schema, err := Schema()
+	assert.NoError(t, err)
+
+	if *update {
+		t.Logf("Updating Golden file")
+		testutils.UpdateGoldenFileContents(t, schema)
+	}
+
+	expected := testutils.GetGoldenFileContents(t)
+	assert.JSONEq(t, string(expected), string(schema),
+		"the inventory report schema changed, bump SchemaVersion and update the snapshot with -update")
+	assert.Contains(t, string(schema), SchemaID)
+}
+
+func TestValidateReport(t *testing.T) {
+	tests := []struct {
+		name   string
+		report Report
+	}{
+		{
+			name:   "empty report",
+			report: Report{},
+		},
+		{
+			name: "full report",
+			report: Report{
+				ClusterName:   "docker-desktop",
+				Containers:    []Container{{ID: "c-1", Name: "nginx", PodUID: "pod-1", ImageTag: "nginx:1.25"}},
+				Namespaces:    []Namespace{{Name: "default", UID: "ns-1", Labels: map[string]string{"team": "a"}}},
+				Nodes:         []Node{{Name: "node-1", UID: "node-1", Arch: "arm64"}},
+				Pods:          []Pod{{Name: "web", UID: "pod-1", NamespaceUID: "ns-1", NodeUID: "node-1"}},
+				SchemaVersion: SchemaVersion,
+				ServerVersionMetadata: &version.Info{
+					Major:      "1",
+					Minor:      "30",
+					GitVersion: "v1.30.0",
+				},
+				Timestamp: "2024-01-01T00:00:00Z",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, ValidateReport(tt.report))
+		})
+	}
+}
+
+func TestValidateReportJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		report  string
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			report: `{"cluster_name": "c", "containers": [], "serverVersionMetadata": null, "schema_version": "1.0.0"}`,
+		},
+		{
+			name:   "unknown fields are allowed",
+			report: `{"cluster_name": "c", "containers": null, "serverVersionMetadata": null, "workloads": []}`,
+		},
+		{
+			name:    "missing required field",
+			report:  `{"containers": [], "serverVersionMetadata": null}`,
+			wantErr: "cluster_name",
+		},
+		{
+			name:    "container missing image tag",
+			report:  `{"cluster_name": "c", "containers": [{"id": "1", "image_digest": "", "name": "n", "pod_uid": "p"}], "serverVersionMetadata": null}`,
+			wantErr: "image_tag",
+		},
+		{
+			name:    "wrong type",
+			report:  `{"cluster_name": "c", "containers": [], "serverVersionMetadata": null, "namespaces": {}}`,
+			wantErr: "namespaces",
+		},
+		{
+			name:    "not json",
+			report:  `{`,
+			wantErr: "failed to parse inventory report",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateReportJSON([]byte(tt.report))
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestValidateReportFile(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.json")
+	assert.NoError(t, os.WriteFile(valid, []byte(`{"cluster_name": "a", "containers": [], "serverVersionMetadata": null}
+{"cluster_name": "b", "containers": [], "serverVersionMetadata": null}
+`), 0600))
+	count, err := ValidateReportFile(valid)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	invalid := filepath.Join(dir, "invalid.json")
+	assert.NoError(t, os.WriteFile(invalid, []byte(`{"cluster_name": "a", "containers": [], "serverVersionMetadata": null}
+{"containers": [], "serverVersionMetadata": null}
+`), 0600))
+	_, err = ValidateReportFile(invalid)
+	assert.ErrorContains(t, err, "inventory report 2 in "+invalid+" is invalid")
+}
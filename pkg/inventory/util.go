@@ -1,21 +1,15 @@
 package inventory
 
-import "regexp"
+import "github.com/anchore/k8s-inventory/internal/matcher"
 
-func checkForRegexMatch(regex string, value string) bool {
-	return regexp.MustCompile(regex).MatchString(value)
-}
-
-func processAnnotationsOrLabels(annotationsOrLabels map[string]string, include []string) map[string]string {
-	if len(include) == 0 {
+func processAnnotationsOrLabels(annotationsOrLabels map[string]string, include *matcher.Matcher) map[string]string {
+	if include.Empty() {
 		return annotationsOrLabels
 	}
 	toReturn := make(map[string]string)
 	for key, val := range annotationsOrLabels {
-		for _, includeKey := range include {
-			if checkForRegexMatch(includeKey, key) {
-				toReturn[key] = val
-			}
+		if include.MatchString(key) {
+			toReturn[key] = val
 		}
 	}
 	return toReturn
@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/k8s-inventory/internal/matcher"
 )
 
 func Test_processAnnotations(t *testing.T) {
@@ -130,7 +132,7 @@ func Test_processAnnotations(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := processAnnotationsOrLabels(tt.args.annotationsOrLabels, tt.args.include)
+			got := processAnnotationsOrLabels(tt.args.annotationsOrLabels, matcher.MustNew(tt.args.include...))
 			assert.Equal(t, tt.want, got)
 		})
 	}
@@ -0,0 +1,166 @@
+// Package leader elects a leader among the replicas of the agent with a coordination.k8s.io Lease, so that only the
+// leader collects and reports the inventory while the other replicas stand by
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/internal/log"
+	"github.com/anchore/k8s-inventory/internal/metrics"
+	"github.com/anchore/k8s-inventory/pkg/client"
+)
+
+// Roles of the agent in the leader election
+const (
+	RoleLeader  = "leader"
+	RoleStandby = "standby"
+)
+
+// current is the elector that the agent runs, if leader election is enabled
+var current atomic.Pointer[Elector]
+
+// Role returns the role of the agent in the leader election, or an empty string if leader election is disabled
+func Role() string {
+	elector := current.Load()
+	switch {
+	case elector == nil:
+		return ""
+	case elector.IsLeader():
+		return RoleLeader
+	default:
+		return RoleStandby
+	}
+}
+
+// Elector contends for the Lease with the other replicas of the agent
+type Elector struct {
+	electionConfig leaderelection.LeaderElectionConfig
+	identity       string
+	leading        atomic.Bool
+	// only one lead function runs at a time, even if the leadership is regained before the previous one returned
+	leadLock sync.Mutex
+}
+
+// NewForConfig returns an elector for the Lease of the configuration, identifying the agent by its pod name
+// (HOSTNAME)
+func NewForConfig(appConfig *config.Application) (*Elector, error) {
+	namespace := appConfig.LeaderElection.LeaseNamespace
+	if namespace == "" {
+		namespace = os.Getenv("POD_NAMESPACE")
+	}
+	if namespace == "" {
+		return nil, fmt.Errorf("leader-election.lease-namespace is required when POD_NAMESPACE is not set")
+	}
+	identity, err := client.PodIdentity()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine identity for leader election: %w", err)
+	}
+
+	kubeconfig, err := client.GetKubeConfig(appConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig for leader election: %w", err)
+	}
+	clientset, err := client.GetClientSet(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clientset for leader election: %w", err)
+	}
+	return New(appConfig.LeaderElection, clientset, namespace, identity)
+}
+
+// New returns an elector for the Lease in the namespace, identifying the agent with identity
+func New(cfg config.LeaderElectionConfig, clientset kubernetes.Interface, namespace, identity string) (*Elector, error) {
+	e := &Elector{identity: identity}
+	e.electionConfig = leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{
+				Name:      cfg.LeaseName,
+				Namespace: namespace,
+			},
+			Client:     clientset.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+		},
+		LeaseDuration:   time.Duration(cfg.LeaseDurationSeconds) * time.Second,
+		RenewDeadline:   time.Duration(cfg.RenewDeadlineSeconds) * time.Second,
+		RetryPeriod:     time.Duration(cfg.RetryPeriodSeconds) * time.Second,
+		ReleaseOnCancel: true,
+		Name:            cfg.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {},
+			OnStoppedLeading: func() {},
+		},
+	}
+	// validate the configuration up front, the elector itself is created for every term
+	if _, err := leaderelection.NewLeaderElector(e.electionConfig); err != nil {
+		return nil, fmt.Errorf("invalid leader election configuration: %w", err)
+	}
+	return e, nil
+}
+
+func (e *Elector) setLeading(leading bool) {
+	e.leading.Store(leading)
+	if leading {
+		metrics.Leader.Set(1)
+	} else {
+		metrics.Leader.Set(0)
+	}
+}
+
+// IsLeader returns whether the agent currently holds the Lease
+func (e *Elector) IsLeader() bool {
+	return e.leading.Load()
+}
+
+// Run contends for the Lease until the context is done, calling lead whenever the agent becomes the leader. The
+// context passed to lead is canceled when the agent stops leading, and the Lease is released when the context is
+// done, so that another replica can take over without waiting for it to expire. Run returns once lead returned.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	current.Store(e)
+	metrics.Leader.Set(0)
+
+	electionConfig := e.electionConfig
+	electionConfig.Callbacks = leaderelection.LeaderCallbacks{
+		OnStartedLeading: func(ctx context.Context) {
+			e.leadLock.Lock()
+			defer e.leadLock.Unlock()
+			if ctx.Err() != nil {
+				// the leadership was lost again while the previous term was ending
+				return
+			}
+			log.Infof("Became the leader (%s), collecting and reporting the inventory", e.identity)
+			e.setLeading(true)
+			lead(ctx)
+			e.setLeading(false)
+			log.Infof("Stopped leading (%s), standing by", e.identity)
+		},
+		OnStoppedLeading: func() {},
+		OnNewLeader: func(identity string) {
+			if identity != e.identity {
+				log.Infof("Standing by, %s is the leader", identity)
+			}
+		},
+	}
+
+	for ctx.Err() == nil {
+		elector, err := leaderelection.NewLeaderElector(electionConfig)
+		if err != nil {
+			// the configuration was validated when the elector was created
+			log.Errorf("Failed to start leader election: %v", err)
+			return
+		}
+		elector.Run(ctx)
+	}
+	// wait for the last term to end
+	e.leadLock.Lock()
+	defer e.leadLock.Unlock()
+}
@@ -0,0 +1,93 @@
+package leader
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+)
+
+var leaderElectionConfig = config.LeaderElectionConfig{
+	Enabled:              true,
+	LeaseName:            "anchore-k8s-inventory",
+	LeaseDurationSeconds: 3,
+	RenewDeadlineSeconds: 2,
+	RetryPeriodSeconds:   1,
+}
+
+// run runs the elector in the background, returning a function that stops it and waits for it to release the Lease
+func run(t *testing.T, e *Elector, lead func(ctx context.Context)) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Run(ctx, lead)
+	}()
+	return func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("elector did not stop")
+		}
+	}
+}
+
+func TestElectorFailover(t *testing.T) {
+	defer current.Store(nil)
+	clientset := fake.NewClientset()
+
+	first, err := New(leaderElectionConfig, clientset, "anchore", "pod-a")
+	require.NoError(t, err)
+	second, err := New(leaderElectionConfig, clientset, "anchore", "pod-b")
+	require.NoError(t, err)
+
+	var firstTerms atomic.Int32
+	stopFirst := run(t, first, func(ctx context.Context) {
+		firstTerms.Add(1)
+		<-ctx.Done()
+	})
+	require.Eventually(t, first.IsLeader, 5*time.Second, 50*time.Millisecond)
+
+	var secondTerms atomic.Int32
+	stopSecond := run(t, second, func(ctx context.Context) {
+		secondTerms.Add(1)
+		<-ctx.Done()
+	})
+	defer stopSecond()
+
+	// the second replica stands by while the first one leads
+	assert.Never(t, second.IsLeader, 1500*time.Millisecond, 50*time.Millisecond)
+	assert.Equal(t, RoleStandby, Role())
+
+	lease, err := clientset.CoordinationV1().Leases("anchore").Get(context.Background(), "anchore-k8s-inventory", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "pod-a", *lease.Spec.HolderIdentity)
+
+	// the first replica releases the Lease when it stops, so that the second one takes over
+	stopFirst()
+	assert.False(t, first.IsLeader())
+	require.Eventually(t, second.IsLeader, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, RoleLeader, Role())
+	assert.Equal(t, int32(1), firstTerms.Load())
+	assert.Equal(t, int32(1), secondTerms.Load())
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	cfg := leaderElectionConfig
+	cfg.RenewDeadlineSeconds = 3
+
+	_, err := New(cfg, fake.NewClientset(), "anchore", "pod-a")
+	assert.ErrorContains(t, err, "invalid leader election configuration")
+}
+
+func TestRoleWithoutLeaderElection(t *testing.T) {
+	assert.Empty(t, Role())
+}
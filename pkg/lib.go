@@ -4,26 +4,39 @@ k8s go SDK
 */package pkg
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
-	"regexp"
 	"time"
 
 	jstime "github.com/anchore/k8s-inventory/internal/time"
 	"github.com/anchore/k8s-inventory/pkg/integration"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
 	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/internal/healtherrors"
 	"github.com/anchore/k8s-inventory/internal/log"
+	"github.com/anchore/k8s-inventory/internal/metrics"
+	"github.com/anchore/k8s-inventory/internal/stats"
+	"github.com/anchore/k8s-inventory/internal/tracker"
+	"github.com/anchore/k8s-inventory/pkg/agentstatus"
 	"github.com/anchore/k8s-inventory/pkg/client"
 	"github.com/anchore/k8s-inventory/pkg/healthreporter"
 	"github.com/anchore/k8s-inventory/pkg/inventory"
+	"github.com/anchore/k8s-inventory/pkg/leader"
 	"github.com/anchore/k8s-inventory/pkg/logger"
 	"github.com/anchore/k8s-inventory/pkg/reporter"
+	"github.com/anchore/k8s-inventory/pkg/secrets"
+	"github.com/anchore/k8s-inventory/pkg/server"
+	"github.com/anchore/k8s-inventory/pkg/sharding"
+	"github.com/anchore/k8s-inventory/pkg/tracing"
 )
 
 type ReportItem struct {
@@ -49,6 +62,21 @@ type batchState struct {
 	currSize int
 }
 
+type reportTimeKey struct{}
+
+// withReportTime sets the timestamp of the inventory reports collected with the context
+func withReportTime(ctx context.Context, timestamp time.Time) context.Context {
+	return context.WithValue(ctx, reportTimeKey{}, timestamp)
+}
+
+// reportTime returns the timestamp of an inventory report collected with the context, the current time unless set
+func reportTime(ctx context.Context) time.Time {
+	if timestamp, ok := ctx.Value(reportTimeKey{}).(time.Time); ok {
+		return timestamp
+	}
+	return time.Now()
+}
+
 func reportToStdout(report inventory.Report) error {
 	enc := json.NewEncoder(os.Stdout)
 	// prevent > and < from being escaped in the payload
@@ -60,7 +88,15 @@ func reportToStdout(report inventory.Report) error {
 	return nil
 }
 
-func HandleReport(report inventory.Report, reportInfo *healthreporter.InventoryReportInfo, cfg *config.Application, account string) error {
+func HandleReport(ctx context.Context, report inventory.Report, reportInfo *healthreporter.InventoryReportInfo, cfg *config.Application, account string) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "reporter.Post", trace.WithAttributes(
+		attribute.String("account", account),
+		attribute.Int("namespaces", len(report.Namespaces)),
+		attribute.Int("pods", len(report.Pods)),
+		attribute.Int("containers", len(report.Containers)),
+	))
+	defer func() { tracing.End(span, err) }()
+
 	if cfg.VerboseInventoryReports {
 		err := reportToStdout(report)
 		if err != nil {
@@ -68,17 +104,40 @@ func HandleReport(report inventory.Report, reportInfo *healthreporter.InventoryR
 		}
 	}
 
+	anchoreDetails, err := GetAccountAnchoreDetails(cfg, account)
+	if err != nil {
+		return err
+	}
+
+	if anchoreDetails.IsValid() {
+		reportInfo.SentAsUser = anchoreDetails.User
+		if err := reporter.Post(ctx, report, anchoreDetails); err != nil {
+			if errors.Is(err, reporter.ErrAnchoreAccountDoesNotExist) {
+				return err
+			}
+			return fmt.Errorf("unable to report Inventory to Anchore account %s: %w", account, err)
+		}
+		log.Infof("Inventory report sent to Anchore account %s", account)
+		metrics.LastSuccessfulReport.WithLabelValues(account).SetToCurrentTime()
+	} else {
+		log.Info("Anchore details not specified, not reporting inventory")
+	}
+	return nil
+}
+
+// GetAccountAnchoreDetails returns the Anchore details to report the inventory of the account with, from its account
+// route if there is one or the global Anchore details otherwise, with the credentials resolved
+func GetAccountAnchoreDetails(cfg *config.Application, account string) (config.AnchoreInfo, error) {
 	anchoreDetails := cfg.AnchoreDetails
 	// Look for account credentials in the account routes first then fall back to the global anchore credentials
 	if account == "" {
-		return fmt.Errorf("account name is required")
+		return anchoreDetails, fmt.Errorf("account name is required")
 	}
 	anchoreDetails.Account = account
 	if cfg.AccountRoutes != nil {
 		if route, ok := cfg.AccountRoutes[account]; ok {
 			log.Debugf("Using account details specified from account-routes config for account %s", account)
-			anchoreDetails.User = route.User
-			anchoreDetails.Password = route.Password
+			route.ApplyTo(&anchoreDetails)
 		} else {
 			log.Debugf("Using default account details for account %s", account)
 		}
@@ -86,38 +145,97 @@ func HandleReport(report inventory.Report, reportInfo *healthreporter.InventoryR
 		log.Debugf("Using default account details for account %s", account)
 	}
 
-	if anchoreDetails.IsValid() {
-		reportInfo.SentAsUser = anchoreDetails.User
-		if err := reporter.Post(report, anchoreDetails); err != nil {
-			if errors.Is(err, reporter.ErrAnchoreAccountDoesNotExist) {
-				return err
-			}
-			return fmt.Errorf("unable to report Inventory to Anchore account %s: %w", account, err)
-		}
-		log.Infof("Inventory report sent to Anchore account %s", account)
-	} else {
-		log.Info("Anchore details not specified, not reporting inventory")
+	anchoreDetails, err := secrets.ResolveAnchoreDetails(cfg, anchoreDetails)
+	if err != nil {
+		return anchoreDetails, fmt.Errorf("unable to resolve credentials for Anchore account %s: %w", account, err)
 	}
-	return nil
+	return anchoreDetails, nil
+}
+
+// GetDefaultAccount returns the account that reports are sent to when their account does not exist in Anchore
+func GetDefaultAccount(cfg *config.Application) string {
+	if cfg.AccountRouteByNamespaceLabel.DefaultAccount != "" {
+		return cfg.AccountRouteByNamespaceLabel.DefaultAccount
+	}
+	return cfg.AnchoreDetails.Account
 }
 
 // PeriodicallyGetInventoryReport periodically retrieve image results and report/output them according to the configuration.
 // Note: Errors do not cause the function to exit, since this is periodically running
-//
-//nolint:gocognit
 func PeriodicallyGetInventoryReport(cfg *config.Application, ch integration.Channels, gatedReportInfo *healthreporter.GatedReportInfo) {
 	// Wait for registration with Enterprise to be disabled or completed
 	<-ch.InventoryReportingEnabled
 	log.Info("Inventory reporting started")
 	healthReportingEnabled := false
+	periodicallyReportInventory(context.Background(), cfg, ch, gatedReportInfo, &healthReportingEnabled)
+}
+
+// PeriodicallyGetInventoryReportUntilDone is like PeriodicallyGetInventoryReport, but stops collecting and reporting
+// the inventory once the context is done, so that an agent sharing the namespaces with its replicas reports nothing
+// after it left them. It returns once the context is done.
+func PeriodicallyGetInventoryReportUntilDone(ctx context.Context, cfg *config.Application, ch integration.Channels,
+	gatedReportInfo *healthreporter.GatedReportInfo) {
+	// Wait for registration with Enterprise to be disabled or completed
+	select {
+	case <-ch.InventoryReportingEnabled:
+	case <-ctx.Done():
+		return
+	}
+	log.Info("Inventory reporting started")
+	healthReportingEnabled := false
+	periodicallyReportInventory(ctx, cfg, ch, gatedReportInfo, &healthReportingEnabled)
+}
+
+// PeriodicallyGetInventoryReportWhileLeading is like PeriodicallyGetInventoryReport, but only collects and reports the
+// inventory while the agent is the leader among its replicas. It returns once the context is done and the Lease is
+// released.
+func PeriodicallyGetInventoryReportWhileLeading(ctx context.Context, cfg *config.Application, ch integration.Channels,
+	gatedReportInfo *healthreporter.GatedReportInfo, elector *leader.Elector) {
+	// Wait for registration with Enterprise to be disabled or completed
+	select {
+	case <-ch.InventoryReportingEnabled:
+	case <-ctx.Done():
+		return
+	}
+	log.Info("Inventory reporting started, collecting and reporting the inventory while leading")
+	healthReportingEnabled := false
+	elector.Run(ctx, func(leaderCtx context.Context) {
+		periodicallyReportInventory(leaderCtx, cfg, ch, gatedReportInfo, &healthReportingEnabled)
+	})
+}
 
+// periodicallyReportInventory collects and reports the inventory every polling interval until the context is done
+//
+//nolint:gocognit
+func periodicallyReportInventory(runCtx context.Context, cfg *config.Application, ch integration.Channels,
+	gatedReportInfo *healthreporter.GatedReportInfo, healthReportingEnabled *bool) {
 	// Fire off a ticker that reports according to a configurable polling interval
-	ticker := time.NewTicker(time.Duration(cfg.PollingIntervalSeconds) * time.Second)
+	pollingInterval := cfg.PollingIntervalSeconds
+	ticker := time.NewTicker(time.Duration(pollingInterval) * time.Second)
+	defer ticker.Stop()
 
+	// keep the local configuration, so that the remote configuration is applied to it on every cycle
+	localCfg := cfg
 	for {
-		reports, err := GetInventoryReports(cfg)
+		cfg := integration.EffectiveConfig(localCfg)
+		if cfg.PollingIntervalSeconds != pollingInterval {
+			pollingInterval = cfg.PollingIntervalSeconds
+			ticker.Reset(time.Duration(pollingInterval) * time.Second)
+		}
+
+		ctx, span := tracing.Tracer().Start(runCtx, "inventory.poll")
+		ctx, collectionStats := stats.NewContext(ctx, time.Now())
+		var cycleStart time.Time
+		if cfg.Sharding.Enabled {
+			// the shares of the replicas of a cycle are reported with the same timestamp
+			cycleStart = sharding.CycleStart(time.Now(), time.Duration(pollingInterval)*time.Second)
+			ctx = withReportTime(ctx, cycleStart)
+		}
+		reports, err := GetInventoryReports(ctx, cfg)
+		agentstatus.SetCollectionResult(err)
 		if err != nil {
 			log.Errorf("Failed to get Inventory Report: %w", err)
+			healtherrors.Record(healtherrors.CodeInventoryCollection, err)
 		} else {
 			for account, reportsForAccount := range reports {
 				reportInfo := healthreporter.InventoryReportInfo{
@@ -127,7 +245,12 @@ func PeriodicallyGetInventoryReport(cfg *config.Application, ch integration.Chan
 					Batches:             make([]healthreporter.BatchInfo, 0),
 					HasErrors:           false,
 				}
+				batchErrors := make([]string, 0)
 				for count, report := range reportsForAccount {
+					if runCtx.Err() != nil {
+						log.Infof("Not sending the remaining Inventory Reports to Anchore Account %s, reporting was stopped", account)
+						break
+					}
 					log.Infof("Sending Inventory Report to Anchore Account %s, %d of %d", account, count+1, len(reportsForAccount))
 
 					reportInfo.ReportTimestamp = report.Timestamp
@@ -136,55 +259,76 @@ func PeriodicallyGetInventoryReport(cfg *config.Application, ch integration.Chan
 						BatchIndex:    count + 1,
 					}
 
-					err := HandleReport(report, &reportInfo, cfg, account)
+					batchCtx, batchSpan := tracing.Tracer().Start(ctx, "inventory.batch", trace.WithAttributes(
+						attribute.String("account", account),
+						attribute.Int("batch.index", count+1),
+						attribute.Int("batch.count", len(reportsForAccount)),
+					))
+					err := HandleReport(batchCtx, report, &reportInfo, cfg, account)
 					if errors.Is(err, reporter.ErrAnchoreAccountDoesNotExist) {
 						// record this error for the health report even if the retry works
 						batchInfo.Error = fmt.Sprintf("%s (%s) | ", err.Error(), account)
 						reportInfo.HasErrors = true
+						healtherrors.Record(healtherrors.CodeAnchoreAccountNotFound, fmt.Errorf("%w: %s", err, account))
 
 						// Retry with default account
-						retryAccount := cfg.AnchoreDetails.Account
-						if cfg.AccountRouteByNamespaceLabel.DefaultAccount != "" {
-							retryAccount = cfg.AccountRouteByNamespaceLabel.DefaultAccount
-						}
+						retryAccount := GetDefaultAccount(cfg)
+						metrics.DefaultAccountFallbacks.WithLabelValues(account).Inc()
 						log.Warnf("Error sending to Anchore Account %s, sending to default account", account)
-						err = HandleReport(report, &reportInfo, cfg, retryAccount)
+						err = HandleReport(batchCtx, report, &reportInfo, cfg, retryAccount)
 					}
+					tracing.End(batchSpan, err)
 					if err != nil {
 						log.Errorf("Failed to handle Inventory Report: %w", err)
 						// append the error to any error that happened during a retry, so we record both failures
 						batchInfo.Error += err.Error()
 						reportInfo.HasErrors = true
+						healtherrors.Record(healtherrors.CodeInventoryReportDelivery, err)
+						batchErrors = append(batchErrors, batchInfo.Error)
 					} else {
 						reportInfo.LastSuccessfulIndex = count + 1
+						server.SetReady("inventory report sent")
 					}
 
 					select {
 					case isEnabled, isNotClosed := <-ch.HealthReportingEnabled:
 						if isNotClosed {
-							healthReportingEnabled = isEnabled
+							*healthReportingEnabled = isEnabled
 						}
-						log.Infof("Health reporting enabled: %t", healthReportingEnabled)
+						log.Infof("Health reporting enabled: %t", *healthReportingEnabled)
 					default:
 					}
-					if healthReportingEnabled {
+					if *healthReportingEnabled {
 						reportInfo.Batches = append(reportInfo.Batches, batchInfo)
 						healthreporter.SetReportInfoNoBlocking(account, count, reportInfo, gatedReportInfo)
 					}
 				}
+				agentstatus.SetReportResult(account, len(reportsForAccount), batchErrors)
 			}
 		}
+		healthreporter.SetCollectionSummaryNoBlocking(collectionStats.Summary(time.Now(), err), gatedReportInfo)
+		tracing.End(span, err)
 
 		log.Infof("Waiting %d seconds for next poll...", cfg.PollingIntervalSeconds)
 
-		// Wait at least as long as the ticker
-		log.Debugf("Start new gather: %s", <-ticker.C)
+		// Wait at least as long as the ticker, or until the next cycle of the replicas with sharding
+		next := ticker.C
+		if cfg.Sharding.Enabled {
+			next = time.After(time.Until(cycleStart.Add(time.Duration(pollingInterval) * time.Second)))
+		}
+		select {
+		case tick := <-next:
+			log.Debugf("Start new gather: %s", tick)
+		case <-runCtx.Done():
+			return
+		}
 	}
 }
 
 // launchWorkerPool will create a worker pool of goroutines to grab pods/containers
 // from each namespace. This should alleviate the load on the api server
 func launchWorkerPool(
+	ctx context.Context,
 	cfg *config.Application,
 	kubeconfig *rest.Config,
 	ch channels,
@@ -196,6 +340,7 @@ func launchWorkerPool(
 			// each worker needs its own clientset
 			clientset, err := client.GetClientSet(kubeconfig)
 			if err != nil {
+				healtherrors.Record(healtherrors.CodeKubernetesClient, err)
 				ch.errors <- err
 				return
 			}
@@ -205,7 +350,7 @@ func launchWorkerPool(
 				case <-ch.stopper:
 					return
 				default:
-					processNamespace(clientset, cfg, namespace, ch, nodes)
+					processNamespace(ctx, clientset, cfg, namespace, ch, nodes)
 				}
 			}
 		}()
@@ -216,22 +361,30 @@ func launchWorkerPool(
 //
 //nolint:funlen
 func GetInventoryReportForNamespaces(
+	ctx context.Context,
 	cfg *config.Application,
 	namespaces []inventory.Namespace,
-) (inventory.Report, error) {
+) (_ inventory.Report, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "GetInventoryReportForNamespaces",
+		trace.WithAttributes(attribute.Int("namespaces", len(namespaces))))
+	defer func() { tracing.End(span, err) }()
+
 	nsNames := make([]string, 0)
 	for _, ns := range namespaces {
 		nsNames = append(nsNames, ns.Name)
 	}
 	log.Info("Starting inventory collection for namespaces: ", nsNames)
+	defer tracker.TrackPhaseTime(time.Now(), metrics.PhaseCollect, "Collecting inventory for namespaces")
 
 	kubeconfig, err := client.GetKubeConfig(cfg)
 	if err != nil {
+		healtherrors.Record(healtherrors.CodeKubernetesClient, err)
 		return inventory.Report{}, err
 	}
 
 	clientset, err := client.GetClientSet(kubeconfig)
 	if err != nil {
+		healtherrors.Record(healtherrors.CodeKubernetesClient, err)
 		return inventory.Report{}, fmt.Errorf("failed to get k8s client set: %w", err)
 	}
 	client := client.Client{
@@ -252,18 +405,24 @@ func GetInventoryReportForNamespaces(
 
 	var nodeMap map[string]inventory.Node
 	nodeMap, err = inventory.FetchNodes(
+		ctx,
 		client,
 		cfg.Kubernetes.RequestBatchSize,
 		cfg.Kubernetes.RequestTimeoutSeconds,
-		cfg.MetadataCollection.Nodes.Annotations,
-		cfg.MetadataCollection.Nodes.Labels,
+		cfg.MetadataCollection.Nodes.AnnotationMatcher(),
+		cfg.MetadataCollection.Nodes.LabelMatcher(),
 		cfg.MetadataCollection.Nodes.Disable,
 	)
-	if err != nil {
+	if k8sErrors.IsForbidden(err) {
+		// the inventory is collected without the nodes when the agent is not allowed to list them
+		log.Warnf("%v", err)
+		agentstatus.KubernetesError(err)
+	} else if err != nil {
 		return inventory.Report{}, err
 	}
+	stats.FromContext(ctx).SetNodes(len(nodeMap))
 
-	launchWorkerPool(cfg, kubeconfig, ch, queue, nodeMap) // get pods/containers from namespaces using a worker pool pattern
+	launchWorkerPool(ctx, cfg, kubeconfig, ch, queue, nodeMap) // get pods/containers from namespaces using a worker pool pattern
 
 	results := make([]ReportItem, 0)
 	pods := make([]inventory.Pod, 0)
@@ -275,8 +434,10 @@ func GetInventoryReportForNamespaces(
 			results = append(results, item)
 			if cfg.NamespaceSelectors.IgnoreEmpty && len(item.Pods) == 0 {
 				log.Debugf("Ignoring namespace \"%s\" as it has no pods", item.Namespace.Name)
+				stats.FromContext(ctx).AddNamespacesSkipped(1)
 				continue
 			}
+			stats.FromContext(ctx).AddNamespaceScanned(len(item.Pods), len(item.Containers))
 			processedNamespaces = append(processedNamespaces, item.Namespace)
 			pods = append(pods, item.Pods...)
 			containers = append(containers, item.Containers...)
@@ -284,7 +445,9 @@ func GetInventoryReportForNamespaces(
 			close(ch.stopper)
 			return inventory.Report{}, err
 		case <-time.After(time.Second * time.Duration(cfg.Kubernetes.RequestTimeoutSeconds)):
-			return inventory.Report{}, fmt.Errorf("timed out waiting for results")
+			err = fmt.Errorf("timed out waiting for results")
+			healtherrors.Record(healtherrors.CodeKubernetesTimeout, err)
+			return inventory.Report{}, err
 		}
 	}
 	close(ch.reportItem)
@@ -293,6 +456,7 @@ func GetInventoryReportForNamespaces(
 
 	serverVersion, err := clientset.Discovery().ServerVersion()
 	if err != nil {
+		healtherrors.Record(healtherrors.KubernetesCode(healtherrors.CodeKubernetesServerVersion, err), err)
 		return inventory.Report{}, fmt.Errorf("failed to get Cluster Server Version: %w", err)
 	}
 
@@ -303,36 +467,41 @@ func GetInventoryReportForNamespaces(
 
 	log.Infof("Got Inventory Report with %d containers running across %d namespaces", len(containers), len(processedNamespaces))
 	return inventory.Report{
-		Timestamp:             time.Now().UTC().Format(time.RFC3339),
+		Timestamp:             reportTime(ctx).UTC().Format(time.RFC3339),
 		Containers:            containers,
 		Pods:                  pods,
 		Namespaces:            processedNamespaces,
 		Nodes:                 nodes,
 		ServerVersionMetadata: serverVersion,
 		ClusterName:           cfg.KubeConfig.Cluster,
+		SchemaVersion:         inventory.SchemaVersion,
 	}, nil
 }
 
-func GetAllNamespaces(cfg *config.Application) ([]inventory.Namespace, error) {
+func GetAllNamespaces(ctx context.Context, cfg *config.Application) ([]inventory.Namespace, error) {
 	kubeconfig, err := client.GetKubeConfig(cfg)
 	if err != nil {
+		healtherrors.Record(healtherrors.CodeKubernetesClient, err)
 		return []inventory.Namespace{}, err
 	}
 
 	clientset, err := client.GetClientSet(kubeconfig)
 	if err != nil {
+		healtherrors.Record(healtherrors.CodeKubernetesClient, err)
 		return []inventory.Namespace{}, fmt.Errorf("failed to get k8s client set: %w", err)
 	}
 	client := client.Client{
 		Clientset: clientset,
 	}
 
-	namespaces, err := inventory.FetchNamespaces(client,
+	namespaces, err := inventory.FetchNamespaces(ctx, client,
 		cfg.Kubernetes.RequestBatchSize, cfg.Kubernetes.RequestTimeoutSeconds,
-		cfg.NamespaceSelectors.Exclude, cfg.NamespaceSelectors.Include,
-		cfg.MetadataCollection.Namespace.Annotations, cfg.MetadataCollection.Namespace.Labels,
+		cfg.NamespaceSelectors.ExcludeMatcher(), cfg.NamespaceSelectors.Include,
+		cfg.MetadataCollection.Namespace.AnnotationMatcher(), cfg.MetadataCollection.Namespace.LabelMatcher(),
 		cfg.MetadataCollection.Namespace.Disable)
 	if err != nil {
+		healtherrors.Record(healtherrors.KubernetesCode(healtherrors.CodeListNamespaces, err), err)
+		agentstatus.KubernetesError(err)
 		return []inventory.Namespace{}, err
 	}
 
@@ -341,51 +510,6 @@ func GetAllNamespaces(cfg *config.Application) ([]inventory.Namespace, error) {
 	return namespaces, nil
 }
 
-func GetAccountRoutedNamespaces(defaultAccount string, namespaces []inventory.Namespace,
-	accountRoutes config.AccountRoutes, namespaceLabelRouting config.AccountRouteByNamespaceLabel) map[string][]inventory.Namespace {
-	accountRoutesForAllNamespaces := make(map[string][]inventory.Namespace)
-
-	if namespaceLabelRouting.DefaultAccount != "" {
-		defaultAccount = namespaceLabelRouting.DefaultAccount
-	}
-
-	accountNamespaces := make(map[string]struct{})
-	for routeNS, route := range accountRoutes {
-		for _, ns := range namespaces {
-			for _, namespaceRegex := range route.Namespaces {
-				if regexp.MustCompile(namespaceRegex).MatchString(ns.Name) {
-					log.Debugf("Namespace %s matched route from config %s", ns.Name, routeNS)
-					accountNamespaces[ns.Name] = struct{}{}
-					accountRoutesForAllNamespaces[routeNS] = append(accountRoutesForAllNamespaces[routeNS], ns)
-				}
-			}
-		}
-	}
-
-	// If there is a namespace label routing, add namespaces to the account routes based on the label,
-	// if the namespace has not already been added to an account route set via explicit configuration in
-	// accountRoutes config. (This overrides the label routing for the case where the label cannot be changed).
-	// Otherwise, add namespaces that are not in any account route to the default account unless disabled.
-	for _, ns := range namespaces {
-		_, namespaceRouted := accountNamespaces[ns.Name]
-		if namespaceLabelRouting.LabelKey != "" && !namespaceRouted {
-			if account, ok := ns.Labels[namespaceLabelRouting.LabelKey]; ok {
-				log.Debugf("Namespace %s matched route from label %s", ns.Name, account)
-				accountRoutesForAllNamespaces[account] = append(accountRoutesForAllNamespaces[account], ns)
-			} else if !namespaceLabelRouting.IgnoreMissingLabel {
-				accountRoutesForAllNamespaces[defaultAccount] = append(accountRoutesForAllNamespaces[defaultAccount], ns)
-			} else {
-				log.Infof("Ignoring namespace %s because it does not have the label %s", ns.Name, namespaceLabelRouting.LabelKey)
-			}
-		} else if !namespaceRouted {
-			accountRoutesForAllNamespaces[defaultAccount] = append(accountRoutesForAllNamespaces[defaultAccount], ns)
-			log.Debugf("Namespace %s added to default account %s", ns.Name, defaultAccount)
-		}
-	}
-
-	return accountRoutesForAllNamespaces
-}
-
 func GetNamespacesBatches(namespaces []inventory.Namespace, batchSize int) [][]inventory.Namespace {
 	batches := make([][]inventory.Namespace, 0)
 	if batchSize <= 0 {
@@ -401,14 +525,18 @@ func GetNamespacesBatches(namespaces []inventory.Namespace, batchSize int) [][]i
 	return batches
 }
 
-func GetInventoryReports(cfg *config.Application) (BatchedReports, error) {
+func GetInventoryReports(ctx context.Context, cfg *config.Application) (_ BatchedReports, err error) {
 	log.Info("Starting image inventory collection")
+	defer tracker.TrackPhaseTime(time.Now(), metrics.PhaseInventory, "Image inventory collection")
+	ctx, span := tracing.Tracer().Start(ctx, "GetInventoryReports")
+	defer func() { tracing.End(span, err) }()
 
 	reports := AccountRoutedReports{}
-	namespaces, _ := GetAllNamespaces(cfg)
+	namespaces, _ := GetAllNamespaces(ctx, cfg)
+	namespaces = sharding.Namespaces(namespaces)
 
 	if len(cfg.AccountRoutes) == 0 && cfg.AccountRouteByNamespaceLabel.LabelKey == "" {
-		allNamespacesReport, err := GetInventoryReportForNamespaces(cfg, namespaces)
+		allNamespacesReport, err := GetInventoryReportForNamespaces(ctx, cfg, namespaces)
 		if err != nil {
 			return BatchedReports{}, err
 		}
@@ -426,7 +554,7 @@ func GetInventoryReports(cfg *config.Application) (BatchedReports, error) {
 
 		// Get inventory reports for each account
 		for account, namespaces := range accountRoutesForAllNamespaces {
-			accountReport, err := GetInventoryReportForNamespaces(cfg, namespaces)
+			accountReport, err := GetInventoryReportForNamespaces(ctx, cfg, namespaces)
 			if err != nil {
 				return BatchedReports{}, err
 			}
@@ -434,7 +562,16 @@ func GetInventoryReports(cfg *config.Application) (BatchedReports, error) {
 		}
 	}
 
-	return getBatchedInventoryReports(reports, cfg.InventoryReportLimits), nil
+	for account, report := range reports {
+		metrics.NamespacesCollected.WithLabelValues(account).Set(float64(len(report.Namespaces)))
+		metrics.PodsCollected.WithLabelValues(account).Set(float64(len(report.Pods)))
+		metrics.ContainersCollected.WithLabelValues(account).Set(float64(len(report.Containers)))
+	}
+
+	_, batchSpan := tracing.Tracer().Start(ctx, "getBatchedInventoryReports")
+	batched := getBatchedInventoryReports(reports, cfg.InventoryReportLimits)
+	batchSpan.End()
+	return batched, nil
 }
 
 func (state *batchState) createReportBatch(accountReport inventory.Report) *inventory.Report {
@@ -457,6 +594,7 @@ func (state *batchState) createReportBatch(accountReport inventory.Report) *inve
 		Nodes:                 nodes,
 		ServerVersionMetadata: accountReport.ServerVersionMetadata,
 		ClusterName:           accountReport.ClusterName,
+		SchemaVersion:         accountReport.SchemaVersion,
 	}
 
 	// Reset batch state
@@ -509,12 +647,14 @@ func buildLookups(accountReport inventory.Report) inventoryLookups {
 
 //nolint:gocognit
 func getBatchedInventoryReports(reports AccountRoutedReports, limits config.InventoryReportLimits) BatchedReports {
+	defer tracker.TrackPhaseTime(time.Now(), metrics.PhaseBatch, "Batching inventory reports")
 	batchCount := 0
 	batched := BatchedReports{}
 	for account, accountReport := range reports {
 		// Check if batching is enabled
 		if limits.PayloadThresholdBytes <= 0 && limits.Namespaces <= 0 {
 			batched[account] = append(batched[account], accountReport)
+			metrics.BatchesBuilt.WithLabelValues(account).Inc()
 			continue
 		}
 
@@ -572,6 +712,7 @@ func getBatchedInventoryReports(reports AccountRoutedReports, limits config.Inve
 				(limits.Namespaces > 0 && len(state.currNS) >= limits.Namespaces) {
 				if rpt := state.createReportBatch(accountReport); rpt != nil {
 					batched[account] = append(batched[account], *rpt)
+					metrics.BatchesBuilt.WithLabelValues(account).Inc()
 					batchCount++
 				}
 			}
@@ -580,6 +721,7 @@ func getBatchedInventoryReports(reports AccountRoutedReports, limits config.Inve
 		// Emit tail batch (if any).
 		if rpt := state.createReportBatch(accountReport); rpt != nil {
 			batched[account] = append(batched[account], *rpt)
+			metrics.BatchesBuilt.WithLabelValues(account).Inc()
 			batchCount++
 		}
 	}
@@ -589,19 +731,27 @@ func getBatchedInventoryReports(reports AccountRoutedReports, limits config.Inve
 }
 
 func processNamespace(
+	ctx context.Context,
 	clientset *kubernetes.Clientset,
 	cfg *config.Application,
 	ns inventory.Namespace,
 	ch channels,
 	nodes map[string]inventory.Node,
 ) {
+	ctx, span := tracing.Tracer().Start(ctx, "processNamespace", trace.WithAttributes(attribute.String("namespace", ns.Name)))
+	defer span.End()
+
 	v1pods, err := inventory.FetchPodsInNamespace(
+		ctx,
 		client.Client{Clientset: clientset},
 		cfg.Kubernetes.RequestBatchSize,
 		cfg.Kubernetes.RequestTimeoutSeconds,
 		ns.Name,
 	)
 	if err != nil {
+		healtherrors.Record(healtherrors.KubernetesCode(healtherrors.CodeListPods, err), err)
+		agentstatus.KubernetesError(err)
+		stats.FromContext(ctx).AddNamespaceFailed()
 		ch.errors <- err
 		return
 	}
@@ -614,7 +764,7 @@ func processNamespace(
 		return
 	}
 
-	pods := inventory.ProcessPods(v1pods, ns.UID, nodes, cfg.MetadataCollection.Pods.Annotations, cfg.MetadataCollection.Pods.Labels, cfg.MetadataCollection.Pods.Disable)
+	pods := inventory.ProcessPods(v1pods, ns.UID, nodes, cfg.MetadataCollection.Pods.AnnotationMatcher(), cfg.MetadataCollection.Pods.LabelMatcher(), cfg.MetadataCollection.Pods.Disable)
 	containers := inventory.GetContainersFromPods(
 		v1pods,
 		cfg.IgnoreNotRunning,
@@ -1,8 +1,10 @@
 package pkg
 
 import (
+	"context"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/anchore/k8s-inventory/internal/config"
 	"github.com/anchore/k8s-inventory/pkg/inventory"
@@ -71,6 +73,17 @@ var (
 	}
 )
 
+// buildAccountRoutes returns the account routes with their namespace patterns compiled, as in a built configuration
+func buildAccountRoutes(t *testing.T, accountRoutes config.AccountRoutes) config.AccountRoutes {
+	cfg := &config.Application{
+		AccountRoutes:               accountRoutes,
+		MissingTagPolicy:            config.MissingTagConf{Policy: "digest"},
+		HealthReportIntervalSeconds: 60,
+	}
+	assert.NoError(t, cfg.Build())
+	return cfg.AccountRoutes
+}
+
 func TestGetAccountRoutedNamespaces(t *testing.T) {
 	type args struct {
 		defaultAccount        string
@@ -243,7 +256,8 @@ func TestGetAccountRoutedNamespaces(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := GetAccountRoutedNamespaces(tt.args.defaultAccount, tt.args.namespaces, tt.args.accountRoutes, tt.args.namespaceLabelRouting)
+			accountRoutes := buildAccountRoutes(t, tt.args.accountRoutes)
+			got := GetAccountRoutedNamespaces(tt.args.defaultAccount, tt.args.namespaces, accountRoutes, tt.args.namespaceLabelRouting)
 			assert.Equal(t, tt.want, got)
 		})
 	}
@@ -945,3 +959,11 @@ func Test_getBatchedInventoryReportsByPayload(t *testing.T) {
 		})
 	}
 }
+
+func TestReportTime(t *testing.T) {
+	assert.WithinDuration(t, time.Now(), reportTime(context.Background()), time.Minute)
+
+	// the replicas sharing the namespaces report a cycle with its start as timestamp
+	cycleStart := time.Date(2024, 10, 4, 10, 10, 0, 0, time.UTC)
+	assert.Equal(t, cycleStart, reportTime(withReportTime(context.Background(), cycleStart)))
+}
@@ -2,147 +2,123 @@
 package reporter
 
 import (
-	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"net/url"
 	"strings"
 	"time"
 
+	"github.com/anchore/k8s-inventory/internal/anchore"
 	"github.com/anchore/k8s-inventory/internal/config"
 	"github.com/anchore/k8s-inventory/internal/log"
+	"github.com/anchore/k8s-inventory/internal/metrics"
+	"github.com/anchore/k8s-inventory/internal/stats"
 	"github.com/anchore/k8s-inventory/internal/tracker"
 	"github.com/anchore/k8s-inventory/pkg/inventory"
-	"github.com/h2non/gock"
 )
 
-const (
-	reportAPIPathV1            = "v1/enterprise/kubernetes-inventory"
-	reportAPIPathV2            = "v2/kubernetes-inventory"
-	AnchoreAccountMissingError = "User account not found"
-)
-
-var (
-	ErrAnchoreAccountDoesNotExist = fmt.Errorf("user account not found")
-	enterpriseEndpoint            = reportAPIPathV2
-)
+const AnchoreAccountMissingError = "User account not found"
 
-type AnchoreResponse struct {
-	Message          string      `json:"message"`
-	Httpcode         int         `json:"httpcode"`
-	Detail           interface{} `json:"detail"`
-	AnchoreRequestID string      `json:"anchore_request_id"`
-}
+var ErrAnchoreAccountDoesNotExist = fmt.Errorf("user account not found")
 
 // This method does the actual Reporting (via HTTP) to Anchore
-//
-//nolint:funlen
-func Post(report inventory.Report, anchoreDetails config.AnchoreInfo) error {
+func Post(ctx context.Context, report inventory.Report, anchoreDetails config.AnchoreInfo) error {
 	defer tracker.TrackFunctionTime(time.Now(), "Reporting results to Anchore for cluster: "+report.ClusterName+"")
 	log.Debug("Validating and normalizing report before sending to Anchore")
-	report, modified := Normalize(report)
-	if modified {
+	report, dropped := NormalizeWithDetails(report)
+	if len(dropped) > 0 {
 		log.Warnf("Report was modified during normalization, some data may be missing")
 	}
-
-	log.Debug("Reporting results to Anchore using endpoint: ", enterpriseEndpoint)
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: anchoreDetails.HTTP.Insecure},
-	} // #nosec G402
-	client := &http.Client{
-		Transport: tr,
-		Timeout:   time.Duration(anchoreDetails.HTTP.TimeoutSeconds) * time.Second,
-	}
-	gock.InterceptClient(client) // Required to use gock for testing custom client
-
-	anchoreURL, err := buildURL(anchoreDetails, enterpriseEndpoint)
-	if err != nil {
-		return fmt.Errorf("failed to build url: %w", err)
+	for _, record := range dropped {
+		metrics.NormalizationDrops.WithLabelValues(record.Kind, record.Reason).Inc()
 	}
+	collectionStats := stats.FromContext(ctx)
+	collectionStats.AddNormalizationDrops(len(dropped))
 
 	reqBody, err := json.Marshal(report)
 	if err != nil {
 		return fmt.Errorf("failed to serialize results as JSON: %w", err)
 	}
+	if err := inventory.ValidateReportJSON(reqBody); err != nil {
+		return fmt.Errorf("report does not match the inventory report schema: %w", err)
+	}
+	metrics.BatchSize.WithLabelValues(anchoreDetails.Account).Observe(float64(len(reqBody)))
 
-	req, err := http.NewRequest("POST", anchoreURL, bytes.NewBuffer(reqBody))
-	if err != nil {
-		return fmt.Errorf("failed to send data to Anchore: %w", err)
+	err = post(anchore.DefaultClient(), reqBody, anchoreDetails)
+	collectionStats.AddAnchoreAPICall(err)
+	return err
+}
+
+func post(client *anchore.Client, reqBody []byte, anchoreDetails config.AnchoreInfo) error {
+	endpoint := client.Capabilities(anchoreDetails).InventoryAPIPath
+	log.Debug("Reporting results to Anchore using endpoint: ", endpoint)
+
+	_, err := client.Post(reqBody, "", endpoint, anchoreDetails, "inventory report")
+	if err == nil {
+		log.Debug("Successfully reported results to Anchore")
+		return nil
 	}
-	req.SetBasicAuth(anchoreDetails.User, anchoreDetails.Password)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-anchore-account", anchoreDetails.Account)
-	resp, err := client.Do(req)
-	if err != nil {
+
+	var apiClientError *anchore.APIClientError
+	if !errors.As(err, &apiClientError) {
 		return fmt.Errorf("failed to report data to Anchore: %w", err)
 	}
-	defer resp.Body.Close()
-	switch resp.StatusCode {
-	case 403:
+
+	switch apiClientError.HTTPStatusCode {
+	case http.StatusForbidden:
 		log.Debug("Forbidden response (403) from Anchore")
 		return ErrAnchoreAccountDoesNotExist
-	case 404:
-		previousVersion := enterpriseEndpoint
+	case http.StatusNotFound:
 		// We failed to send the inventory.  We need to check the version of Enterprise.
-		versionError := checkVersion(anchoreDetails)
-		if versionError != nil {
+		if _, versionError := client.GetVersion(anchoreDetails); versionError != nil {
 			return fmt.Errorf("failed to validate Enterprise API: %w", versionError)
 		}
-		if previousVersion != enterpriseEndpoint {
+		newEndpoint := client.Capabilities(anchoreDetails).InventoryAPIPath
+		log.Info("Using enterprise endpoint ", newEndpoint)
+		if newEndpoint != endpoint {
 			// We need to re-send the inventory with the new endpoint
-			log.Info("Retrying inventory report with new endpoint: ", enterpriseEndpoint)
-			return Post(report, anchoreDetails)
+			log.Info("Retrying inventory report with new endpoint: ", newEndpoint)
+			return post(client, reqBody, anchoreDetails)
 		}
 
 		// Check if account is correct
-		respBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("failed to read response from Anchore: %w", err)
-		}
-		anchoreResponse := &AnchoreResponse{}
-		err = json.Unmarshal(respBody, anchoreResponse)
-		if err != nil {
-			return fmt.Errorf("failed to parse response from Anchore: %w", err)
-		}
-		if strings.Contains(anchoreResponse.Message, AnchoreAccountMissingError) {
+		if apiClientError.APIErrorDetails != nil &&
+			strings.Contains(apiClientError.APIErrorDetails.Message, AnchoreAccountMissingError) {
 			return ErrAnchoreAccountDoesNotExist
 		}
-		return fmt.Errorf("failed to report data to Anchore: %s", string(respBody))
-	}
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return fmt.Errorf("failed to report data to Anchore: %+v", resp)
 	}
-
-	// Check we received a valid JSON response from Anchore, this will help catch
-	// any redirect responses where it returns HTML login pages e.g. Enterprise
-	// running behind cloudflare where a login page is returned with the status 200
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response from Anchore: %w", err)
-	}
-	if len(respBody) > 0 && !json.Valid(respBody) {
-		log.Debug("Anchore response body: ", string(respBody))
-		return fmt.Errorf("failed to report data to Anchore not a valid json response: %+v", resp)
-	}
-	log.Debug("Successfully reported results to Anchore")
-	return nil
+	return fmt.Errorf("failed to report data to Anchore: %w", err)
 }
 
 // Only send a report that contains all required references in the report. E.g. if a container references a pod that is not in the report, remove the container from the report and log it.
 // This is likely due to timing issues gathering the inventory but we should not send incomplete data to Anchore.
 // Returns the normalized report and a boolean indicating if the report was modified.
+func Normalize(report inventory.Report) (inventory.Report, bool) {
+	newReport, dropped := NormalizeWithDetails(report)
+	return newReport, len(dropped) > 0
+}
+
+// DroppedRecord describes a record that was omitted from (or, for pods on unknown nodes, modified in) a report
+// during normalization
+type DroppedRecord struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// NormalizeWithDetails normalizes the report like Normalize, and returns the records that were dropped or modified
 //
 //nolint:funlen
-func Normalize(report inventory.Report) (inventory.Report, bool) {
-	modified := false
+func NormalizeWithDetails(report inventory.Report) (inventory.Report, []DroppedRecord) {
+	dropped := make([]DroppedRecord, 0)
 
 	namespaces := make(map[string]inventory.Namespace)
 	for _, ns := range report.Namespaces {
 		if ns.UID == "" {
-			modified = true
+			dropped = append(dropped, DroppedRecord{Kind: "Namespace", Name: ns.Name, Reason: "no UID"})
 			log.Warnf("Namespace has no UID omitting from report: %s", ns.Name)
 			continue
 		}
@@ -152,7 +128,7 @@ func Normalize(report inventory.Report) (inventory.Report, bool) {
 	nodes := make(map[string]inventory.Node)
 	for _, node := range report.Nodes {
 		if node.UID == "" {
-			modified = true
+			dropped = append(dropped, DroppedRecord{Kind: "Node", Name: node.Name, Reason: "no UID"})
 			log.Warnf("Node has no UID omitting from report: %s", node.Name)
 			continue
 		}
@@ -162,12 +138,13 @@ func Normalize(report inventory.Report) (inventory.Report, bool) {
 	pods := make(map[string]inventory.Pod)
 	for _, pod := range report.Pods {
 		if pod.UID == "" {
-			modified = true
+			dropped = append(dropped, DroppedRecord{Kind: "Pod", Name: pod.Name, Reason: "no UID"})
 			log.Warnf("Pod has no UID omitting from report: %s", pod.Name)
 			continue
 		}
 		if _, ok := namespaces[pod.NamespaceUID]; !ok {
-			modified = true
+			dropped = append(dropped, DroppedRecord{Kind: "Pod", Name: pod.Name, ID: pod.UID,
+				Reason: "references a namespace that is not in the report"})
 			log.Warnf(
 				"Pod references a namespace that is not in the report, omitting from final report: %s, %s",
 				pod.UID,
@@ -176,7 +153,8 @@ func Normalize(report inventory.Report) (inventory.Report, bool) {
 			continue
 		}
 		if _, ok := nodes[pod.NodeUID]; !ok {
-			modified = true
+			dropped = append(dropped, DroppedRecord{Kind: "Pod", Name: pod.Name, ID: pod.UID,
+				Reason: "references a node that is not in the report, node field omitted"})
 			log.Warnf(
 				"Pod references a node that is not in the report, omitting Node field from final report: %s, %s",
 				pod.NodeUID,
@@ -198,12 +176,13 @@ func Normalize(report inventory.Report) (inventory.Report, bool) {
 	containers := make([]inventory.Container, 0)
 	for _, container := range report.Containers {
 		if container.ID == "" {
-			modified = true
+			dropped = append(dropped, DroppedRecord{Kind: "Container", Name: container.Name, Reason: "no ID"})
 			log.Warnf("Container has no ID omitting from report: %s", container.Name)
 			continue
 		}
 		if _, ok := pods[container.PodUID]; !ok {
-			modified = true
+			dropped = append(dropped, DroppedRecord{Kind: "Container", Name: container.Name, ID: container.ID,
+				Reason: "references a pod that is not in the report"})
 			log.Warnf("Container references a pod that is not in the report: %s, %s", container.ID, container.Name)
 			continue
 		}
@@ -218,9 +197,14 @@ func Normalize(report inventory.Report) (inventory.Report, bool) {
 		Namespaces:            make([]inventory.Namespace, 0),
 		Nodes:                 make([]inventory.Node, 0),
 		Pods:                  make([]inventory.Pod, 0),
+		SchemaVersion:         report.SchemaVersion,
 		ServerVersionMetadata: report.ServerVersionMetadata,
 		Timestamp:             report.Timestamp,
 	}
+	if newReport.SchemaVersion == "" {
+		// e.g. reports saved before the format was versioned
+		newReport.SchemaVersion = inventory.SchemaVersion
+	}
 
 	for _, ns := range namespaces {
 		newReport.Namespaces = append(newReport.Namespaces, ns)
@@ -231,72 +215,5 @@ func Normalize(report inventory.Report) (inventory.Report, bool) {
 	for _, pod := range pods {
 		newReport.Pods = append(newReport.Pods, pod)
 	}
-	return newReport, modified
-}
-
-type AnchoreVersion struct {
-	API struct {
-		Version string `json:"version"`
-	} `json:"api"`
-	DB struct {
-		SchemaVersion string `json:"schema_version"`
-	} `json:"db"`
-	Service struct {
-		Version string `json:"version"`
-	} `json:"service"`
-}
-
-// This method retrieves the API version from Anchore
-// and caches the response if parsed successfully
-func checkVersion(anchoreDetails config.AnchoreInfo) error {
-	log.Debug("Detecting Anchore API version")
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: anchoreDetails.HTTP.Insecure},
-	} // #nosec G402
-	client := &http.Client{
-		Transport: tr,
-		Timeout:   time.Duration(anchoreDetails.HTTP.TimeoutSeconds) * time.Second,
-	}
-	gock.InterceptClient(client) // Required to use gock for testing custom client
-
-	resp, err := client.Get(anchoreDetails.URL + "/version")
-	if err != nil {
-		return fmt.Errorf("failed to contact Anchore API: %w", err)
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("failed to retrieve Anchore API version: %+v", resp)
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read Anchore API version: %w", err)
-	}
-
-	ver := AnchoreVersion{}
-	err = json.Unmarshal(body, &ver)
-	if err != nil {
-		return fmt.Errorf("failed to parse API version: %w", err)
-	}
-
-	log.Debug("Anchore API version: ", ver)
-	if ver.API.Version == "2" {
-		enterpriseEndpoint = reportAPIPathV2
-	} else {
-		// If we can't parse the version, we'll assume it's v1 as 4.X does not include the version in the API version response
-		enterpriseEndpoint = reportAPIPathV1
-	}
-
-	log.Info("Using enterprise endpoint ", enterpriseEndpoint)
-	return nil
-}
-
-func buildURL(anchoreDetails config.AnchoreInfo, path string) (string, error) {
-	anchoreURL, err := url.Parse(anchoreDetails.URL)
-	if err != nil {
-		return "", err
-	}
-
-	anchoreURL.Path += path
-
-	return anchoreURL.String(), nil
+	return newReport, dropped
 }
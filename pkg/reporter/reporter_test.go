@@ -1,34 +1,16 @@
 package reporter
 
 import (
+	"context"
 	"testing"
 
+	"github.com/anchore/k8s-inventory/internal/anchore"
 	"github.com/anchore/k8s-inventory/internal/config"
 	"github.com/anchore/k8s-inventory/pkg/inventory"
 	"github.com/h2non/gock"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestBuildUrl(t *testing.T) {
-	anchoreDetails := config.AnchoreInfo{
-		URL:      "https://ancho.re",
-		User:     "admin",
-		Password: "foobar",
-	}
-
-	expectedURL := "https://ancho.re/v1/enterprise/kubernetes-inventory"
-	actualURL, err := buildURL(anchoreDetails, "v1/enterprise/kubernetes-inventory")
-	if err != nil || expectedURL != actualURL {
-		t.Errorf("Failed to build URL:\nexpected=%s\nactual=%s", expectedURL, actualURL)
-	}
-
-	expectedURL = "https://ancho.re/v2/kubernetes-inventory"
-	actualURL, err = buildURL(anchoreDetails, "v2/kubernetes-inventory")
-	if err != nil || expectedURL != actualURL {
-		t.Errorf("Failed to build URL:\nexpected=%s\nactual=%s", expectedURL, actualURL)
-	}
-}
-
 func TestPost(t *testing.T) {
 	defer gock.Off()
 
@@ -58,7 +40,7 @@ func TestPost(t *testing.T) {
 				},
 			},
 			wantErr:         false,
-			expectedAPIPath: reportAPIPathV2,
+			expectedAPIPath: anchore.InventoryAPIPathV2,
 		},
 		{
 			name: "post to v1 when v2 is not found",
@@ -76,7 +58,7 @@ func TestPost(t *testing.T) {
 				},
 			},
 			wantErr:         false,
-			expectedAPIPath: reportAPIPathV1,
+			expectedAPIPath: anchore.InventoryAPIPathV1,
 		},
 		{
 			name: "error when v1 and v2 are not found",
@@ -94,7 +76,7 @@ func TestPost(t *testing.T) {
 				},
 			},
 			wantErr:         true,
-			expectedAPIPath: reportAPIPathV1,
+			expectedAPIPath: anchore.InventoryAPIPathV1,
 		},
 		{
 			name: "error when api response is not JSON",
@@ -112,22 +94,22 @@ func TestPost(t *testing.T) {
 				},
 			},
 			wantErr:         true,
-			expectedAPIPath: reportAPIPathV2,
+			expectedAPIPath: anchore.InventoryAPIPathV2,
 		},
 	}
 	for _, tt := range tests {
 		switch tt.name {
 		case "default post to v2":
 			gock.New("https://ancho.re").
-				Post(reportAPIPathV2).
+				Post(anchore.InventoryAPIPathV2).
 				Reply(201).
 				JSON(map[string]interface{}{})
 		case "post to v1 when v2 is not found":
 			gock.New("https://ancho.re").
-				Post(reportAPIPathV2).
+				Post(anchore.InventoryAPIPathV2).
 				Reply(404)
 			gock.New("https://ancho.re").
-				Post(reportAPIPathV1).
+				Post(anchore.InventoryAPIPathV1).
 				Reply(201).
 				JSON(map[string]interface{}{})
 			gock.New("https://ancho.re").
@@ -140,29 +122,29 @@ func TestPost(t *testing.T) {
 				})
 		case "error when v1 and v2 are not found":
 			gock.New("https://ancho.re").
-				Post(reportAPIPathV2).
+				Post(anchore.InventoryAPIPathV2).
 				Reply(404)
 			gock.New("https://ancho.re").
 				Get("/version").
 				Reply(404)
 		case "error when api response is not JSON":
 			gock.New("https://ancho.re").
-				Post(reportAPIPathV2).
+				Post(anchore.InventoryAPIPathV2).
 				Reply(201).
 				BodyString("not json")
 		}
 
 		t.Run(tt.name, func(t *testing.T) {
-			// Reset enterpriseEndpoint to the default each test run
-			enterpriseEndpoint = reportAPIPathV2
+			// Reset the negotiated API version each test run
+			anchore.DefaultClient().Reset()
 
-			err := Post(tt.args.report, tt.args.anchoreDetails)
+			err := Post(context.Background(), tt.args.report, tt.args.anchoreDetails)
 
 			if tt.wantErr {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.expectedAPIPath, enterpriseEndpoint)
+				assert.Equal(t, tt.expectedAPIPath, anchore.DefaultClient().Capabilities(tt.args.anchoreDetails).InventoryAPIPath)
 			}
 		})
 	}
@@ -185,11 +167,11 @@ func TestPostSimulateV1ToV2HandoverFromEnterprise4Xto5X(t *testing.T) {
 		},
 	}
 
-	enterpriseEndpoint = reportAPIPathV2
+	anchore.DefaultClient().Reset()
 
-	// After the first post to default v2, the enterpriseEndpoint should be set to v1
+	// After the first post to default v2, the inventory endpoint should be set to v1
 	gock.New("https://ancho.re").
-		Post(reportAPIPathV2).
+		Post(anchore.InventoryAPIPathV2).
 		Reply(404)
 	gock.New("https://ancho.re").
 		Get("/version").
@@ -200,16 +182,16 @@ func TestPostSimulateV1ToV2HandoverFromEnterprise4Xto5X(t *testing.T) {
 			"service": map[string]interface{}{"version": "4.8.0"},
 		})
 	gock.New("https://ancho.re").
-		Post(reportAPIPathV1).
+		Post(anchore.InventoryAPIPathV1).
 		Reply(201).
 		JSON(map[string]interface{}{})
-	err := Post(testReport, testAnchoreDetails)
+	err := Post(context.Background(), testReport, testAnchoreDetails)
 	assert.NoError(t, err)
-	assert.Equal(t, reportAPIPathV1, enterpriseEndpoint)
+	assert.Equal(t, anchore.InventoryAPIPathV1, anchore.DefaultClient().Capabilities(testAnchoreDetails).InventoryAPIPath)
 
 	// Simulate upgrade to Enterprise 5.x, v1 should no longer be available
 	gock.New("https://ancho.re").
-		Post(reportAPIPathV1).
+		Post(anchore.InventoryAPIPathV1).
 		Reply(404)
 	gock.New("https://ancho.re").
 		Get("/version").
@@ -220,12 +202,12 @@ func TestPostSimulateV1ToV2HandoverFromEnterprise4Xto5X(t *testing.T) {
 			"service": map[string]interface{}{"version": "4.8.0"},
 		})
 	gock.New("https://ancho.re").
-		Post(reportAPIPathV2).
+		Post(anchore.InventoryAPIPathV2).
 		Reply(201).
 		JSON(map[string]interface{}{})
-	err = Post(testReport, testAnchoreDetails)
+	err = Post(context.Background(), testReport, testAnchoreDetails)
 	assert.NoError(t, err)
-	assert.Equal(t, reportAPIPathV2, enterpriseEndpoint)
+	assert.Equal(t, anchore.InventoryAPIPathV2, anchore.DefaultClient().Capabilities(testAnchoreDetails).InventoryAPIPath)
 }
 
 func TestNormalize(t *testing.T) {
@@ -981,7 +963,47 @@ func TestNormalize(t *testing.T) {
 			assert.Equal(t, tt.want.ClusterName, report.ClusterName)
 			assert.Equal(t, tt.want.Timestamp, report.Timestamp)
 			assert.Equal(t, tt.want.ServerVersionMetadata, report.ServerVersionMetadata)
+			assert.Equal(t, inventory.SchemaVersion, report.SchemaVersion)
 			assert.Equal(t, tt.modified, mod)
+			assert.NoError(t, inventory.ValidateReport(report))
 		})
 	}
 }
+
+func TestPostAccountDoesNotExist(t *testing.T) {
+	defer gock.Off()
+	anchore.DefaultClient().Reset()
+
+	testAnchoreDetails := config.AnchoreInfo{
+		URL:      "https://ancho.re",
+		User:     "admin",
+		Password: "foobar",
+		Account:  "test",
+		HTTP: config.HTTPConfig{
+			TimeoutSeconds: 10,
+			Insecure:       true,
+		},
+	}
+
+	// the detail of the error is a string rather than an object
+	gock.New("https://ancho.re").
+		Post(anchore.InventoryAPIPathV2).
+		Reply(404).
+		JSON(map[string]interface{}{
+			"message":  "User account not found",
+			"detail":   "account test does not exist",
+			"httpcode": 404,
+		})
+	gock.New("https://ancho.re").
+		Get("/version").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"api":     map[string]interface{}{"version": "2"},
+			"db":      map[string]interface{}{"schema_version": "400"},
+			"service": map[string]interface{}{"version": "5.0.0"},
+		})
+
+	err := Post(context.Background(), inventory.Report{}, testAnchoreDetails)
+	assert.ErrorIs(t, err, ErrAnchoreAccountDoesNotExist)
+	assert.True(t, gock.IsDone())
+}
@@ -0,0 +1,248 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/internal/log"
+	"github.com/anchore/k8s-inventory/internal/matcher"
+	"github.com/anchore/k8s-inventory/pkg/inventory"
+)
+
+// The rules that can route a namespace to an account, in order of precedence
+const (
+	RouteRuleAccountRoutes  = "account-routes"
+	RouteRuleNamespaceLabel = "namespace-label"
+	RouteRuleDefaultAccount = "default-account"
+)
+
+// RouteMatch is an account that a namespace is routed to, and the rule that routed it there
+type RouteMatch struct {
+	Account string `json:"account"`
+	Rule    string `json:"rule"`
+	Detail  string `json:"detail"`
+}
+
+// RouteDecision explains where the inventory of a namespace is sent
+type RouteDecision struct {
+	Namespace inventory.Namespace `json:"-"`
+	Name      string              `json:"namespace"`
+	Matches   []RouteMatch        `json:"matches"`
+	// MultipleRoutes is set when the namespace matched more than one account route, and is sent to each of them
+	MultipleRoutes bool `json:"multiple_routes"`
+	// Dropped is set when the namespace is not sent anywhere, because it is missing the routing label and
+	// ignore-missing-label is set
+	Dropped bool `json:"dropped"`
+}
+
+// ExplainAccountRouting returns the routing decision for each namespace. Explicit account routes take precedence
+// over the namespace label, which takes precedence over the default account.
+func ExplainAccountRouting(defaultAccount string, namespaces []inventory.Namespace,
+	accountRoutes config.AccountRoutes, namespaceLabelRouting config.AccountRouteByNamespaceLabel) []RouteDecision {
+	if namespaceLabelRouting.DefaultAccount != "" {
+		defaultAccount = namespaceLabelRouting.DefaultAccount
+	}
+
+	accounts := make([]string, 0, len(accountRoutes))
+	namespaceMatchers := make(map[string]*matcher.Matcher, len(accountRoutes))
+	for account, route := range accountRoutes {
+		accounts = append(accounts, account)
+		namespaceMatchers[account] = route.NamespaceMatcher()
+	}
+	sort.Strings(accounts)
+
+	decisions := make([]RouteDecision, 0, len(namespaces))
+	for _, ns := range namespaces {
+		decision := RouteDecision{Namespace: ns, Name: ns.Name, Matches: make([]RouteMatch, 0)}
+
+		for _, account := range accounts {
+			// the namespace is only sent once to each account, however many of its patterns match
+			if namespaceRegex, ok := namespaceMatchers[account].Match(ns.Name); ok {
+				decision.Matches = append(decision.Matches, RouteMatch{
+					Account: account,
+					Rule:    RouteRuleAccountRoutes,
+					Detail:  fmt.Sprintf("matches %q", namespaceRegex),
+				})
+			}
+		}
+		decision.MultipleRoutes = len(decision.Matches) > 1
+
+		if len(decision.Matches) == 0 {
+			decision = routeByLabelOrDefault(decision, defaultAccount, namespaceLabelRouting)
+		}
+
+		decisions = append(decisions, decision)
+	}
+	return decisions
+}
+
+// routeByLabelOrDefault routes a namespace that no account route matched. If there is a namespace label routing,
+// the namespace is routed based on the label (explicit account routes override the label routing for the case where
+// the label cannot be changed). Otherwise, it is routed to the default account unless disabled.
+func routeByLabelOrDefault(decision RouteDecision, defaultAccount string, namespaceLabelRouting config.AccountRouteByNamespaceLabel) RouteDecision {
+	if namespaceLabelRouting.LabelKey == "" {
+		decision.Matches = append(decision.Matches, RouteMatch{
+			Account: defaultAccount,
+			Rule:    RouteRuleDefaultAccount,
+			Detail:  "no account route matches",
+		})
+		return decision
+	}
+
+	if account, ok := decision.Namespace.Labels[namespaceLabelRouting.LabelKey]; ok {
+		decision.Matches = append(decision.Matches, RouteMatch{
+			Account: account,
+			Rule:    RouteRuleNamespaceLabel,
+			Detail:  fmt.Sprintf("label %s=%s", namespaceLabelRouting.LabelKey, account),
+		})
+	} else if !namespaceLabelRouting.IgnoreMissingLabel {
+		decision.Matches = append(decision.Matches, RouteMatch{
+			Account: defaultAccount,
+			Rule:    RouteRuleDefaultAccount,
+			Detail:  fmt.Sprintf("missing label %s", namespaceLabelRouting.LabelKey),
+		})
+	} else {
+		decision.Dropped = true
+	}
+	return decision
+}
+
+func GetAccountRoutedNamespaces(defaultAccount string, namespaces []inventory.Namespace,
+	accountRoutes config.AccountRoutes, namespaceLabelRouting config.AccountRouteByNamespaceLabel) map[string][]inventory.Namespace {
+	accountRoutesForAllNamespaces := make(map[string][]inventory.Namespace)
+
+	for _, decision := range ExplainAccountRouting(defaultAccount, namespaces, accountRoutes, namespaceLabelRouting) {
+		if decision.Dropped {
+			log.Infof("Ignoring namespace %s because it does not have the label %s", decision.Name, namespaceLabelRouting.LabelKey)
+			continue
+		}
+		for _, match := range decision.Matches {
+			log.Debugf("Namespace %s routed to account %s by %s (%s)", decision.Name, match.Account, match.Rule, match.Detail)
+			accountRoutesForAllNamespaces[match.Account] = append(accountRoutesForAllNamespaces[match.Account], decision.Namespace)
+		}
+	}
+
+	return accountRoutesForAllNamespaces
+}
+
+// GetRouteDecisions explains the account routing for the namespaces in the cluster or, if a file is given, for the
+// namespaces in the file. The file can be a namespace list (e.g. from kubectl get namespaces -o yaml) or a plain
+// list of namespace names, one per line. The namespace selectors and metadata configuration are applied as they are
+// when collecting the inventory.
+func GetRouteDecisions(cfg *config.Application, namespacesFile string) ([]RouteDecision, error) {
+	var namespaces []inventory.Namespace
+	var err error
+	if namespacesFile != "" {
+		namespaces, err = getNamespacesFromFile(cfg, namespacesFile)
+	} else {
+		namespaces, err = GetAllNamespaces(context.Background(), cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].Name < namespaces[j].Name
+	})
+	return ExplainAccountRouting(cfg.AnchoreDetails.Account, namespaces, cfg.AccountRoutes, cfg.AccountRouteByNamespaceLabel), nil
+}
+
+func getNamespacesFromFile(cfg *config.Application, namespacesFile string) ([]inventory.Namespace, error) {
+	contents, err := os.ReadFile(namespacesFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read namespaces file: %w", err)
+	}
+	namespaces, err := parseNamespaces(contents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse namespaces file %s: %w", namespacesFile, err)
+	}
+
+	return inventory.SelectNamespaces(namespaces,
+		cfg.NamespaceSelectors.ExcludeMatcher(), cfg.NamespaceSelectors.Include,
+		cfg.MetadataCollection.Namespace.AnnotationMatcher(), cfg.MetadataCollection.Namespace.LabelMatcher(),
+		cfg.MetadataCollection.Namespace.Disable), nil
+}
+
+func parseNamespaces(contents []byte) ([]v1.Namespace, error) {
+	namespaces := make([]v1.Namespace, 0)
+
+	list := v1.NamespaceList{}
+	decodeErr := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(contents), len(contents)).Decode(&list)
+	// kubectl get namespaces prints a List, which decodes the same way as a NamespaceList
+	if decodeErr == nil && (list.Kind == "NamespaceList" || list.Kind == "List" || len(list.Items) > 0) {
+		return append(namespaces, list.Items...), nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		if strings.ContainsAny(name, " \t:{}[]") {
+			return nil, fmt.Errorf("expected a namespace list or one namespace name per line, got %q", name)
+		}
+		namespaces = append(namespaces, v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name)}})
+	}
+	return namespaces, scanner.Err()
+}
+
+// WriteRouteDecisions writes the routing decisions as a table (followed by any warnings) or as JSON
+func WriteRouteDecisions(w io.Writer, decisions []RouteDecision, output string) error {
+	switch output {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(decisions)
+	case "table", "":
+	default:
+		return fmt.Errorf("unsupported output format %q, must be one of [table json]", output)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tACCOUNT\tRULE\tDETAIL")
+	multiple := make([]string, 0)
+	dropped := make([]string, 0)
+	for _, decision := range decisions {
+		if decision.Dropped {
+			dropped = append(dropped, decision.Name)
+			fmt.Fprintf(tw, "%s\t-\t%s\tdropped, missing label and ignore-missing-label is set\n", decision.Name, RouteRuleNamespaceLabel)
+			continue
+		}
+		if decision.MultipleRoutes {
+			multiple = append(multiple, decision.Name)
+		}
+		for _, match := range decision.Matches {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", decision.Name, match.Account, match.Rule, match.Detail)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	if len(multiple) > 0 {
+		fmt.Fprintf(&sb, "\nWARNING: namespaces matched by more than one account route (sent to each account): %s\n",
+			strings.Join(multiple, ", "))
+	}
+	if len(dropped) > 0 {
+		fmt.Fprintf(&sb, "\nWARNING: namespaces not reported because they are missing the routing label: %s\n",
+			strings.Join(dropped, ", "))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,115 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/pkg/inventory"
+)
+
+func TestExplainAccountRouting(t *testing.T) {
+	namespaces := []inventory.Namespace{
+		{Name: "team-a", UID: "1"},
+		{Name: "team-a-shared", UID: "2"},
+		{Name: "labelled", UID: "3", Labels: map[string]string{"anchore.io/account": "team-b"}},
+		{Name: "unlabelled", UID: "4"},
+	}
+	accountRoutes := buildAccountRoutes(t, config.AccountRoutes{
+		"team-a": {Namespaces: []string{"team-a", "team-a.*"}},
+		"shared": {Namespaces: []string{"shared"}},
+	})
+
+	decisions := ExplainAccountRouting("admin", namespaces, accountRoutes, config.AccountRouteByNamespaceLabel{
+		LabelKey:           "anchore.io/account",
+		IgnoreMissingLabel: true,
+	})
+	assert.Equal(t, []RouteDecision{
+		{
+			Namespace: namespaces[0],
+			Name:      "team-a",
+			Matches:   []RouteMatch{{Account: "team-a", Rule: RouteRuleAccountRoutes, Detail: `matches "team-a"`}},
+		},
+		{
+			Namespace: namespaces[1],
+			Name:      "team-a-shared",
+			Matches: []RouteMatch{
+				{Account: "shared", Rule: RouteRuleAccountRoutes, Detail: `matches "shared"`},
+				{Account: "team-a", Rule: RouteRuleAccountRoutes, Detail: `matches "team-a"`},
+			},
+			MultipleRoutes: true,
+		},
+		{
+			Namespace: namespaces[2],
+			Name:      "labelled",
+			Matches:   []RouteMatch{{Account: "team-b", Rule: RouteRuleNamespaceLabel, Detail: "label anchore.io/account=team-b"}},
+		},
+		{
+			Namespace: namespaces[3],
+			Name:      "unlabelled",
+			Matches:   []RouteMatch{},
+			Dropped:   true,
+		},
+	}, decisions)
+
+	var sb strings.Builder
+	assert.NoError(t, WriteRouteDecisions(&sb, decisions, "table"))
+	output := sb.String()
+	assert.Contains(t, output, "namespaces matched by more than one account route (sent to each account): team-a-shared")
+	assert.Contains(t, output, "namespaces not reported because they are missing the routing label: unlabelled")
+
+	assert.Error(t, WriteRouteDecisions(&sb, decisions, "yaml"))
+}
+
+func TestGetRouteDecisionsFromFile(t *testing.T) {
+	dir := t.TempDir()
+	namespaceList := filepath.Join(dir, "namespaces.yaml")
+	assert.NoError(t, os.WriteFile(namespaceList, []byte(`apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Namespace
+  metadata:
+    name: team-b
+    uid: 4b9e5c4c-0a0e-4d6f-9d5c-5d3f0e0f6f0b
+    labels:
+      anchore.io/account: team-b
+- apiVersion: v1
+  kind: Namespace
+  metadata:
+    name: kube-system
+    uid: 0e3c7f0a-9d3e-4c4c-8a4e-2f2c1b1e0d0c
+`), 0600))
+	namespaceNames := filepath.Join(dir, "namespaces.txt")
+	assert.NoError(t, os.WriteFile(namespaceNames, []byte("team-b\n\n# system namespaces\nkube-system\n"), 0600))
+
+	cfg := &config.Application{
+		AnchoreDetails:               config.AnchoreInfo{Account: "admin"},
+		AccountRouteByNamespaceLabel: config.AccountRouteByNamespaceLabel{LabelKey: "anchore.io/account"},
+		NamespaceSelectors:           config.NamespaceSelector{Exclude: []string{"kube-system"}},
+		Kubernetes:                   config.KubernetesAPI{RequestBatchSize: 100, RequestTimeoutSeconds: 10},
+		MissingTagPolicy:             config.MissingTagConf{Policy: "digest"},
+		HealthReportIntervalSeconds:  60,
+	}
+	assert.NoError(t, cfg.Build())
+
+	decisions, err := GetRouteDecisions(cfg, namespaceList)
+	assert.NoError(t, err)
+	assert.Len(t, decisions, 1)
+	assert.Equal(t, []RouteMatch{{Account: "team-b", Rule: RouteRuleNamespaceLabel, Detail: "label anchore.io/account=team-b"}},
+		decisions[0].Matches)
+
+	// names only, so the namespace is routed to the default account as it has no labels
+	decisions, err = GetRouteDecisions(cfg, namespaceNames)
+	assert.NoError(t, err)
+	assert.Len(t, decisions, 1)
+	assert.Equal(t, []RouteMatch{{Account: "admin", Rule: RouteRuleDefaultAccount, Detail: "missing label anchore.io/account"}},
+		decisions[0].Matches)
+
+	_, err = GetRouteDecisions(cfg, filepath.Join(dir, "missing.yaml"))
+	assert.ErrorContains(t, err, "failed to read namespaces file")
+}
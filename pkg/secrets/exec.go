@@ -0,0 +1,110 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/internal/log"
+)
+
+const (
+	defaultExecTimeout = 30 * time.Second
+	// Credentials are refreshed this long before they expire, so that a report is never sent with credentials that
+	// expire while it is in flight
+	execRefreshWindow = 30 * time.Second
+)
+
+// ExecCredentialOutput is the JSON printed to stdout by a credential command
+type ExecCredentialOutput struct {
+	User     string     `json:"user,omitempty"`
+	Password string     `json:"password,omitempty"`
+	Token    string     `json:"token,omitempty"`
+	Expiry   *time.Time `json:"expiry,omitempty"`
+}
+
+// execCache holds the credentials printed by credential commands until they expire. Credentials without an expiry
+// are not cached, i.e. the command runs every time they are needed.
+type execCache struct {
+	mu          sync.Mutex
+	credentials map[string]ExecCredentialOutput
+	now         func() time.Time
+}
+
+var defaultExecCache = newExecCache()
+
+func newExecCache() *execCache {
+	return &execCache{
+		credentials: make(map[string]ExecCredentialOutput),
+		now:         time.Now,
+	}
+}
+
+// get returns the credentials for the Anchore account, running the credential command if there are no valid cached
+// credentials. Each account (the global one and every account route) is cached separately.
+func (c *execCache) get(anchoreDetails config.AnchoreInfo) (ExecCredentialOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := fmt.Sprintf("%s|%s|%v", anchoreDetails.Account, anchoreDetails.URL, anchoreDetails.Exec)
+	if cached, ok := c.credentials[key]; ok && c.now().Add(execRefreshWindow).Before(*cached.Expiry) {
+		return cached, nil
+	}
+
+	log.Debugf("Running credential command %s for Anchore account %s", anchoreDetails.Exec.Command, anchoreDetails.Account)
+	credentials, err := runCredentialCommand(anchoreDetails)
+	if err != nil {
+		delete(c.credentials, key)
+		return ExecCredentialOutput{}, err
+	}
+
+	if credentials.Expiry != nil {
+		c.credentials[key] = credentials
+	} else {
+		delete(c.credentials, key)
+	}
+	return credentials, nil
+}
+
+// runCredentialCommand runs the credential command and parses its output. The Anchore account and URL are passed
+// to the command as ANCHORE_ACCOUNT and ANCHORE_URL, in addition to the configured environment variables.
+func runCredentialCommand(anchoreDetails config.AnchoreInfo) (ExecCredentialOutput, error) {
+	execCredential := anchoreDetails.Exec
+	timeout := defaultExecTimeout
+	if execCredential.TimeoutSeconds > 0 {
+		timeout = time.Duration(execCredential.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// #nosec G204 the command is explicitly configured by the operator
+	cmd := exec.CommandContext(ctx, execCredential.Command, execCredential.Args...)
+	cmd.Env = append(os.Environ(), "ANCHORE_ACCOUNT="+anchoreDetails.Account, "ANCHORE_URL="+anchoreDetails.URL)
+	for name, value := range execCredential.Env {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return ExecCredentialOutput{}, fmt.Errorf("credential command %s failed: %w: %s", execCredential.Command, err,
+			strings.TrimSpace(stderr.String()))
+	}
+
+	credentials := ExecCredentialOutput{}
+	if err := json.Unmarshal(stdout.Bytes(), &credentials); err != nil {
+		return ExecCredentialOutput{}, fmt.Errorf("failed to parse output of credential command %s: %w", execCredential.Command, err)
+	}
+	if credentials.Password == "" && credentials.Token == "" {
+		return ExecCredentialOutput{}, fmt.Errorf("credential command %s did not print a password or token", execCredential.Command)
+	}
+	return credentials, nil
+}
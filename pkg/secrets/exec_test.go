@@ -0,0 +1,115 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+)
+
+// credentialCommand returns a credential command that prints the given output and records every invocation (with
+// the account it was run for) in the returned file
+func credentialCommand(t *testing.T, output string) (config.ExecCredential, string) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	return config.ExecCredential{
+		Command: "sh",
+		Args:    []string{"-c", `echo "$ANCHORE_ACCOUNT" >> "$CALLS" && echo "$OUTPUT"`},
+		Env:     map[string]string{"CALLS": calls, "OUTPUT": output},
+	}, calls
+}
+
+func readCalls(t *testing.T, calls string) []string {
+	contents, err := os.ReadFile(calls)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	assert.NoError(t, err)
+	return strings.Fields(string(contents))
+}
+
+func TestResolveAnchoreDetailsExecCachesUntilExpiry(t *testing.T) {
+	now := time.Date(2024, 10, 4, 10, 11, 12, 0, time.UTC)
+	cache := newExecCache()
+	cache.now = func() time.Time { return now }
+	resolver := &Resolver{appConfig: &config.Application{}, execCache: cache}
+
+	execCredential, calls := credentialCommand(t,
+		`{"user": "team-a", "password": "s3cr3t", "expiry": "2024-10-04T10:21:12Z"}`)
+	teamA := config.AnchoreInfo{URL: "https://ancho.re", Account: "team-a", Exec: execCredential}
+	teamB := teamA
+	teamB.Account = "team-b"
+
+	got, err := resolver.ResolveAnchoreDetails(teamA)
+	assert.NoError(t, err)
+	assert.Equal(t, "team-a", got.User)
+	assert.Equal(t, "s3cr3t", got.Password)
+	assert.True(t, got.IsValid())
+
+	// cached per account until shortly before the credentials expire
+	_, err = resolver.ResolveAnchoreDetails(teamA)
+	assert.NoError(t, err)
+	_, err = resolver.ResolveAnchoreDetails(teamB)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"team-a", "team-b"}, readCalls(t, calls))
+
+	now = now.Add(9*time.Minute + 40*time.Second)
+	_, err = resolver.ResolveAnchoreDetails(teamA)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"team-a", "team-b", "team-a"}, readCalls(t, calls))
+}
+
+func TestResolveAnchoreDetailsExecWithoutExpiry(t *testing.T) {
+	resolver := &Resolver{appConfig: &config.Application{}, execCache: newExecCache()}
+
+	execCredential, calls := credentialCommand(t, `{"token": "t0k3n"}`)
+	details := config.AnchoreInfo{URL: "https://ancho.re", Account: "admin", User: "admin", Exec: execCredential}
+
+	for i := 0; i < 2; i++ {
+		got, err := resolver.ResolveAnchoreDetails(details)
+		assert.NoError(t, err)
+		assert.Equal(t, "admin", got.User)
+		assert.Equal(t, "t0k3n", got.Token)
+	}
+	assert.Equal(t, []string{"admin", "admin"}, readCalls(t, calls))
+}
+
+func TestResolveAnchoreDetailsExecFailures(t *testing.T) {
+	tests := []struct {
+		name           string
+		execCredential config.ExecCredential
+		wantErr        string
+	}{
+		{
+			name:           "command fails",
+			execCredential: config.ExecCredential{Command: "sh", Args: []string{"-c", "echo vault is sealed >&2; exit 1"}},
+			wantErr:        "credential command sh failed: exit status 1: vault is sealed",
+		},
+		{
+			name:           "invalid output",
+			execCredential: config.ExecCredential{Command: "sh", Args: []string{"-c", "echo s3cr3t"}},
+			wantErr:        "failed to parse output of credential command sh",
+		},
+		{
+			name:           "no credentials",
+			execCredential: config.ExecCredential{Command: "sh", Args: []string{"-c", `echo '{"user": "admin"}'`}},
+			wantErr:        "credential command sh did not print a password or token",
+		},
+		{
+			name:           "timeout",
+			execCredential: config.ExecCredential{Command: "sleep", Args: []string{"5"}, TimeoutSeconds: 1},
+			wantErr:        "credential command sleep failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &Resolver{appConfig: &config.Application{}, execCache: newExecCache()}
+			_, err := resolver.ResolveAnchoreDetails(config.AnchoreInfo{Account: "admin", Exec: tt.execCredential})
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
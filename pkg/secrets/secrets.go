@@ -0,0 +1,157 @@
+/*
+Package secrets resolves the credentials that are referenced by the configuration rather than given inline, either
+as files, as keys of Kubernetes Secrets or from the output of a credential command. Credentials are read again every
+time they are resolved (or, for credential commands, once they expire), so that changes (e.g. rotated passwords) are
+picked up without restarting the agent.
+*/
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/pkg/client"
+)
+
+type Resolver struct {
+	appConfig  *config.Application
+	clientset  kubernetes.Interface
+	clientsets *clientsetCache
+	execCache  *execCache
+}
+
+func NewResolver(appConfig *config.Application) *Resolver {
+	return &Resolver{appConfig: appConfig, clientsets: defaultClientsetCache, execCache: defaultExecCache}
+}
+
+// clientsetCache holds the Kubernetes client that reads the Secrets, so that it is created once per process rather
+// than every time credentials are resolved. A new client is only created if the kubeconfig changes.
+type clientsetCache struct {
+	mu           sync.Mutex
+	kubeConfig   config.KubeConf
+	clientset    kubernetes.Interface
+	newClientset func(appConfig *config.Application) (kubernetes.Interface, error)
+}
+
+var defaultClientsetCache = &clientsetCache{newClientset: newClientset}
+
+func (c *clientsetCache) get(appConfig *config.Application) (kubernetes.Interface, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.clientset != nil && reflect.DeepEqual(c.kubeConfig, appConfig.KubeConfig) {
+		return c.clientset, nil
+	}
+	clientset, err := c.newClientset(appConfig)
+	if err != nil {
+		return nil, err
+	}
+	c.kubeConfig = appConfig.KubeConfig
+	c.clientset = clientset
+	return clientset, nil
+}
+
+func newClientset(appConfig *config.Application) (kubernetes.Interface, error) {
+	kubeconfig, err := client.GetKubeConfig(appConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig: %w", err)
+	}
+	return client.GetClientSet(kubeconfig)
+}
+
+// ResolveAnchoreDetails returns the Anchore details with the password and token read from their files, Secrets or
+// credential command
+func ResolveAnchoreDetails(appConfig *config.Application, anchoreDetails config.AnchoreInfo) (config.AnchoreInfo, error) {
+	return NewResolver(appConfig).ResolveAnchoreDetails(anchoreDetails)
+}
+
+func (r *Resolver) ResolveAnchoreDetails(anchoreDetails config.AnchoreInfo) (config.AnchoreInfo, error) {
+	if anchoreDetails.Exec.IsSet() {
+		credentials, err := r.execCache.get(anchoreDetails)
+		if err != nil {
+			return anchoreDetails, err
+		}
+		if credentials.User != "" {
+			anchoreDetails.User = credentials.User
+		}
+		anchoreDetails.Password = credentials.Password
+		anchoreDetails.Token = credentials.Token
+		return anchoreDetails, nil
+	}
+
+	password, err := r.resolve("password", anchoreDetails.Password, anchoreDetails.PasswordFile, anchoreDetails.PasswordSecretRef)
+	if err != nil {
+		return anchoreDetails, err
+	}
+	token, err := r.resolve("token", anchoreDetails.Token, anchoreDetails.TokenFile, anchoreDetails.TokenSecretRef)
+	if err != nil {
+		return anchoreDetails, err
+	}
+
+	anchoreDetails.Password = password
+	anchoreDetails.Token = token
+	return anchoreDetails, nil
+}
+
+func (r *Resolver) resolve(name, value, file string, ref config.SecretRef) (string, error) {
+	switch {
+	case value != "":
+		return value, nil
+	case file != "":
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s from file: %w", name, err)
+		}
+		return strings.TrimSpace(string(contents)), nil
+	case ref.IsSet():
+		secretValue, err := r.readSecret(ref)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s from Secret: %w", name, err)
+		}
+		return secretValue, nil
+	default:
+		return "", nil
+	}
+}
+
+func (r *Resolver) readSecret(ref config.SecretRef) (string, error) {
+	clientset, err := r.getClientset()
+	if err != nil {
+		return "", err
+	}
+
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = os.Getenv("POD_NAMESPACE")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(r.appConfig.Kubernetes.RequestTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	data, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in Secret %s/%s", ref.Key, namespace, ref.Name)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// getClientset returns the Kubernetes client, which is only created once a Secret actually needs to be read
+func (r *Resolver) getClientset() (kubernetes.Interface, error) {
+	if r.clientset != nil {
+		return r.clientset, nil
+	}
+	return r.clientsets.get(r.appConfig)
+}
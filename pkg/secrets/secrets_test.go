@@ -0,0 +1,152 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+)
+
+func TestResolveAnchoreDetails(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "anchore")
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	assert.NoError(t, os.WriteFile(passwordFile, []byte("from-file\n"), 0600))
+
+	clientset := fake.NewClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-a", Namespace: "team-a"},
+			Data:       map[string][]byte{"password": []byte("team-a-password"), "token": []byte("team-a-token")},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "anchore"},
+			Data:       map[string][]byte{"token": []byte("agent-token")},
+		},
+	)
+
+	tests := []struct {
+		name    string
+		details config.AnchoreInfo
+		want    config.AnchoreInfo
+		wantErr string
+	}{
+		{
+			name:    "inline",
+			details: config.AnchoreInfo{User: "admin", Password: "inline", Token: "inline-token"},
+			want:    config.AnchoreInfo{User: "admin", Password: "inline", Token: "inline-token"},
+		},
+		{
+			name:    "file",
+			details: config.AnchoreInfo{User: "admin", PasswordFile: passwordFile},
+			want:    config.AnchoreInfo{User: "admin", Password: "from-file", PasswordFile: passwordFile},
+		},
+		{
+			name: "secret",
+			details: config.AnchoreInfo{
+				User:              "team-a",
+				PasswordSecretRef: config.SecretRef{Name: "team-a", Namespace: "team-a", Key: "password"},
+				TokenSecretRef:    config.SecretRef{Name: "agent", Key: "token"},
+			},
+			want: config.AnchoreInfo{
+				User:              "team-a",
+				Password:          "team-a-password",
+				PasswordSecretRef: config.SecretRef{Name: "team-a", Namespace: "team-a", Key: "password"},
+				Token:             "agent-token",
+				TokenSecretRef:    config.SecretRef{Name: "agent", Key: "token"},
+			},
+		},
+		{
+			name:    "missing file",
+			details: config.AnchoreInfo{PasswordFile: filepath.Join(t.TempDir(), "missing")},
+			wantErr: "failed to read password from file",
+		},
+		{
+			name:    "missing secret",
+			details: config.AnchoreInfo{TokenSecretRef: config.SecretRef{Name: "team-b", Key: "token"}},
+			wantErr: "failed to read token from Secret",
+		},
+		{
+			name:    "missing key",
+			details: config.AnchoreInfo{TokenSecretRef: config.SecretRef{Name: "team-a", Namespace: "team-a", Key: "api-key"}},
+			wantErr: "key api-key not found in Secret team-a/team-a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &Resolver{appConfig: &config.Application{}, clientset: clientset}
+			got, err := resolver.ResolveAnchoreDetails(tt.details)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestResolveAnchoreDetailsPicksUpChanges(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("token-1"), 0600))
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a", Namespace: "team-a"},
+		Data:       map[string][]byte{"password": []byte("password-1")},
+	}
+	clientset := fake.NewClientset(secret)
+	resolver := &Resolver{appConfig: &config.Application{}, clientset: clientset}
+	details := config.AnchoreInfo{
+		TokenFile:         tokenFile,
+		PasswordSecretRef: config.SecretRef{Name: "team-a", Namespace: "team-a", Key: "password"},
+	}
+
+	got, err := resolver.ResolveAnchoreDetails(details)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", got.Token)
+	assert.Equal(t, "password-1", got.Password)
+
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("token-2"), 0600))
+	secret.Data["password"] = []byte("password-2")
+	_, err = clientset.CoreV1().Secrets("team-a").Update(context.Background(), secret, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+
+	got, err = resolver.ResolveAnchoreDetails(details)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-2", got.Token)
+	assert.Equal(t, "password-2", got.Password)
+}
+
+func TestClientsetCache(t *testing.T) {
+	created := 0
+	cache := &clientsetCache{newClientset: func(*config.Application) (kubernetes.Interface, error) {
+		created++
+		return fake.NewClientset(&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "anchore"},
+			Data:       map[string][]byte{"password": []byte("agent-password")},
+		}), nil
+	}}
+	anchoreDetails := config.AnchoreInfo{PasswordSecretRef: config.SecretRef{Name: "agent", Namespace: "anchore", Key: "password"}}
+
+	// every resolver, e.g. one per report, shares the client
+	for i := 0; i < 3; i++ {
+		resolver := &Resolver{appConfig: &config.Application{}, clientsets: cache}
+		resolved, err := resolver.ResolveAnchoreDetails(anchoreDetails)
+		assert.NoError(t, err)
+		assert.Equal(t, "agent-password", resolved.Password)
+	}
+	assert.Equal(t, 1, created)
+
+	// a new client is created when the kubeconfig changes
+	resolver := &Resolver{appConfig: &config.Application{KubeConfig: config.KubeConf{Path: "/etc/kubeconfig"}}, clientsets: cache}
+	_, err := resolver.ResolveAnchoreDetails(anchoreDetails)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, created)
+}
@@ -0,0 +1,76 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/internal/log"
+	"github.com/anchore/k8s-inventory/internal/metrics"
+	"github.com/anchore/k8s-inventory/pkg/healthreporter"
+	"github.com/anchore/k8s-inventory/pkg/inventory"
+	"github.com/anchore/k8s-inventory/pkg/reporter"
+	"github.com/anchore/k8s-inventory/pkg/tracing"
+)
+
+// SendReports sends previously saved inventory reports to an Anchore account (the default account if empty). Each
+// report is batched by the inventory report limits and sent like a collected inventory, falling back to the default
+// account if the account does not exist. Sending stops when the context is done.
+func SendReports(ctx context.Context, cfg *config.Application, account string, reports []inventory.Report) (err error) {
+	if account == "" {
+		account = cfg.AnchoreDetails.Account
+	}
+	if err := checkAnchoreDetails(cfg, account); err != nil {
+		return err
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "inventory.send")
+	defer func() { tracing.End(span, err) }()
+
+	batches := 0
+	failures := 0
+	reportInfo := healthreporter.InventoryReportInfo{}
+	for i, report := range reports {
+		reportsForAccount := getBatchedInventoryReports(AccountRoutedReports{account: report}, cfg.InventoryReportLimits)[account]
+		for count, batch := range reportsForAccount {
+			if ctx.Err() != nil {
+				return fmt.Errorf("stopped sending inventory reports after %d batches: %w", batches, ctx.Err())
+			}
+			log.Infof("Sending saved Inventory Report %d of %d to Anchore Account %s, %d of %d",
+				i+1, len(reports), account, count+1, len(reportsForAccount))
+			batches++
+
+			err := HandleReport(ctx, batch, &reportInfo, cfg, account)
+			if errors.Is(err, reporter.ErrAnchoreAccountDoesNotExist) {
+				retryAccount := GetDefaultAccount(cfg)
+				metrics.DefaultAccountFallbacks.WithLabelValues(account).Inc()
+				log.Warnf("Error sending to Anchore Account %s, sending to default account", account)
+				if err = checkAnchoreDetails(cfg, retryAccount); err == nil {
+					err = HandleReport(ctx, batch, &reportInfo, cfg, retryAccount)
+				}
+			}
+			if err != nil {
+				log.Errorf("Failed to handle Inventory Report: %+v", err)
+				failures++
+			}
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("failed to send %d of %d inventory reports", failures, batches)
+	}
+	return nil
+}
+
+// checkAnchoreDetails checks that the reports can be sent to the account, HandleReport does not send them otherwise
+func checkAnchoreDetails(cfg *config.Application, account string) error {
+	anchoreDetails, err := GetAccountAnchoreDetails(cfg, account)
+	if err != nil {
+		return err
+	}
+	if !anchoreDetails.IsValid() {
+		return fmt.Errorf("anchore details are not specified for account %s, set anchore.url and the credentials", account)
+	}
+	return nil
+}
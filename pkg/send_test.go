@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/k8s-inventory/internal/anchore"
+	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/internal/metrics"
+	"github.com/anchore/k8s-inventory/pkg/inventory"
+)
+
+func TestSendReports(t *testing.T) {
+	defer gock.Off()
+
+	cfg := &config.Application{
+		AnchoreDetails: config.AnchoreInfo{
+			URL:      "https://ancho.re",
+			User:     "admin",
+			Password: "foobar",
+			Account:  "admin",
+			HTTP:     config.HTTPConfig{TimeoutSeconds: 10},
+		},
+		InventoryReportLimits: config.InventoryReportLimits{Namespaces: 1},
+	}
+	report := inventory.Report{
+		Timestamp:  "2024-01-01T00:00:00Z",
+		Namespaces: []inventory.Namespace{{Name: "default", UID: "ns-1"}, {Name: "team-a", UID: "ns-2"}},
+	}
+
+	anchore.DefaultClient().Reset()
+
+	// the account does not exist, so both batches are sent to the default account instead
+	gock.New("https://ancho.re").
+		Post(anchore.InventoryAPIPathV2).
+		MatchHeader("x-anchore-account", "missing").
+		Times(2).
+		Reply(403)
+	gock.New("https://ancho.re").
+		Post(anchore.InventoryAPIPathV2).
+		MatchHeader("x-anchore-account", "admin").
+		Times(2).
+		Reply(201).
+		JSON(map[string]interface{}{})
+
+	assert.NoError(t, SendReports(context.Background(), cfg, "missing", []inventory.Report{report}))
+	assert.True(t, gock.IsDone())
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.DefaultAccountFallbacks.WithLabelValues("missing")))
+
+	gock.New("https://ancho.re").
+		Post(anchore.InventoryAPIPathV2).
+		MatchHeader("x-anchore-account", "admin").
+		Reply(201).
+		JSON(map[string]interface{}{})
+	gock.New("https://ancho.re").
+		Post(anchore.InventoryAPIPathV2).
+		MatchHeader("x-anchore-account", "admin").
+		Reply(500)
+
+	assert.EqualError(t, SendReports(context.Background(), cfg, "", []inventory.Report{report}), "failed to send 1 of 2 inventory reports")
+}
+
+func TestSendReportsWithoutAnchoreDetails(t *testing.T) {
+	report := inventory.Report{Timestamp: "2024-01-01T00:00:00Z"}
+
+	// nothing is sent, and the reports are not reported as sent
+	err := SendReports(context.Background(), &config.Application{}, "admin", []inventory.Report{report})
+	assert.ErrorContains(t, err, "anchore details are not specified for account admin")
+
+	// the credentials of the account cannot be read
+	cfg := &config.Application{AnchoreDetails: config.AnchoreInfo{
+		URL:          "https://ancho.re",
+		User:         "admin",
+		PasswordFile: "/does/not/exist",
+		Account:      "admin",
+	}}
+	err = SendReports(context.Background(), cfg, "", []inventory.Report{report})
+	assert.ErrorContains(t, err, "unable to resolve credentials for Anchore account admin")
+}
+
+func TestSendReportsStopsWhenCancelled(t *testing.T) {
+	defer gock.Off()
+	cfg := &config.Application{AnchoreDetails: config.AnchoreInfo{
+		URL:      "https://ancho.re",
+		User:     "admin",
+		Password: "foobar",
+		Account:  "admin",
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// nothing is sent once the command was interrupted
+	err := SendReports(ctx, cfg, "", []inventory.Report{{Timestamp: "2024-01-01T00:00:00Z"}})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, gock.HasUnmatchedRequest())
+}
@@ -0,0 +1,108 @@
+// Package server serves the health probes and Prometheus metrics of the agent over HTTP
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/internal/log"
+)
+
+var ready atomic.Bool
+
+// SetReady marks the agent as ready once it has registered with Anchore or sent its first inventory report. The
+// agent stays ready from then on, failures are reported through the health reports and metrics instead.
+func SetReady(reason string) {
+	if !ready.Swap(true) {
+		log.Infof("Agent is ready: %s", reason)
+	}
+}
+
+// IsReady returns whether the agent has registered with Anchore or sent an inventory report
+func IsReady() bool {
+	return ready.Load()
+}
+
+type Server struct {
+	httpServer *http.Server
+	listener   net.Listener
+	timeout    time.Duration
+}
+
+func New(cfg config.ServerConfig) *Server {
+	return &Server{
+		httpServer: &http.Server{
+			Addr:              cfg.ListenAddress,
+			Handler:           Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		timeout: time.Duration(cfg.ShutdownTimeoutSeconds) * time.Second,
+	}
+}
+
+// Handler serves /healthz, /readyz and /metrics
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		writeStatus(w, http.StatusOK, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		if !IsReady() {
+			writeStatus(w, http.StatusServiceUnavailable, "not ready: not registered with Anchore and no inventory report sent yet")
+			return
+		}
+		writeStatus(w, http.StatusOK, "ok")
+	})
+	mux.Handle("/metrics", promhttp.Handler())
+	return mux
+}
+
+func writeStatus(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintln(w, message)
+}
+
+// Start listens on the configured address and serves in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.httpServer.Addr, err)
+	}
+	s.listener = listener
+	log.Infof("Serving health probes and metrics on %s", listener.Addr())
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Health probe and metrics server failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return s.httpServer.Addr
+	}
+	return s.listener.Addr().String()
+}
+
+// Shutdown stops the server, waiting up to the shutdown timeout for in-flight requests to complete
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	log.Info("Shutting down health probe and metrics server")
+	return s.httpServer.Shutdown(ctx)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+)
+
+func TestHandler(t *testing.T) {
+	defer ready.Store(false)
+	handler := Handler()
+
+	get := func(path string) (int, string) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder.Code, recorder.Body.String()
+	}
+
+	code, _ := get("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+
+	code, body := get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, "not ready")
+
+	SetReady("registered with Anchore")
+	code, _ = get("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+
+	code, body = get("/metrics")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "go_goroutines")
+
+	code, _ = get("/unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestServerStartAndShutdown(t *testing.T) {
+	srv := New(config.ServerConfig{ListenAddress: "127.0.0.1:0", ShutdownTimeoutSeconds: 5})
+	require.NoError(t, srv.Start())
+
+	resp, err := http.Get("http://" + srv.Addr() + "/healthz")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok\n", string(body))
+
+	assert.NoError(t, srv.Shutdown(context.Background()))
+	_, err = http.Get("http://" + srv.Addr() + "/healthz")
+	assert.Error(t, err)
+
+	// the address is already in use
+	other := New(config.ServerConfig{ListenAddress: "127.0.0.1:0"})
+	require.NoError(t, other.Start())
+	defer other.Shutdown(context.Background())
+	inUse := New(config.ServerConfig{ListenAddress: other.Addr()})
+	assert.ErrorContains(t, inUse.Start(), "failed to listen")
+}
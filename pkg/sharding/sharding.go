@@ -0,0 +1,325 @@
+// Package sharding splits the namespaces between the replicas of the agent, so that each replica collects and reports
+// only its share of the inventory. The replicas discover each other through a coordination.k8s.io Lease per replica,
+// and each namespace is owned by one of the live replicas by rendezvous hashing of its UID, so that only the
+// namespaces of a replica that joins or leaves move between replicas.
+package sharding
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/internal/log"
+	"github.com/anchore/k8s-inventory/internal/metrics"
+	"github.com/anchore/k8s-inventory/pkg/client"
+	"github.com/anchore/k8s-inventory/pkg/inventory"
+)
+
+// GroupLabel is the label of the Leases of the replicas, set to the sharding group
+const GroupLabel = "anchore.com/k8s-inventory-shard-group"
+
+// staleLeaseDurations is how many lease durations a Lease must have expired for to be deleted, e.g. the Lease of a
+// replica that crashed or was evicted, and did not delete it
+const staleLeaseDurations = 3
+
+// current is the membership that the agent maintains, if sharding is enabled
+var current atomic.Pointer[Membership]
+
+// Status describes the share of the namespaces of the agent in the latest inventory collection
+type Status struct {
+	Replicas        []string `json:"replicas"`         // live replicas that the namespaces were split between
+	Namespaces      int      `json:"namespaces"`       // namespaces owned by the agent
+	TotalNamespaces int      `json:"total_namespaces"` // namespaces of all replicas
+}
+
+// Membership keeps the Lease of the agent renewed and tracks the live replicas of its group
+type Membership struct {
+	clientset     kubernetes.Interface
+	namespace     string
+	group         string
+	identity      string
+	leaseDuration time.Duration
+	renewInterval time.Duration
+	members       atomic.Pointer[[]string]
+	status        atomic.Pointer[Status]
+	left          atomic.Bool
+}
+
+// NewForConfig returns the membership of the agent in the sharding group of the configuration, identifying the agent
+// by its pod name (HOSTNAME)
+func NewForConfig(appConfig *config.Application) (*Membership, error) {
+	namespace := appConfig.Sharding.LeaseNamespace
+	if namespace == "" {
+		namespace = os.Getenv("POD_NAMESPACE")
+	}
+	if namespace == "" {
+		return nil, fmt.Errorf("sharding.lease-namespace is required when POD_NAMESPACE is not set")
+	}
+	identity, err := client.PodIdentity()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine identity for sharding: %w", err)
+	}
+
+	kubeconfig, err := client.GetKubeConfig(appConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig for sharding: %w", err)
+	}
+	clientset, err := client.GetClientSet(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clientset for sharding: %w", err)
+	}
+	return New(appConfig.Sharding, clientset, namespace, identity), nil
+}
+
+// New returns the membership of the agent, identified with identity, in the sharding group with Leases in the
+// namespace
+func New(cfg config.ShardingConfig, clientset kubernetes.Interface, namespace, identity string) *Membership {
+	m := &Membership{
+		clientset:     clientset,
+		namespace:     namespace,
+		group:         cfg.Group,
+		identity:      identity,
+		leaseDuration: time.Duration(cfg.LeaseDurationSeconds) * time.Second,
+		renewInterval: time.Duration(cfg.RenewIntervalSeconds) * time.Second,
+	}
+	m.members.Store(&[]string{identity})
+	return m
+}
+
+// Join makes the agent collect only its share of the namespaces, and announces it to the other replicas. If the
+// replicas cannot be discovered, the agent collects all namespaces until they are.
+func (m *Membership) Join(ctx context.Context) {
+	current.Store(m)
+	if err := m.refresh(ctx); err != nil {
+		log.Warnf("Failed to discover the replicas to share the namespaces with: %v", err)
+	}
+}
+
+// Run keeps the Lease of the agent renewed and the live replicas up to date until the context is done, and then
+// deletes the Lease so that the other replicas take over the namespaces of the agent right away
+func (m *Membership) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.renewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			m.leave()
+			return
+		case <-ticker.C:
+			if err := m.refresh(ctx); err != nil {
+				log.Warnf("Failed to refresh the replicas to share the namespaces with: %v", err)
+			}
+		}
+	}
+}
+
+// Members returns the sorted identities of the live replicas, always including the agent itself
+func (m *Membership) Members() []string {
+	return *m.members.Load()
+}
+
+func (m *Membership) leaseName() string {
+	return m.group + "-" + m.identity
+}
+
+// refresh renews the Lease of the agent and lists the Leases of the group to find the live replicas. The previous
+// replicas are kept if they cannot be listed.
+func (m *Membership) refresh(ctx context.Context) error {
+	now := time.Now()
+	if err := m.renew(ctx, now); err != nil {
+		return err
+	}
+	leases, err := m.clientset.CoordinationV1().Leases(m.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: GroupLabel + "=" + m.group,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list Leases of sharding group %s: %w", m.group, err)
+	}
+
+	members := []string{m.identity}
+	for _, lease := range leases.Items {
+		if isStale(lease, now) {
+			m.deleteStale(ctx, lease)
+			continue
+		}
+		holder := lease.Spec.HolderIdentity
+		if holder == nil || *holder == m.identity || !isLive(lease, now) {
+			continue
+		}
+		members = append(members, *holder)
+	}
+	slices.Sort(members)
+	members = slices.Compact(members)
+
+	if previous := m.members.Swap(&members); !slices.Equal(*previous, members) {
+		log.Infof("Sharing the namespaces between %d replicas: %v", len(members), members)
+	}
+	metrics.ShardReplicas.Set(float64(len(members)))
+	return nil
+}
+
+func isLive(lease coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return false
+	}
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return expiry.After(now)
+}
+
+// isStale returns whether the Lease expired more than staleLeaseDurations lease durations ago
+func isStale(lease coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return false
+	}
+	staleAfter := staleLeaseDurations * time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	return lease.Spec.RenewTime.Add(staleAfter).Before(now)
+}
+
+// deleteStale deletes the Lease of a replica that is gone, unless it was renewed in the meantime
+func (m *Membership) deleteStale(ctx context.Context, lease coordinationv1.Lease) {
+	err := m.clientset.CoordinationV1().Leases(m.namespace).Delete(ctx, lease.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &lease.UID, ResourceVersion: &lease.ResourceVersion},
+	})
+	if err != nil && !errors.IsNotFound(err) && !errors.IsConflict(err) {
+		log.Warnf("Failed to delete stale Lease %s: %v", lease.Name, err)
+		return
+	}
+	log.Debugf("Deleted stale Lease %s of sharding group %s", lease.Name, m.group)
+}
+
+// renew creates or renews the Lease of the agent
+func (m *Membership) renew(ctx context.Context, now time.Time) error {
+	leases := m.clientset.CoordinationV1().Leases(m.namespace)
+	renewTime := metav1.NewMicroTime(now)
+	leaseDurationSeconds := int32(m.leaseDuration / time.Second)
+
+	lease, err := leases.Get(ctx, m.leaseName(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      m.leaseName(),
+				Namespace: m.namespace,
+				Labels:    map[string]string{GroupLabel: m.group},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &m.identity,
+				LeaseDurationSeconds: &leaseDurationSeconds,
+				AcquireTime:          &renewTime,
+				RenewTime:            &renewTime,
+			},
+		}
+		if _, err := leases.Create(ctx, lease, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create Lease %s: %w", m.leaseName(), err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get Lease %s: %w", m.leaseName(), err)
+	}
+
+	if lease.Labels == nil {
+		lease.Labels = map[string]string{}
+	}
+	lease.Labels[GroupLabel] = m.group
+	lease.Spec.HolderIdentity = &m.identity
+	lease.Spec.LeaseDurationSeconds = &leaseDurationSeconds
+	lease.Spec.RenewTime = &renewTime
+	if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to renew Lease %s: %w", m.leaseName(), err)
+	}
+	return nil
+}
+
+// leave deletes the Lease of the agent, within the renew interval. From then on the agent owns no namespaces, as the
+// other replicas take them over.
+func (m *Membership) leave() {
+	m.left.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), m.renewInterval)
+	defer cancel()
+	err := m.clientset.CoordinationV1().Leases(m.namespace).Delete(ctx, m.leaseName(), metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		log.Warnf("Failed to delete Lease %s, the other replicas take over once it expired: %v", m.leaseName(), err)
+		return
+	}
+	log.Infof("Left sharding group %s", m.group)
+}
+
+// Owner returns the member that owns the key, which is the member with the highest hash of itself and the key
+// (rendezvous hashing). When a member leaves, only its keys move, spread over the remaining members.
+func Owner(members []string, key string) string {
+	var owner string
+	var highest uint64
+	for _, member := range members {
+		if score := hash(member, key); owner == "" || score > highest {
+			owner, highest = member, score
+		}
+	}
+	return owner
+}
+
+func hash(member, key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(member))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	// FNV alone spreads similar inputs poorly, finalize it like MurmurHash3 to compare the scores of the members
+	x := binary.BigEndian.Uint64(h.Sum(nil))
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// CycleStart returns the start of the collection cycle that now is in. The replicas collect their share of the
+// namespaces on the same cycles, which start at the multiples of the polling interval, and report it with the start
+// of the cycle as timestamp: Anchore receives the shares of a cycle like the batches of one inventory report, which
+// all have the same timestamp.
+func CycleStart(now time.Time, pollingInterval time.Duration) time.Time {
+	return now.Truncate(pollingInterval).UTC()
+}
+
+// Namespaces returns the namespaces that the agent owns among the live replicas, by namespace UID, or all of them if
+// sharding is disabled. Once the agent left the replicas, it owns none of them.
+func Namespaces(namespaces []inventory.Namespace) []inventory.Namespace {
+	m := current.Load()
+	if m == nil {
+		return namespaces
+	}
+	if m.left.Load() {
+		log.Infof("Collecting none of %d namespaces, the agent left sharding group %s", len(namespaces), m.group)
+		return []inventory.Namespace{}
+	}
+	members := m.Members()
+	owned := make([]inventory.Namespace, 0, len(namespaces)/len(members)+1)
+	for _, namespace := range namespaces {
+		if Owner(members, namespace.UID) == m.identity {
+			owned = append(owned, namespace)
+		}
+	}
+	log.Infof("Collecting %d of %d namespaces as one of %d replicas", len(owned), len(namespaces), len(members))
+	m.status.Store(&Status{Replicas: members, Namespaces: len(owned), TotalNamespaces: len(namespaces)})
+	return owned
+}
+
+// GetStatus returns the share of the namespaces of the agent in the latest inventory collection, or nil if sharding
+// is disabled or no inventory was collected yet
+func GetStatus() *Status {
+	m := current.Load()
+	if m == nil {
+		return nil
+	}
+	return m.status.Load()
+}
@@ -0,0 +1,168 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+	"github.com/anchore/k8s-inventory/pkg/inventory"
+)
+
+var shardingConfig = config.ShardingConfig{
+	Enabled:              true,
+	Group:                "anchore-k8s-inventory",
+	LeaseDurationSeconds: 30,
+	RenewIntervalSeconds: 10,
+}
+
+func TestOwnerDistribution(t *testing.T) {
+	members := []string{"pod-a", "pod-b", "pod-c"}
+	owned := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		owned[Owner(members, fmt.Sprintf("namespace-uid-%d", i))]++
+	}
+	for _, member := range members {
+		assert.InDelta(t, 1000, owned[member], 150, "namespaces owned by %s", member)
+	}
+}
+
+func TestOwnerRebalance(t *testing.T) {
+	members := []string{"pod-a", "pod-b", "pod-c"}
+	remaining := []string{"pod-a", "pod-c"}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("namespace-uid-%d", i)
+		// only the namespaces of the replica that left move
+		if owner := Owner(members, key); owner != "pod-b" {
+			assert.Equal(t, owner, Owner(remaining, key))
+		}
+	}
+	assert.Empty(t, Owner(nil, "namespace-uid"))
+}
+
+func TestMembership(t *testing.T) {
+	defer current.Store(nil)
+	ctx := context.Background()
+	expired := metav1.NewMicroTime(time.Now().Add(-time.Minute))
+	leaseDurationSeconds := int32(30)
+	holder := "pod-gone"
+	clientset := fake.NewClientset(&coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "anchore-k8s-inventory-pod-gone",
+			Namespace: "anchore",
+			Labels:    map[string]string{GroupLabel: "anchore-k8s-inventory"},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &leaseDurationSeconds,
+			RenewTime:            &expired,
+		},
+	})
+
+	first := New(shardingConfig, clientset, "anchore", "pod-a")
+	second := New(shardingConfig, clientset, "anchore", "pod-b")
+	first.Join(ctx)
+	second.Join(ctx)
+	assert.Equal(t, []string{"pod-a", "pod-b"}, second.Members())
+
+	// the first replica sees the second one once it refreshed
+	assert.Equal(t, []string{"pod-a"}, first.Members())
+	require.NoError(t, first.refresh(ctx))
+	assert.Equal(t, []string{"pod-a", "pod-b"}, first.Members())
+
+	// the Lease of a replica is deleted when it leaves
+	second.leave()
+	_, err := clientset.CoordinationV1().Leases("anchore").Get(ctx, "anchore-k8s-inventory-pod-b", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+	require.NoError(t, first.refresh(ctx))
+	assert.Equal(t, []string{"pod-a"}, first.Members())
+}
+
+func TestStaleLeases(t *testing.T) {
+	defer current.Store(nil)
+	ctx := context.Background()
+	leaseDurationSeconds := int32(30)
+	lease := func(holder string, renewed time.Duration) *coordinationv1.Lease {
+		renewTime := metav1.NewMicroTime(time.Now().Add(-renewed))
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "anchore-k8s-inventory-" + holder,
+				Namespace: "anchore",
+				Labels:    map[string]string{GroupLabel: "anchore-k8s-inventory"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &leaseDurationSeconds,
+				RenewTime:            &renewTime,
+			},
+		}
+	}
+	clientset := fake.NewClientset(lease("pod-crashed", 10*time.Minute), lease("pod-late", time.Minute))
+
+	m := New(shardingConfig, clientset, "anchore", "pod-a")
+	m.Join(ctx)
+	assert.Equal(t, []string{"pod-a"}, m.Members())
+
+	// the Lease of a replica that is gone for several lease durations is deleted, a late one is kept
+	leases, err := clientset.CoordinationV1().Leases("anchore").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	names := make([]string, 0, len(leases.Items))
+	for _, lease := range leases.Items {
+		names = append(names, lease.Name)
+	}
+	assert.ElementsMatch(t, []string{"anchore-k8s-inventory-pod-a", "anchore-k8s-inventory-pod-late"}, names)
+}
+
+func TestCycleStart(t *testing.T) {
+	// the replicas agree on the start of the cycle, however late in the cycle they are
+	pollingInterval := 5 * time.Minute
+	cycleStart := time.Date(2024, 10, 4, 10, 10, 0, 0, time.UTC)
+	assert.Equal(t, cycleStart, CycleStart(cycleStart, pollingInterval))
+	assert.Equal(t, cycleStart, CycleStart(cycleStart.Add(4*time.Minute), pollingInterval))
+	assert.Equal(t, cycleStart, CycleStart(cycleStart.Add(time.Second).In(time.FixedZone("CEST", 2*60*60)), pollingInterval))
+	assert.Equal(t, cycleStart.Add(pollingInterval), CycleStart(cycleStart.Add(pollingInterval), pollingInterval))
+}
+
+func TestNamespaces(t *testing.T) {
+	defer current.Store(nil)
+	namespaces := make([]inventory.Namespace, 0, 100)
+	for i := 0; i < 100; i++ {
+		namespaces = append(namespaces, inventory.Namespace{
+			Name: fmt.Sprintf("namespace-%d", i),
+			UID:  fmt.Sprintf("namespace-uid-%d", i),
+		})
+	}
+
+	// all namespaces are collected when sharding is disabled
+	assert.Equal(t, namespaces, Namespaces(namespaces))
+	assert.Nil(t, GetStatus())
+
+	members := []string{"pod-a", "pod-b"}
+	collected := map[string]string{}
+	for _, identity := range members {
+		m := New(shardingConfig, fake.NewClientset(), "anchore", identity)
+		m.members.Store(&members)
+		current.Store(m)
+
+		owned := Namespaces(namespaces)
+		for _, namespace := range owned {
+			assert.NotContains(t, collected, namespace.Name)
+			collected[namespace.Name] = identity
+		}
+		assert.Equal(t, &Status{Replicas: members, Namespaces: len(owned), TotalNamespaces: 100}, GetStatus())
+	}
+	// every namespace is collected by exactly one replica
+	assert.Len(t, collected, 100)
+
+	// no namespace is collected once the agent left the replicas
+	current.Load().leave()
+	assert.Empty(t, Namespaces(namespaces))
+}
@@ -0,0 +1,150 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/anchore/k8s-inventory/internal/config"
+)
+
+// otlpReceiver is an in-process OTLP/HTTP trace receiver that keeps the received spans
+type otlpReceiver struct {
+	lock         sync.Mutex
+	serviceNames []string
+	spans        []*tracepb.Span
+}
+
+func (r *otlpReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/v1/traces" {
+		http.NotFound(w, req)
+		return
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	request := &collectortrace.ExportTraceServiceRequest{}
+	if err := proto.Unmarshal(body, request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, resourceSpans := range request.GetResourceSpans() {
+		for _, attr := range resourceSpans.GetResource().GetAttributes() {
+			if attr.GetKey() == "service.name" {
+				r.serviceNames = append(r.serviceNames, attr.GetValue().GetStringValue())
+			}
+		}
+		for _, scopeSpans := range resourceSpans.GetScopeSpans() {
+			r.spans = append(r.spans, scopeSpans.GetSpans()...)
+		}
+	}
+
+	response, _ := proto.Marshal(&collectortrace.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(response)
+}
+
+func (r *otlpReceiver) span(name string) *tracepb.Span {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, span := range r.spans {
+		if span.GetName() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func spanAttribute(span *tracepb.Span, key string) string {
+	for _, attr := range span.GetAttributes() {
+		if attr.GetKey() == key {
+			if attr.GetValue().GetStringValue() != "" {
+				return attr.GetValue().GetStringValue()
+			}
+			return attr.GetValue().String()
+		}
+	}
+	return ""
+}
+
+func TestSetup(t *testing.T) {
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	receiver := &otlpReceiver{}
+	collector := httptest.NewServer(receiver)
+	defer collector.Close()
+	kubernetesAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer kubernetesAPI.Close()
+
+	shutdown, err := Setup(context.Background(), config.TracingConfig{
+		Enabled:     true,
+		Endpoint:    collector.URL + "/v1/traces",
+		SampleRatio: 1,
+		ServiceName: "anchore-k8s-inventory-test",
+	})
+	require.NoError(t, err)
+
+	ctx, poll := Tracer().Start(context.Background(), "inventory.poll")
+	_, post := Tracer().Start(ctx, "reporter.Post", trace.WithAttributes(attribute.String("account", "admin")))
+	End(post, errors.New("anchore is unavailable"))
+
+	httpClient := http.Client{Transport: WrapTransport(http.DefaultTransport)}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, kubernetesAPI.URL+"/api/v1/namespaces", nil)
+	require.NoError(t, err)
+	response, err := httpClient.Do(request)
+	require.NoError(t, err)
+	response.Body.Close()
+	End(poll, nil)
+
+	require.NoError(t, shutdown(context.Background()))
+
+	assert.Contains(t, receiver.serviceNames, "anchore-k8s-inventory-test")
+
+	root := receiver.span("inventory.poll")
+	require.NotNil(t, root)
+	assert.Empty(t, root.GetParentSpanId())
+	assert.Equal(t, tracepb.Status_STATUS_CODE_UNSET, root.GetStatus().GetCode())
+
+	child := receiver.span("reporter.Post")
+	require.NotNil(t, child)
+	assert.Equal(t, root.GetSpanId(), child.GetParentSpanId())
+	assert.Equal(t, "admin", spanAttribute(child, "account"))
+	assert.Equal(t, tracepb.Status_STATUS_CODE_ERROR, child.GetStatus().GetCode())
+	assert.Equal(t, "anchore is unavailable", child.GetStatus().GetMessage())
+
+	clientSpan := receiver.span("HTTP GET")
+	require.NotNil(t, clientSpan)
+	assert.Equal(t, root.GetSpanId(), clientSpan.GetParentSpanId())
+	assert.Equal(t, tracepb.Span_SPAN_KIND_CLIENT, clientSpan.GetKind())
+}
+
+func TestSetupDisabled(t *testing.T) {
+	shutdown, err := Setup(context.Background(), config.TracingConfig{Enabled: false})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, span := Tracer().Start(context.Background(), "inventory.poll")
+	defer span.End()
+	assert.False(t, span.IsRecording())
+}
@@ -1,6 +1,7 @@
 package integration
 
 import (
+	"context"
 	"strings"
 	"testing"
 
@@ -18,7 +19,7 @@ const (
 //nolint:gocognit
 func TestGetImageResults(t *testing.T) {
 	cmd.InitAppConfig()
-	reports, err := pkg.GetInventoryReports(cmd.GetAppConfig())
+	reports, err := pkg.GetInventoryReports(context.Background(), cmd.GetAppConfig())
 	if err != nil {
 		t.Fatalf("failed to get image results: %v", err)
 	}